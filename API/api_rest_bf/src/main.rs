@@ -1,6 +1,6 @@
 use axum::{
-    extract::State,
-    http::StatusCode,
+    extract::{ConnectInfo, State},
+    http::{HeaderMap, StatusCode, header},
     response::IntoResponse,
     routing::{get, post},
     Json, Router,
@@ -66,17 +66,62 @@ async fn main() {
 
     let addr: SocketAddr = "0.0.0.0:8080".parse().unwrap();
     info!("API REST escuchando en http://{addr}");
-    axum::serve(tokio::net::TcpListener::bind(addr).await.unwrap(), app)
-        .await
-        .unwrap();
+    // con la IP del cliente para X-Forwarded-For (límites por IP del Go client)
+    axum::serve(
+        tokio::net::TcpListener::bind(addr).await.unwrap(),
+        app.into_make_service_with_connect_info::<SocketAddr>(),
+    )
+    .await
+    .unwrap();
 }
 
 async fn health() -> &'static str {
     "ok"
 }
 
+// Timeout de la llamada al Go client: el de X-Request-Timeout (ms o con
+// unidad, "500ms" / "2s") más un margen para que el Go client conteste, o 2s.
+fn timeout_pedido(headers: &HeaderMap) -> std::time::Duration {
+    let pedido = headers
+        .get("x-request-timeout")
+        .and_then(|v| v.to_str().ok())
+        .and_then(|v| {
+            let v = v.trim();
+            if let Some(ms) = v.strip_suffix("ms") {
+                ms.parse::<u64>().ok()
+            } else if let Some(s) = v.strip_suffix('s') {
+                s.parse::<u64>().ok().map(|s| s * 1000)
+            } else {
+                v.parse::<u64>().ok()
+            }
+        });
+    match pedido {
+        Some(ms) => std::time::Duration::from_millis(ms.min(10_000) + 500),
+        None => std::time::Duration::from_secs(2),
+    }
+}
+
+// El X-Request-ID entrante va a los logs y se reenvía, así que solo se acepta
+// con la misma regla que el Go client: 1 a 128 bytes de [A-Za-z0-9._-]. Si no
+// la cumple se genera uno nuevo.
+fn request_id_de(headers: &HeaderMap) -> String {
+    headers
+        .get("x-request-id")
+        .and_then(|v| v.to_str().ok())
+        .filter(|v| {
+            !v.is_empty()
+                && v.len() <= 128
+                && v.bytes()
+                    .all(|c| c.is_ascii_alphanumeric() || matches!(c, b'.' | b'_' | b'-'))
+        })
+        .map(str::to_string)
+        .unwrap_or_else(|| Uuid::new_v4().to_string())
+}
+
 async fn recibir_venta(
     State(state): State<AppState>,
+    ConnectInfo(peer): ConnectInfo<SocketAddr>,
+    headers: HeaderMap,
     Json(req): Json<ProductSaleRequest>,
 ) -> impl IntoResponse {
     if req.producto_id.trim().is_empty() {
@@ -93,16 +138,36 @@ async fn recibir_venta(
     }
 
     let total = state.total_ventas.fetch_add(1, Ordering::Relaxed) + 1;
-    let request_id = Uuid::new_v4().to_string();
+    // El mismo id viaja al Go client, al gRPC server y a Kafka (X-Request-ID)
+    let request_id = request_id_de(&headers);
 
     // Llamar al Go Client (HTTP)
     let url = format!("{}/ventas", state.go_client_url.trim_end_matches('/'));
 
+    // Credenciales e IP del cliente para la autenticación y los límites del Go client
+    let mut reenviar = HeaderMap::new();
+    for nombre in ["authorization", "x-api-key", "x-request-timeout"] {
+        if let Some(v) = headers.get(nombre) {
+            reenviar.insert(nombre, v.clone());
+        }
+    }
+    let xff = match headers.get("x-forwarded-for").and_then(|v| v.to_str().ok()) {
+        Some(previo) => format!("{previo}, {}", peer.ip()),
+        None => peer.ip().to_string(),
+    };
+    if let Ok(v) = xff.parse() {
+        reenviar.insert("x-forwarded-for", v);
+    }
+    if let Ok(v) = request_id.parse() {
+        reenviar.insert("x-request-id", v);
+    }
+
     let resp = state
         .http
         .post(url)
+        .headers(reenviar)
         .json(&req)
-        .timeout(std::time::Duration::from_secs(2))
+        .timeout(timeout_pedido(&headers))
         .send()
         .await;
 
@@ -115,12 +180,35 @@ async fn recibir_venta(
                 .unwrap_or("OK")
                 .to_string()
         }
+        Ok(r) if r.status().is_client_error() || r.status() == StatusCode::SERVICE_UNAVAILABLE => {
+            // Rechazos del Go client (auth, límites, stock, catálogo): se
+            // devuelven tal cual, con Retry-After / WWW-Authenticate
+            let status = r.status();
+            let mut copiar = HeaderMap::new();
+            for h in [
+                header::CONTENT_TYPE,
+                header::RETRY_AFTER,
+                header::WWW_AUTHENTICATE,
+            ] {
+                if let Some(v) = r.headers().get(&h) {
+                    copiar.insert(h, v.clone());
+                }
+            }
+            let cuerpo = r.text().await.unwrap_or_default();
+            warn!(
+                "Go client rechazó la venta id={}: HTTP {} {}",
+                request_id,
+                status,
+                cuerpo.trim()
+            );
+            return (status, copiar, cuerpo).into_response();
+        }
         Ok(r) => {
-            warn!("Go client respondió HTTP {}", r.status());
+            warn!("Go client respondió HTTP {} id={}", r.status(), request_id);
             return (StatusCode::BAD_GATEWAY, "Error llamando Go Client").into_response();
         }
         Err(e) => {
-            warn!("Error llamando Go Client: {}", e);
+            warn!("Error llamando Go Client id={}: {}", request_id, e);
             return (StatusCode::BAD_GATEWAY, "Error llamando Go Client").into_response();
         }
     };
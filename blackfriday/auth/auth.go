@@ -0,0 +1,139 @@
+// Package auth autentica los requests del gateway REST con API key o JWT y
+// devuelve el tenant (tienda) que hizo la venta y la credencial usada.
+//
+//	X-API-Key: <clave>            la clave se busca en el archivo de API keys
+//	Authorization: Bearer <jwt>   HS256 o RS256, verificado contra un JWKS local
+//
+// El JWT se valida a mano con la biblioteca estándar: firma según el kid del
+// header, exp/nbf con una tolerancia de reloj, y iss/aud si están
+// configurados. El tenant sale del claim configurado (por defecto "tenant").
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// MetadataTenant es la key de metadata gRPC con la que el gateway pasa el
+// tenant autenticado al gRPC server.
+const MetadataTenant = "x-tenant-id"
+
+// ErrSinCredenciales indica que el request no trae API key ni token.
+var ErrSinCredenciales = errors.New("faltan credenciales: enviar X-API-Key o Authorization: Bearer <jwt>")
+
+// Config es la configuración del autenticador.
+type Config struct {
+	APIKeysArchivo string // JSON {"<clave>": "<tenant>", ...}
+	JWKSArchivo    string // JWKS con claves RSA (RS256) u oct (HS256)
+	Issuer         string // iss esperado; vacío no se controla
+	Audience       string // aud esperado; vacío no se controla
+	ClaimTenant    string // claim con el tenant (por defecto "tenant")
+	Tolerancia     time.Duration
+}
+
+// Identidad es el resultado de autenticar un request.
+type Identidad struct {
+	Tenant string
+	// Credencial identifica la API key ("apikey:" + sha256 abreviado, para
+	// no guardar la clave) o el sujeto del JWT ("jwt:" + sub). Un tenant
+	// puede tener varias; el rate limit por cliente va por credencial.
+	Credencial string
+}
+
+// Autenticador valida API keys y JWT. Sin archivos configurados para un
+// método, ese método se rechaza.
+type Autenticador struct {
+	apiKeys map[string]string
+	claves  map[string]claveJWK // por kid
+	cfg     Config
+}
+
+// Nuevo carga los archivos de API keys y JWKS.
+func Nuevo(cfg Config) (*Autenticador, error) {
+	if cfg.ClaimTenant == "" {
+		cfg.ClaimTenant = "tenant"
+	}
+	if cfg.Tolerancia == 0 {
+		cfg.Tolerancia = 30 * time.Second
+	}
+	a := &Autenticador{cfg: cfg}
+	if cfg.APIKeysArchivo != "" {
+		b, err := os.ReadFile(cfg.APIKeysArchivo)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &a.apiKeys); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.APIKeysArchivo, err)
+		}
+		for k, t := range a.apiKeys {
+			if k == "" || t == "" {
+				return nil, fmt.Errorf("%s: clave o tenant vacío", cfg.APIKeysArchivo)
+			}
+		}
+	}
+	if cfg.JWKSArchivo != "" {
+		claves, err := cargarJWKS(cfg.JWKSArchivo)
+		if err != nil {
+			return nil, err
+		}
+		a.claves = claves
+	}
+	if a.apiKeys == nil && a.claves == nil {
+		return nil, errors.New("sin API keys ni JWKS configurados")
+	}
+	return a, nil
+}
+
+// Metodos describe qué métodos quedaron habilitados, para el log de arranque.
+func (a *Autenticador) Metodos() string {
+	var m []string
+	if a.apiKeys != nil {
+		m = append(m, fmt.Sprintf("apikey(%d)", len(a.apiKeys)))
+	}
+	if a.claves != nil {
+		m = append(m, fmt.Sprintf("jwt(%d claves)", len(a.claves)))
+	}
+	return strings.Join(m, ",")
+}
+
+// Autenticar devuelve la identidad del request o un error con el motivo,
+// apto para devolver al cliente.
+func (a *Autenticador) Autenticar(r *http.Request) (Identidad, error) {
+	if clave := r.Header.Get("X-API-Key"); clave != "" {
+		return a.porAPIKey(clave)
+	}
+	if h := r.Header.Get("Authorization"); h != "" {
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok {
+			return Identidad{}, errors.New("Authorization debe ser Bearer <jwt>")
+		}
+		return a.porJWT(strings.TrimSpace(token), time.Now())
+	}
+	return Identidad{}, ErrSinCredenciales
+}
+
+func (a *Autenticador) porAPIKey(clave string) (Identidad, error) {
+	if a.apiKeys == nil {
+		return Identidad{}, errors.New("API keys no habilitadas")
+	}
+	// comparación en tiempo constante contra todas, sin cortar en la primera
+	var tenant string
+	for k, t := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(clave)) == 1 {
+			tenant = t
+		}
+	}
+	if tenant == "" {
+		return Identidad{}, errors.New("API key inválida")
+	}
+	h := sha256.Sum256([]byte(clave))
+	return Identidad{Tenant: tenant, Credencial: "apikey:" + hex.EncodeToString(h[:8])}, nil
+}
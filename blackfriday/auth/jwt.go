@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// claveJWK es una clave del JWKS ya decodificada.
+type claveJWK struct {
+	alg  string // "RS256" o "HS256"
+	rsa  *rsa.PublicKey
+	hmac []byte
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+var b64 = base64.RawURLEncoding
+
+func cargarJWKS(path string) (map[string]claveJWK, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	claves := make(map[string]claveJWK, len(set.Keys))
+	for _, k := range set.Keys {
+		var c claveJWK
+		switch k.Kty {
+		case "RSA":
+			n, err1 := b64.DecodeString(k.N)
+			e, err2 := b64.DecodeString(k.E)
+			if err := errors.Join(err1, err2); err != nil || len(n) == 0 || len(e) == 0 {
+				return nil, fmt.Errorf("%s: clave RSA %q inválida", path, k.Kid)
+			}
+			c = claveJWK{alg: "RS256", rsa: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}}
+		case "oct":
+			s, err := b64.DecodeString(k.K)
+			if err != nil || len(s) < 32 {
+				return nil, fmt.Errorf("%s: clave HS256 %q inválida (mínimo 32 bytes)", path, k.Kid)
+			}
+			c = claveJWK{alg: "HS256", hmac: s}
+		default:
+			return nil, fmt.Errorf("%s: kty %q no soportado", path, k.Kty)
+		}
+		if k.Alg != "" && k.Alg != c.alg {
+			return nil, fmt.Errorf("%s: clave %q con alg %s y kty %s", path, k.Kid, k.Alg, k.Kty)
+		}
+		if _, dup := claves[k.Kid]; dup {
+			return nil, fmt.Errorf("%s: kid %q repetido", path, k.Kid)
+		}
+		claves[k.Kid] = c
+	}
+	if len(claves) == 0 {
+		return nil, fmt.Errorf("%s: JWKS sin claves", path)
+	}
+	return claves, nil
+}
+
+// porJWT verifica el token y devuelve el tenant. La credencial es el claim
+// sub; sin sub, el token se identifica por su tenant.
+func (a *Autenticador) porJWT(token string, ahora time.Time) (Identidad, error) {
+	if a.claves == nil {
+		return Identidad{}, errors.New("JWT no habilitado")
+	}
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return Identidad{}, errors.New("JWT mal formado")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodificar(partes[0], &header); err != nil {
+		return Identidad{}, fmt.Errorf("JWT: header inválido: %w", err)
+	}
+	clave, ok := a.claves[header.Kid]
+	if !ok {
+		return Identidad{}, fmt.Errorf("JWT: kid %q desconocido", header.Kid)
+	}
+	// el alg lo fija la clave, no el token (evita alg=none y confusión RS/HS)
+	if header.Alg != clave.alg {
+		return Identidad{}, fmt.Errorf("JWT: alg %q no corresponde a la clave %q", header.Alg, header.Kid)
+	}
+	firma, err := b64.DecodeString(partes[2])
+	if err != nil {
+		return Identidad{}, errors.New("JWT: firma mal codificada")
+	}
+	firmado := []byte(partes[0] + "." + partes[1])
+	switch clave.alg {
+	case "HS256":
+		m := hmac.New(sha256.New, clave.hmac)
+		m.Write(firmado)
+		if !hmac.Equal(m.Sum(nil), firma) {
+			return Identidad{}, errors.New("JWT: firma inválida")
+		}
+	case "RS256":
+		h := sha256.Sum256(firmado)
+		if err := rsa.VerifyPKCS1v15(clave.rsa, crypto.SHA256, h[:], firma); err != nil {
+			return Identidad{}, errors.New("JWT: firma inválida")
+		}
+	}
+
+	var claims map[string]any
+	if err := decodificar(partes[1], &claims); err != nil {
+		return Identidad{}, fmt.Errorf("JWT: claims inválidos: %w", err)
+	}
+	tol := a.cfg.Tolerancia
+	if exp, ok := claims["exp"].(float64); !ok {
+		return Identidad{}, errors.New("JWT: falta exp")
+	} else if ahora.After(time.Unix(int64(exp), 0).Add(tol)) {
+		return Identidad{}, errors.New("JWT: token vencido")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && ahora.Add(tol).Before(time.Unix(int64(nbf), 0)) {
+		return Identidad{}, errors.New("JWT: token todavía no válido")
+	}
+	if a.cfg.Issuer != "" && claims["iss"] != a.cfg.Issuer {
+		return Identidad{}, errors.New("JWT: iss inesperado")
+	}
+	if a.cfg.Audience != "" && !contieneAudience(claims["aud"], a.cfg.Audience) {
+		return Identidad{}, errors.New("JWT: aud inesperado")
+	}
+	tenant, _ := claims[a.cfg.ClaimTenant].(string)
+	if tenant == "" {
+		return Identidad{}, fmt.Errorf("JWT: falta el claim %q", a.cfg.ClaimTenant)
+	}
+	credencial := "tenant:" + tenant
+	if sub, _ := claims["sub"].(string); sub != "" {
+		credencial = "jwt:" + sub
+	}
+	return Identidad{Tenant: tenant, Credencial: credencial}, nil
+}
+
+func decodificar(parte string, v any) error {
+	b, err := b64.DecodeString(parte)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// contieneAudience acepta aud como string o lista de strings.
+func contieneAudience(aud any, esperado string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == esperado
+	case []any:
+		for _, x := range v {
+			if x == esperado {
+				return true
+			}
+		}
+	}
+	return false
+}
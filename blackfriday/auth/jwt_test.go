@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	secretoHS = []byte("0123456789abcdef0123456789abcdef")
+	ahora     = time.Unix(1_700_000_000, 0)
+)
+
+// claveRSA se genera una vez: 2048 bits tarda.
+var claveRSA = func() *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}()
+
+func escribirJSON(t *testing.T, nombre string, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), nombre)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func autenticador(t *testing.T, cfg Config) *Autenticador {
+	t.Helper()
+	cfg.JWKSArchivo = escribirJSON(t, "jwks.json", map[string]any{"keys": []map[string]string{
+		{"kid": "hs", "kty": "oct", "k": b64.EncodeToString(secretoHS)},
+		{"kid": "rs", "kty": "RSA", "alg": "RS256",
+			"n": b64.EncodeToString(claveRSA.N.Bytes()),
+			"e": b64.EncodeToString(big.NewInt(int64(claveRSA.E)).Bytes())},
+	}})
+	a, err := Nuevo(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// firmarHS y firmarRS firman el contenido "header.claims".
+func firmarHS(secreto []byte) func([]byte) []byte {
+	return func(b []byte) []byte {
+		m := hmac.New(sha256.New, secreto)
+		m.Write(b)
+		return m.Sum(nil)
+	}
+}
+
+func firmarRS(b []byte) []byte {
+	h := sha256.Sum256(b)
+	f, err := rsa.SignPKCS1v15(rand.Reader, claveRSA, crypto.SHA256, h[:])
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func jwt(header, claims map[string]any, firmar func([]byte) []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	firmado := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	var firma []byte
+	if firmar != nil {
+		firma = firmar([]byte(firmado))
+	}
+	return firmado + "." + b64.EncodeToString(firma)
+}
+
+// claims válidos respecto de ahora; los casos pisan lo que necesiten.
+func claims(extra map[string]any) map[string]any {
+	c := map[string]any{"tenant": "tienda-1", "exp": ahora.Add(time.Minute).Unix(), "iss": "emisor", "aud": "ventas"}
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+func TestPorJWT(t *testing.T) {
+	a := autenticador(t, Config{Issuer: "emisor", Audience: "ventas", Tolerancia: 30 * time.Second})
+	hs := map[string]any{"alg": "HS256", "kid": "hs"}
+	rs := map[string]any{"alg": "RS256", "kid": "rs"}
+	pubRSA := claveRSA.PublicKey.N.Bytes()
+
+	casos := []struct {
+		nombre string
+		token  string
+		error  string // vacío: se espera tenant-1
+	}{
+		{"HS256 válido", jwt(hs, claims(nil), firmarHS(secretoHS)), ""},
+		{"RS256 válido", jwt(rs, claims(nil), firmarRS), ""},
+		{"mal formado", "a.b", "mal formado"},
+		{"header inválido", "%%%." + strings.SplitN(jwt(hs, claims(nil), nil), ".", 2)[1], "header inválido"},
+
+		{"alg none", jwt(map[string]any{"alg": "none", "kid": "hs"}, claims(nil), nil), "no corresponde"},
+		{"alg none sin firma", strings.TrimSuffix(jwt(map[string]any{"alg": "none", "kid": "rs"}, claims(nil), nil), "."), "mal formado"},
+		{"HS256 con la clave pública RSA", jwt(map[string]any{"alg": "HS256", "kid": "rs"}, claims(nil), firmarHS(pubRSA)), "no corresponde"},
+		{"RS256 sobre clave HS", jwt(map[string]any{"alg": "RS256", "kid": "hs"}, claims(nil), firmarRS), "no corresponde"},
+
+		{"kid desconocido", jwt(map[string]any{"alg": "HS256", "kid": "otro"}, claims(nil), firmarHS(secretoHS)), "desconocido"},
+		{"sin kid", jwt(map[string]any{"alg": "HS256"}, claims(nil), firmarHS(secretoHS)), "desconocido"},
+		{"firma HS con otro secreto", jwt(hs, claims(nil), firmarHS([]byte("otro secreto de 32 bytes........"))), "firma inválida"},
+		{"firma RS alterada", jwt(rs, claims(nil), func(b []byte) []byte { f := firmarRS(b); f[0] ^= 1; return f }), "firma inválida"},
+		{"claims alterados", func() string {
+			p := strings.Split(jwt(hs, claims(nil), firmarHS(secretoHS)), ".")
+			c, _ := json.Marshal(claims(map[string]any{"tenant": "tienda-2"}))
+			return p[0] + "." + b64.EncodeToString(c) + "." + p[2]
+		}(), "firma inválida"},
+		{"firma mal codificada", jwt(hs, claims(nil), nil) + "%%", "mal codificada"},
+
+		{"sin exp", jwt(hs, claims(map[string]any{"exp": nil}), firmarHS(secretoHS)), "falta exp"},
+		{"vencido dentro de la tolerancia", jwt(hs, claims(map[string]any{"exp": ahora.Add(-20 * time.Second).Unix()}), firmarHS(secretoHS)), ""},
+		{"vencido fuera de la tolerancia", jwt(hs, claims(map[string]any{"exp": ahora.Add(-40 * time.Second).Unix()}), firmarHS(secretoHS)), "vencido"},
+		{"nbf dentro de la tolerancia", jwt(hs, claims(map[string]any{"nbf": ahora.Add(20 * time.Second).Unix()}), firmarHS(secretoHS)), ""},
+		{"nbf fuera de la tolerancia", jwt(hs, claims(map[string]any{"nbf": ahora.Add(40 * time.Second).Unix()}), firmarHS(secretoHS)), "todavía no válido"},
+
+		{"iss distinto", jwt(hs, claims(map[string]any{"iss": "otro"}), firmarHS(secretoHS)), "iss"},
+		{"sin iss", jwt(hs, claims(map[string]any{"iss": nil}), firmarHS(secretoHS)), "iss"},
+		{"aud en lista", jwt(hs, claims(map[string]any{"aud": []string{"otra", "ventas"}}), firmarHS(secretoHS)), ""},
+		{"aud en lista sin coincidencia", jwt(hs, claims(map[string]any{"aud": []string{"otra"}}), firmarHS(secretoHS)), "aud"},
+		{"aud distinto", jwt(hs, claims(map[string]any{"aud": "otra"}), firmarHS(secretoHS)), "aud"},
+		{"aud de otro tipo", jwt(hs, claims(map[string]any{"aud": 1}), firmarHS(secretoHS)), "aud"},
+
+		{"sin tenant", jwt(hs, claims(map[string]any{"tenant": nil}), firmarHS(secretoHS)), "falta el claim"},
+		{"tenant vacío", jwt(hs, claims(map[string]any{"tenant": ""}), firmarHS(secretoHS)), "falta el claim"},
+		{"tenant no string", jwt(hs, claims(map[string]any{"tenant": 7}), firmarHS(secretoHS)), "falta el claim"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			id, err := a.porJWT(c.token, ahora)
+			if c.error == "" {
+				if err != nil || id.Tenant != "tienda-1" {
+					t.Fatalf("tenant %q, err %v; esperaba tienda-1", id.Tenant, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.error) {
+				t.Fatalf("err %v; esperaba %q", err, c.error)
+			}
+		})
+	}
+}
+
+func TestPorJWTClaimTenant(t *testing.T) {
+	a := autenticador(t, Config{ClaimTenant: "tienda"})
+	token := jwt(map[string]any{"alg": "HS256", "kid": "hs"},
+		map[string]any{"tienda": "t-9", "exp": ahora.Add(time.Minute).Unix()}, firmarHS(secretoHS))
+	if id, err := a.porJWT(token, ahora); err != nil || id.Tenant != "t-9" {
+		t.Fatalf("tenant %q, err %v", id.Tenant, err)
+	}
+}
+
+func TestCargarJWKS(t *testing.T) {
+	casos := []struct {
+		nombre string
+		claves []map[string]string
+	}{
+		{"vacío", nil},
+		{"kty desconocido", []map[string]string{{"kid": "a", "kty": "EC"}}},
+		{"HS corta", []map[string]string{{"kid": "a", "kty": "oct", "k": b64.EncodeToString([]byte("corta"))}}},
+		{"alg que no corresponde", []map[string]string{{"kid": "a", "kty": "oct", "alg": "RS256", "k": b64.EncodeToString(secretoHS)}}},
+		{"RSA sin n", []map[string]string{{"kid": "a", "kty": "RSA", "e": "AQAB"}}},
+		{"kid repetido", []map[string]string{
+			{"kid": "a", "kty": "oct", "k": b64.EncodeToString(secretoHS)},
+			{"kid": "a", "kty": "oct", "k": b64.EncodeToString(secretoHS)},
+		}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			path := escribirJSON(t, "jwks.json", map[string]any{"keys": c.claves})
+			if _, err := cargarJWKS(path); err == nil {
+				t.Fatal("JWKS inválido aceptado")
+			}
+		})
+	}
+}
+
+func TestPorAPIKey(t *testing.T) {
+	a, err := Nuevo(Config{APIKeysArchivo: escribirJSON(t, "keys.json", map[string]string{
+		"clave-larga-uno": "tienda-1",
+		"clave-dos":       "tienda-2",
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	casos := []struct {
+		clave, tenant string
+	}{
+		{"clave-larga-uno", "tienda-1"},
+		{"clave-dos", "tienda-2"},
+		{"clave-larga", ""},     // prefijo de una clave válida
+		{"clave-dos-extra", ""}, // clave válida con sufijo
+		{"CLAVE-DOS", ""},       // distinta por mayúsculas
+		{"clave-dos\x00", ""},   // byte de más
+		{"otra", ""},
+	}
+	for _, c := range casos {
+		id, err := a.porAPIKey(c.clave)
+		if id.Tenant != c.tenant || (c.tenant == "") != (err != nil) {
+			t.Errorf("porAPIKey(%q) = %q, %v; esperaba %q", c.clave, id.Tenant, err, c.tenant)
+		}
+	}
+
+	// sin JWKS el Bearer se rechaza, y la API key tiene prioridad
+	r := httptest.NewRequest("POST", "/venta", nil)
+	r.Header.Set("Authorization", "Bearer x.y.z")
+	if _, err := a.Autenticar(r); err == nil {
+		t.Error("JWT aceptado sin JWKS")
+	}
+	r.Header.Set("X-API-Key", "clave-dos")
+	if id, err := a.Autenticar(r); err != nil || id.Tenant != "tienda-2" {
+		t.Errorf("Autenticar con API key: %q, %v", id.Tenant, err)
+	}
+	if _, err := a.Autenticar(httptest.NewRequest("POST", "/venta", nil)); err != ErrSinCredenciales {
+		t.Errorf("sin credenciales: %v", err)
+	}
+}
+
+func TestCredencial(t *testing.T) {
+	a := autenticador(t, Config{})
+	keys, err := Nuevo(Config{APIKeysArchivo: escribirJSON(t, "keys.json", map[string]string{
+		"clave-a": "tienda-1",
+		"clave-b": "tienda-1",
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credencial := func(id Identidad, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id.Credencial
+	}
+	hs := map[string]any{"alg": "HS256", "kid": "hs"}
+	subA := credencial(a.porJWT(jwt(hs, claims(map[string]any{"sub": "caja-1"}), firmarHS(secretoHS)), ahora))
+	subA2 := credencial(a.porJWT(jwt(hs, claims(map[string]any{"sub": "caja-1", "exp": ahora.Add(time.Hour).Unix()}), firmarHS(secretoHS)), ahora))
+	subB := credencial(a.porJWT(jwt(hs, claims(map[string]any{"sub": "caja-2"}), firmarHS(secretoHS)), ahora))
+	sinSub := credencial(a.porJWT(jwt(hs, claims(nil), firmarHS(secretoHS)), ahora))
+	claveA := credencial(keys.porAPIKey("clave-a"))
+	claveB := credencial(keys.porAPIKey("clave-b"))
+
+	if subA != "jwt:caja-1" || subA != subA2 {
+		t.Errorf("tokens del mismo sub: %q y %q", subA, subA2)
+	}
+	if subA == subB {
+		t.Error("dos sub del mismo tenant comparten credencial")
+	}
+	if sinSub != "tenant:tienda-1" {
+		t.Errorf("sin sub: %q", sinSub)
+	}
+	if claveA == claveB {
+		t.Error("dos API keys del mismo tenant comparten credencial")
+	}
+	if strings.Contains(claveA, "clave-a") {
+		t.Errorf("la credencial %q expone la API key", claveA)
+	}
+}
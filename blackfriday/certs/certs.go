@@ -0,0 +1,190 @@
+// Package certs arma las credenciales TLS del gRPC server y del cliente Go
+// desde archivos PEM y las recarga cuando cambian, sin reiniciar el proceso.
+//
+// Los archivos se releen cada intervalo y se comparan por contenido (no por
+// mtime), así funciona con los Secrets montados de Kubernetes, que se
+// actualizan cambiando el symlink ..data. Si un archivo nuevo no se puede
+// parsear (por ejemplo, cert ya escrito y key todavía no) se sigue usando el
+// par anterior y se reintenta en la siguiente vuelta.
+//
+// Las conexiones ya establecidas siguen con el certificado del handshake;
+// los nuevos valen para las conexiones siguientes.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Archivos son las rutas PEM. Cert y Key van juntos; CA es opcional: en el
+// server activa mTLS (exige certificado de cliente firmado por esa CA) y en
+// el cliente reemplaza a las CA del sistema para verificar al server.
+type Archivos struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Recargador mantiene el par certificado/clave y el pool de CA vigentes.
+type Recargador struct {
+	arch Archivos
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	ca    *x509.CertPool
+	crudo [3][]byte // contenido leído la última vez: cert, key, ca
+}
+
+// Nuevo lee los archivos por primera vez; un error acá es fatal para el
+// llamador (config inválida), a diferencia de las recargas posteriores.
+func Nuevo(a Archivos) (*Recargador, error) {
+	if (a.Cert == "") != (a.Key == "") {
+		return nil, errors.New("certificado y clave van juntos")
+	}
+	r := &Recargador{arch: a}
+	if _, err := r.recargar(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Vigilar relee los archivos cada intervalo hasta que se cancele ctx.
+func (r *Recargador) Vigilar(ctx context.Context, intervalo time.Duration) {
+	t := time.NewTicker(intervalo)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			cambio, err := r.recargar()
+			if err != nil {
+				slog.Warn("TLS: recarga fallida, sigo con los certificados anteriores", "err", err)
+			} else if cambio {
+				slog.Info("TLS: certificados recargados", "cert", r.arch.Cert, "ca", r.arch.CA)
+			}
+		}
+	}
+}
+
+func (r *Recargador) recargar() (bool, error) {
+	var nuevo [3][]byte
+	for i, path := range []string{r.arch.Cert, r.arch.Key, r.arch.CA} {
+		if path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		nuevo[i] = b
+	}
+
+	r.mu.RLock()
+	igual := bytes.Equal(nuevo[0], r.crudo[0]) && bytes.Equal(nuevo[1], r.crudo[1]) && bytes.Equal(nuevo[2], r.crudo[2])
+	r.mu.RUnlock()
+	if igual {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if r.arch.Cert != "" {
+		c, err := tls.X509KeyPair(nuevo[0], nuevo[1])
+		if err != nil {
+			return false, fmt.Errorf("%s / %s: %w", r.arch.Cert, r.arch.Key, err)
+		}
+		cert = &c
+	}
+	var ca *x509.CertPool
+	if r.arch.CA != "" {
+		ca = x509.NewCertPool()
+		if !ca.AppendCertsFromPEM(nuevo[2]) {
+			return false, fmt.Errorf("%s: sin certificados PEM", r.arch.CA)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.ca, r.crudo = cert, ca, nuevo
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *Recargador) actuales() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.ca
+}
+
+// ConfigServidor es la tls.Config del gRPC server. Con CA exige y verifica
+// el certificado del cliente (mTLS).
+func (r *Recargador) ConfigServidor() (*tls.Config, error) {
+	if r.arch.Cert == "" {
+		return nil, errors.New("el server necesita certificado y clave")
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// cada handshake toma el certificado y la CA vigentes
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, ca := r.actuales()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if ca != nil {
+				cfg.ClientCAs = ca
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}, nil
+}
+
+// ConfigCliente es la tls.Config del cliente gRPC. serverName vacío usa el
+// host de la dirección de destino. Con certificado propio lo presenta al
+// server (mTLS).
+func (r *Recargador) ConfigCliente(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.actuales()
+			if cert == nil {
+				return &tls.Certificate{}, nil // sin certificado: el server decide
+			}
+			return cert, nil
+		},
+	}
+	if r.arch.CA == "" {
+		return cfg // CA del sistema
+	}
+
+	// RootCAs es fijo en tls.Config; para que la CA se pueda recargar la
+	// verificación del server se hace a mano con el pool vigente.
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		_, ca := r.actuales()
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("el server no presentó certificado")
+		}
+		inter := x509.NewCertPool()
+		for _, c := range cs.PeerCertificates[1:] {
+			inter.AddCert(c)
+		}
+		_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         ca,
+			Intermediates: inter,
+		})
+		return err
+	}
+	return cfg
+}
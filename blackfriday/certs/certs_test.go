@@ -0,0 +1,255 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// emisor es una CA de prueba.
+type emisor struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func nuevaCA(t *testing.T, nombre string) *emisor {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: nombre},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &emisor{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// firmar emite un certificado de hoja para localhost con el serial dado y
+// devuelve cert y key en PEM.
+func (e *emisor) firmar(t *testing.T, serial int64, uso x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{uso},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, e.cert, &key.PublicKey, e.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+}
+
+func escribir(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servir acepta conexiones TLS y completa el handshake hasta que se cierre
+// el listener.
+func servir(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				if c.(*tls.Conn).Handshake() == nil {
+					c.Write([]byte("ok"))
+				}
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// conectar abre una conexión y devuelve el serial del certificado del
+// server. En TLS 1.3 el rechazo del certificado de cliente llega después del
+// handshake, por eso se lee la respuesta.
+func conectar(addr string, cfg *tls.Config) (int64, error) {
+	c, err := tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr, cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 2)
+	if _, err := c.Read(buf); err != nil {
+		return 0, err
+	}
+	return c.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestMTLSYRotacion(t *testing.T) {
+	dir := t.TempDir()
+	ca := nuevaCA(t, "ca de prueba")
+	srvCert, srvKey := ca.firmar(t, 100, x509.ExtKeyUsageServerAuth)
+	cliCert, cliKey := ca.firmar(t, 200, x509.ExtKeyUsageClientAuth)
+
+	arch := func(n string) string { return filepath.Join(dir, n) }
+	escribir(t, arch("ca.pem"), ca.pem)
+	escribir(t, arch("server.pem"), srvCert)
+	escribir(t, arch("server.key"), srvKey)
+	escribir(t, arch("cliente.pem"), cliCert)
+	escribir(t, arch("cliente.key"), cliKey)
+
+	srv, err := Nuevo(Archivos{Cert: arch("server.pem"), Key: arch("server.key"), CA: arch("ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvCfg, err := srv.ConfigServidor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := servir(t, srvCfg)
+
+	cli, err := Nuevo(Archivos{Cert: arch("cliente.pem"), Key: arch("cliente.key"), CA: arch("ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial, err := conectar(addr, cli.ConfigCliente("localhost")); err != nil || serial != 100 {
+		t.Fatalf("mTLS: serial %d, err %v; esperaba 100", serial, err)
+	}
+
+	// sin certificado de cliente el server corta
+	sinCert, err := Nuevo(Archivos{CA: arch("ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conectar(addr, sinCert.ConfigCliente("localhost")); err == nil {
+		t.Fatal("conexión sin certificado de cliente aceptada")
+	}
+
+	// nombre que no está en el certificado
+	if _, err := conectar(addr, cli.ConfigCliente("otro.example")); err == nil {
+		t.Fatal("conexión con ServerName equivocado aceptada")
+	}
+
+	// sin cambios no hay recarga
+	if cambio, err := srv.recargar(); cambio || err != nil {
+		t.Fatalf("recargar sin cambios: cambio=%v err=%v", cambio, err)
+	}
+
+	// rotación a medias: cert nuevo con key vieja no se aplica
+	srvCert2, srvKey2 := ca.firmar(t, 101, x509.ExtKeyUsageServerAuth)
+	escribir(t, arch("server.pem"), srvCert2)
+	if _, err := srv.recargar(); err == nil {
+		t.Fatal("recargar aceptó cert y key que no se corresponden")
+	}
+	if serial, err := conectar(addr, cli.ConfigCliente("localhost")); err != nil || serial != 100 {
+		t.Fatalf("tras rotación a medias: serial %d, err %v; esperaba 100", serial, err)
+	}
+
+	// rotación completa: las conexiones nuevas ven el certificado nuevo
+	escribir(t, arch("server.key"), srvKey2)
+	if cambio, err := srv.recargar(); !cambio || err != nil {
+		t.Fatalf("recargar: cambio=%v err=%v", cambio, err)
+	}
+	if serial, err := conectar(addr, cli.ConfigCliente("localhost")); err != nil || serial != 101 {
+		t.Fatalf("tras rotar: serial %d, err %v; esperaba 101", serial, err)
+	}
+}
+
+func TestRotacionCA(t *testing.T) {
+	dir := t.TempDir()
+	arch := func(n string) string { return filepath.Join(dir, n) }
+	vieja, nueva := nuevaCA(t, "ca vieja"), nuevaCA(t, "ca nueva")
+
+	srvCert, srvKey := nueva.firmar(t, 300, x509.ExtKeyUsageServerAuth)
+	escribir(t, arch("server.pem"), srvCert)
+	escribir(t, arch("server.key"), srvKey)
+	srv, err := Nuevo(Archivos{Cert: arch("server.pem"), Key: arch("server.key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvCfg, err := srv.ConfigServidor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := servir(t, srvCfg)
+
+	// el cliente todavía confía solo en la CA vieja
+	escribir(t, arch("ca.pem"), vieja.pem)
+	cli, err := Nuevo(Archivos{CA: arch("ca.pem")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conectar(addr, cli.ConfigCliente("localhost")); err == nil {
+		t.Fatal("server firmado por una CA desconocida aceptado")
+	}
+
+	// la recarga de la CA vale para las conexiones siguientes
+	escribir(t, arch("ca.pem"), append(vieja.pem, nueva.pem...))
+	if cambio, err := cli.recargar(); !cambio || err != nil {
+		t.Fatalf("recargar CA: cambio=%v err=%v", cambio, err)
+	}
+	if serial, err := conectar(addr, cli.ConfigCliente("localhost")); err != nil || serial != 300 {
+		t.Fatalf("tras recargar la CA: serial %d, err %v; esperaba 300", serial, err)
+	}
+}
+
+func TestNuevoErrores(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Nuevo(Archivos{Cert: filepath.Join(dir, "a.pem")}); err == nil {
+		t.Error("cert sin key aceptado")
+	}
+	if _, err := Nuevo(Archivos{Cert: filepath.Join(dir, "a.pem"), Key: filepath.Join(dir, "a.key")}); err == nil {
+		t.Error("archivos inexistentes aceptados")
+	}
+	escribir(t, filepath.Join(dir, "vacia.pem"), []byte("nada"))
+	if _, err := Nuevo(Archivos{CA: filepath.Join(dir, "vacia.pem")}); err == nil {
+		t.Error("CA sin PEM aceptada")
+	}
+	sinCert, err := Nuevo(Archivos{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sinCert.ConfigServidor(); err == nil {
+		t.Error("ConfigServidor sin certificado")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"comun/config"
+)
+
+// Conexión al gRPC server con service config:
+//
+//   - Balanceo round_robin entre todas las réplicas. Para que el resolver
+//     DNS vea cada pod, GRPC_SERVER_ADDR tiene que apuntar al Service
+//     headless: dns:///grpc-server-headless:50051. Contra un ClusterIP hay
+//     una sola IP y todo el tráfico de este pod va a un único backend.
+//   - Reintentos ante UNAVAILABLE con backoff exponencial y throttling (si
+//     más del 10% de las llamadas falla, se dejan de reintentar).
+//   - Hedging opcional: manda otra copia si la primera no respondió en
+//     GRPC_HEDGING. ProcesarVenta no es idempotente (reserva stock y publica
+//     en Kafka), así que una copia de más es una venta duplicada; por eso
+//     viene apagado. Con hedging no hay reintentos.
+//
+// Configuración:
+//
+//	GRPC_LB               round_robin (por defecto) o pick_first
+//	GRPC_REINTENTOS       intentos máximos incluido el primero (por defecto 3; 1 sin reintentos)
+//	GRPC_HEDGING          demora entre copias (ej. 100ms); vacío sin hedging
+//	GRPC_HEDGING_COPIAS   copias máximas incluida la primera (por defecto 2)
+//	GRPC_TIMEOUT          timeout por request (por defecto 2s)
+//	GRPC_TIMEOUT_MAX      tope para X-Request-Timeout (por defecto 10s)
+
+// servicioVentas es el servicio al que se aplica la política.
+const servicioVentas = "blackfriday.ProductSaleService"
+
+func serviceConfigDesdeEnv() (string, error) {
+	lb := os.Getenv("GRPC_LB")
+	if lb == "" {
+		lb = "round_robin"
+	}
+	if lb != "round_robin" && lb != "pick_first" {
+		return "", fmt.Errorf("GRPC_LB: %q, se espera round_robin o pick_first", lb)
+	}
+
+	metodo := map[string]any{
+		"name": []map[string]string{{"service": servicioVentas}},
+	}
+	if v := os.Getenv("GRPC_HEDGING"); v != "" {
+		demora, err := time.ParseDuration(v)
+		if err != nil || demora <= 0 {
+			return "", fmt.Errorf("GRPC_HEDGING: duración inválida %q", v)
+		}
+		copias, err := intEntre("GRPC_HEDGING_COPIAS", 2, 2, 5)
+		if err != nil {
+			return "", err
+		}
+		metodo["hedgingPolicy"] = map[string]any{
+			"maxAttempts":         copias,
+			"hedgingDelay":        segundos(demora),
+			"nonFatalStatusCodes": []string{"UNAVAILABLE"},
+		}
+	} else {
+		intentos, err := intEntre("GRPC_REINTENTOS", 3, 1, 5)
+		if err != nil {
+			return "", err
+		}
+		if intentos > 1 {
+			metodo["retryPolicy"] = map[string]any{
+				"maxAttempts":          intentos,
+				"initialBackoff":       "0.05s",
+				"maxBackoff":           "0.5s",
+				"backoffMultiplier":    2,
+				"retryableStatusCodes": []string{"UNAVAILABLE"},
+			}
+		}
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"loadBalancingConfig": []map[string]any{{lb: map[string]any{}}},
+		"methodConfig":        []any{metodo},
+		"retryThrottling":     map[string]any{"maxTokens": 10, "tokenRatio": 0.1},
+	})
+	return string(b), err
+}
+
+// intEntre lee un entero de k dentro de [min, max] (gRPC limita los intentos a 5).
+func intEntre(k string, def, min, max int) (int, error) {
+	v := os.Getenv(k)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s: %q fuera de rango [%d, %d]", k, v, min, max)
+	}
+	return n, nil
+}
+
+// segundos formatea una duración como la espera el service config ("0.1s").
+func segundos(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// conectarGRPC abre la conexión con el service config y las opciones dadas.
+func conectarGRPC(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	sc, err := serviceConfigDesdeEnv()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(addr, "dns:///") {
+		slog.Warn("gRPC: dirección sin dns:///, el balanceo queda en un solo backend", "addr", addr)
+	}
+	slog.Info("gRPC service config", "config", sc)
+	opts = append(opts, grpc.WithDefaultServiceConfig(sc))
+	return grpc.Dial(addr, opts...)
+}
+
+// timeouts es el timeout por defecto de cada request y el tope que se acepta
+// en X-Request-Timeout.
+type timeouts struct {
+	def, max time.Duration
+}
+
+func timeoutsDesdeEnv() timeouts {
+	t := timeouts{
+		def: config.LeerDuracion("GRPC_TIMEOUT", 2*time.Second),
+		max: config.LeerDuracion("GRPC_TIMEOUT_MAX", 10*time.Second),
+	}
+	t.def = min(t.def, t.max)
+	return t
+}
+
+// delPedido devuelve el timeout del request: X-Request-Timeout en
+// milisegundos ("500") o como duración ("500ms", "2s"), recortado al tope.
+func (t timeouts) delPedido(r *http.Request) (time.Duration, error) {
+	v := strings.TrimSpace(r.Header.Get("X-Request-Timeout"))
+	if v == "" {
+		return t.def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ms, errMs := strconv.ParseInt(v, 10, 64)
+		if errMs != nil {
+			return 0, fmt.Errorf("X-Request-Timeout inválido: %q", v)
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("X-Request-Timeout debe ser positivo: %q", v)
+	}
+	return min(d, t.max), nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "comun/config"
+
+// opciones es toda la configuración del gateway REST (ver paquete config:
+// archivo, entorno o flags).
+var opciones = []config.Opcion{
+	{Clave: "HTTP_ADDR", Tipo: config.Direccion, Defecto: ":8081", Desc: "dirección del REST /ventas, /health y /debug/vars"},
+	{Clave: "GRPC_SERVER_ADDR", Defecto: "localhost:50051", Desc: "gRPC server; dns:///<service headless>:50051 para balancear"},
+	{Clave: "GRPC_LB", Defecto: "round_robin", Valores: []string{"round_robin", "pick_first"}, Desc: "balanceo entre réplicas"},
+	{Clave: "GRPC_REINTENTOS", Tipo: config.Entero, Defecto: "3", Positivo: true, Desc: "intentos ante UNAVAILABLE, incluido el primero (1-5)"},
+	{Clave: "GRPC_HEDGING", Tipo: config.Duracion, Positivo: true, Desc: "demora entre copias con hedging (vacío: sin hedging)"},
+	{Clave: "GRPC_HEDGING_COPIAS", Tipo: config.Entero, Defecto: "2", Positivo: true, Desc: "copias con hedging, incluida la primera (2-5)"},
+	{Clave: "GRPC_TIMEOUT", Tipo: config.Duracion, Defecto: "2s", Positivo: true, Desc: "timeout por request"},
+	{Clave: "GRPC_TIMEOUT_MAX", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "tope para X-Request-Timeout"},
+
+	{Clave: "GRPC_TLS", Tipo: config.Bool, Defecto: "false", Desc: "TLS con las CA del sistema"},
+	{Clave: "GRPC_TLS_CA", Desc: "CA para verificar al server"},
+	{Clave: "GRPC_TLS_CERT", Desc: "certificado de cliente (mTLS)"},
+	{Clave: "GRPC_TLS_KEY", Desc: "clave del certificado de cliente"},
+	{Clave: "GRPC_TLS_SERVER_NAME", Desc: "nombre esperado en el certificado del server"},
+	{Clave: "GRPC_TLS_RELOAD", Tipo: config.Duracion, Defecto: "30s", Positivo: true, Desc: "cada cuánto se releen los certificados"},
+
+	{Clave: "GRPC_INTERCEPTORES", Desc: "interceptores: log,metricas,recuperar o ninguno (vacío: todos)"},
+	{Clave: "GRPC_LOG", Defecto: "todo", Valores: []string{"todo", "errores"}, Desc: "qué llamadas gRPC se loguean"},
+
+	{Clave: "AUTH_API_KEYS", Desc: `archivo JSON {"<clave>": "<tenant>"} (activa auth)`},
+	{Clave: "AUTH_JWKS", Desc: "JWKS local para JWT RS256/HS256 (activa auth)"},
+	{Clave: "AUTH_JWT_ISSUER", Desc: "iss esperado"},
+	{Clave: "AUTH_JWT_AUDIENCE", Desc: "aud esperado"},
+	{Clave: "AUTH_JWT_TENANT_CLAIM", Defecto: "tenant", Desc: "claim con el tenant"},
+
+	{Clave: "RATE_GLOBAL", Tipo: config.Decimal, Defecto: "0", Desc: "requests/s de todo el gateway (0: sin límite)"},
+	{Clave: "RATE_GLOBAL_BURST", Tipo: config.Decimal, Desc: "ráfaga global (vacío: igual a la tasa)"},
+	{Clave: "RATE_IP", Tipo: config.Decimal, Defecto: "0", Desc: "requests/s por IP (0: sin límite)"},
+	{Clave: "RATE_IP_BURST", Tipo: config.Decimal, Desc: "ráfaga por IP"},
+	{Clave: "RATE_IP_HEADER", Desc: "header con la IP real, ej. X-Forwarded-For"},
+	{Clave: "RATE_IP_PROXIES", Tipo: config.Entero, Defecto: "1", Positivo: true, Desc: "proxies de confianza que agregan a RATE_IP_HEADER; se usa la N-ésima IP desde la derecha"},
+	{Clave: "RATE_CREDENCIAL", Tipo: config.Decimal, Defecto: "0", Desc: "requests/s por API key o sujeto de JWT (0: sin límite)"},
+	{Clave: "RATE_CREDENCIAL_BURST", Tipo: config.Decimal, Desc: "ráfaga por credencial"},
+	{Clave: "CONC_INICIAL", Tipo: config.Decimal, Defecto: "100", Positivo: true, Desc: "límite inicial de llamadas gRPC en vuelo"},
+	{Clave: "CONC_MIN", Tipo: config.Decimal, Defecto: "10", Positivo: true, Desc: "límite mínimo de llamadas en vuelo"},
+	{Clave: "CONC_MAX", Tipo: config.Decimal, Defecto: "1000", Desc: "límite máximo de llamadas en vuelo (0: sin límite adaptativo)"},
+	{Clave: "CONC_LATENCIA_OBJETIVO", Tipo: config.Duracion, Defecto: "250ms", Positivo: true, Desc: "latencia por encima de la cual baja el límite"},
+
+	{Clave: "LOG_LEVEL", Defecto: "info", Valores: []string{"debug", "info", "warn", "error"}, Desc: "nivel de log"},
+	{Clave: "LOG_FORMAT", Defecto: "json", Valores: []string{"json", "texto", "text"}, Desc: "formato de log"},
+	{Clave: "LOG_MUESTREO_OK", Tipo: config.Entero, Defecto: "100", Positivo: true, Desc: "se loguea 1 de cada N llamadas OK"},
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"comun/config"
+)
+
+// Protección del gateway ante picos (Locust):
+//
+//   - Rate limit con token bucket: global, por IP de cliente y por
+//     credencial autenticada (cada API key o sujeto de JWT por separado,
+//     aunque sean del mismo tenant). Excedido => 429 con Retry-After.
+//   - Límite de concurrencia adaptativo (AIMD) sobre las llamadas gRPC: si
+//     la latencia supera el objetivo o el server responde Unavailable /
+//     DeadlineExceeded, el límite baja un 10% (como mucho una vez por
+//     ventana); cada respuesta rápida lo sube 1/límite. Lo que excede el
+//     límite se descarta enseguida con 503 y Retry-After, en vez de
+//     encolarse hasta el timeout.
+//
+// Configuración (0 desactiva el limitador correspondiente):
+//
+//	RATE_GLOBAL, RATE_GLOBAL_BURST          requests/s y ráfaga de todo el gateway
+//	RATE_IP, RATE_IP_BURST                  por IP de cliente
+//	RATE_IP_HEADER                          header con la IP real (ej. X-Forwarded-For)
+//	RATE_IP_PROXIES                         proxies de confianza que agregan al header (por defecto 1)
+//	RATE_CREDENCIAL, RATE_CREDENCIAL_BURST  por API key o sujeto de JWT
+//	CONC_INICIAL, CONC_MIN, CONC_MAX        límite de llamadas gRPC en vuelo
+//	CONC_LATENCIA_OBJETIVO                  latencia gRPC por encima de la cual se reduce (por defecto 250ms)
+//
+// Todo se publica en /debug/vars bajo "limites".
+
+var metricasLimites = expvar.NewMap("limites")
+
+// cubeta es un token bucket.
+type cubeta struct {
+	tasa, rafaga float64
+	tokens       float64
+	ultimo       time.Time
+}
+
+func nuevaCubeta(tasa, rafaga float64, ahora time.Time) *cubeta {
+	return &cubeta{tasa: tasa, rafaga: rafaga, tokens: rafaga, ultimo: ahora}
+}
+
+// tomar consume un token; si no hay, devuelve cuánto falta para el próximo.
+func (c *cubeta) tomar(ahora time.Time) (bool, time.Duration) {
+	c.tokens = math.Min(c.rafaga, c.tokens+ahora.Sub(c.ultimo).Seconds()*c.tasa)
+	c.ultimo = ahora
+	if c.tokens >= 1 {
+		c.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - c.tokens) / c.tasa * float64(time.Second))
+}
+
+// cubetasPorClave es un token bucket por clave (IP o credencial). Las claves sin
+// uso por más de inactividad se descartan.
+type cubetasPorClave struct {
+	tasa, rafaga float64
+	inactividad  time.Duration
+
+	mu      sync.Mutex
+	cubetas map[string]*cubeta
+	barrido time.Time
+}
+
+func (p *cubetasPorClave) tomar(clave string, ahora time.Time) (bool, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ahora.Sub(p.barrido) > p.inactividad {
+		for k, c := range p.cubetas {
+			if ahora.Sub(c.ultimo) > p.inactividad {
+				delete(p.cubetas, k)
+			}
+		}
+		p.barrido = ahora
+	}
+	c, ok := p.cubetas[clave]
+	if !ok {
+		c = nuevaCubeta(p.tasa, p.rafaga, ahora)
+		p.cubetas[clave] = c
+	}
+	return c.tomar(ahora)
+}
+
+type limitador struct {
+	global   *cubeta
+	muGlobal sync.Mutex
+	porIP    *cubetasPorClave
+	porCred  *cubetasPorClave
+	ipHeader string
+	proxies  int // proxies de confianza delante del gateway (RATE_IP_PROXIES)
+
+	conc *concurrenciaAdaptativa
+}
+
+func limitadorDesdeEnv() *limitador {
+	ahora := time.Now()
+	l := &limitador{ipHeader: os.Getenv("RATE_IP_HEADER"), proxies: config.LeerEntero("RATE_IP_PROXIES", 1)}
+	if tasa := config.LeerDecimal("RATE_GLOBAL", 0); tasa > 0 {
+		l.global = nuevaCubeta(tasa, config.LeerDecimal("RATE_GLOBAL_BURST", tasa), ahora)
+	}
+	if tasa := config.LeerDecimal("RATE_IP", 0); tasa > 0 {
+		l.porIP = &cubetasPorClave{tasa: tasa, rafaga: config.LeerDecimal("RATE_IP_BURST", tasa), inactividad: 10 * time.Minute, cubetas: make(map[string]*cubeta)}
+	}
+	if tasa := config.LeerDecimal("RATE_CREDENCIAL", 0); tasa > 0 {
+		l.porCred = &cubetasPorClave{tasa: tasa, rafaga: config.LeerDecimal("RATE_CREDENCIAL_BURST", tasa), inactividad: 10 * time.Minute, cubetas: make(map[string]*cubeta)}
+	}
+	if max := config.LeerDecimal("CONC_MAX", 1000); max > 0 {
+		l.conc = nuevaConcurrencia(
+			config.LeerDecimal("CONC_INICIAL", 100),
+			config.LeerDecimal("CONC_MIN", 10),
+			max,
+			config.LeerDuracion("CONC_LATENCIA_OBJETIVO", 250*time.Millisecond),
+		)
+	}
+	slog.Info("límites",
+		"global", tasaTexto(l.global != nil, "RATE_GLOBAL"), "ip", tasaTexto(l.porIP != nil, "RATE_IP"),
+		"credencial", tasaTexto(l.porCred != nil, "RATE_CREDENCIAL"), "concurrencia", l.conc != nil)
+	return l
+}
+
+func tasaTexto(activo bool, env string) string {
+	if !activo {
+		return "off"
+	}
+	return os.Getenv(env) + "/s"
+}
+
+// antesDeAuth aplica los límites global y por IP; devuelve false si ya
+// respondió 429.
+func (l *limitador) antesDeAuth(w http.ResponseWriter, r *http.Request) bool {
+	ahora := time.Now()
+	if l.global != nil {
+		l.muGlobal.Lock()
+		ok, espera := l.global.tomar(ahora)
+		l.muGlobal.Unlock()
+		if !ok {
+			return rechazar(w, http.StatusTooManyRequests, "global", espera)
+		}
+	}
+	if l.porIP != nil {
+		if ok, espera := l.porIP.tomar(l.ipCliente(r), ahora); !ok {
+			return rechazar(w, http.StatusTooManyRequests, "ip", espera)
+		}
+	}
+	return true
+}
+
+// porCredencialOK aplica el límite de la credencial autenticada
+// (auth.Identidad.Credencial).
+func (l *limitador) porCredencialOK(w http.ResponseWriter, credencial string) bool {
+	if l.porCred == nil || credencial == "" {
+		return true
+	}
+	if ok, espera := l.porCred.tomar(credencial, time.Now()); !ok {
+		return rechazar(w, http.StatusTooManyRequests, "credencial", espera)
+	}
+	return true
+}
+
+// ipCliente devuelve la IP con la que se limita por IP. En
+// "X-Forwarded-For: a, b, c" cada proxy agrega al final la dirección desde
+// la que le llegó el request, y el cliente puede mandar lo que quiera al
+// principio: se toma la entrada que agregó el proxy de confianza más lejano,
+// la N-ésima desde la derecha con N = RATE_IP_PROXIES. Con menos entradas
+// que proxies se usa la primera.
+func (l *limitador) ipCliente(r *http.Request) string {
+	if l.ipHeader != "" {
+		var ips []string
+		for _, v := range r.Header.Values(l.ipHeader) {
+			for _, ip := range strings.Split(v, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					ips = append(ips, ip)
+				}
+			}
+		}
+		if len(ips) > 0 {
+			return ips[max(len(ips)-max(l.proxies, 1), 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func rechazar(w http.ResponseWriter, code int, motivo string, espera time.Duration) bool {
+	metricasLimites.Add("rechazadas_"+motivo, 1)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(espera.Seconds(), 1)))))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	estado := "LIMITE_EXCEDIDO"
+	if code == http.StatusServiceUnavailable {
+		estado = "SOBRECARGA"
+	}
+	w.Write([]byte(`{"estado":"` + estado + `","limite":"` + motivo + `"}` + "\n"))
+	return false
+}
+
+// concurrenciaAdaptativa es un límite AIMD de llamadas en vuelo.
+type concurrenciaAdaptativa struct {
+	objetivo time.Duration
+	min, max float64
+
+	mu          sync.Mutex
+	limite      float64
+	enVuelo     int
+	ultimaBaja  time.Time
+	latenciaEWM float64 // ms, solo para métricas
+}
+
+func nuevaConcurrencia(inicial, min, max float64, objetivo time.Duration) *concurrenciaAdaptativa {
+	c := &concurrenciaAdaptativa{objetivo: objetivo, min: min, max: max, limite: math.Min(math.Max(inicial, min), max)}
+	metricasLimites.Set("concurrencia", expvar.Func(func() any {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return map[string]any{"limite": int(c.limite), "en_vuelo": c.enVuelo, "latencia_ms": math.Round(c.latenciaEWM*10) / 10}
+	}))
+	return c
+}
+
+// entrar reserva un lugar; false si el límite está lleno.
+func (c *concurrenciaAdaptativa) entrar() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if float64(c.enVuelo) >= math.Floor(c.limite) {
+		return false
+	}
+	c.enVuelo++
+	return true
+}
+
+// salir libera el lugar y ajusta el límite según la latencia y si el
+// server estaba saturado.
+func (c *concurrenciaAdaptativa) salir(latencia time.Duration, saturado bool, ahora time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enVuelo--
+	ms := float64(latencia) / float64(time.Millisecond)
+	if c.latenciaEWM == 0 {
+		c.latenciaEWM = ms
+	} else {
+		c.latenciaEWM += 0.1 * (ms - c.latenciaEWM)
+	}
+
+	if saturado || latencia > c.objetivo {
+		// una baja por ventana: las respuestas lentas llegan en grupo
+		if ahora.Sub(c.ultimaBaja) > c.objetivo {
+			c.limite = math.Max(c.min, c.limite*0.9)
+			c.ultimaBaja = ahora
+		}
+		return
+	}
+	c.limite = math.Min(c.max, c.limite+1/c.limite)
+}
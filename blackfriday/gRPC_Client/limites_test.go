@@ -0,0 +1,200 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIPCliente(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		header  string
+		proxies int
+		valores []string
+		quiero  string
+	}{
+		{"sin header configurado", "", 1, []string{"1.1.1.1"}, "10.0.0.9"},
+		{"sin header en el request", "X-Forwarded-For", 1, nil, "10.0.0.9"},
+		{"un proxy: la de la derecha", "X-Forwarded-For", 1, []string{"6.6.6.6, 1.1.1.1"}, "1.1.1.1"},
+		{"dos proxies", "X-Forwarded-For", 2, []string{"6.6.6.6, 1.1.1.1, 10.0.0.5"}, "1.1.1.1"},
+		{"menos entradas que proxies", "X-Forwarded-For", 3, []string{"1.1.1.1, 10.0.0.5"}, "1.1.1.1"},
+		{"varias líneas del header", "X-Forwarded-For", 2, []string{"6.6.6.6", "1.1.1.1, 10.0.0.5"}, "1.1.1.1"},
+		{"entradas vacías", "X-Forwarded-For", 1, []string{"6.6.6.6, 1.1.1.1 ,, "}, "1.1.1.1"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			l := &limitador{ipHeader: c.header, proxies: c.proxies}
+			r := httptest.NewRequest("POST", "/ventas", nil)
+			r.RemoteAddr = "10.0.0.9:5555"
+			for _, v := range c.valores {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := l.ipCliente(r); got != c.quiero {
+				t.Errorf("ipCliente = %q, quiero %q", got, c.quiero)
+			}
+		})
+	}
+}
+
+func TestCubeta(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	c := nuevaCubeta(2, 3, t0) // 2/s, ráfaga 3
+
+	// la ráfaga sale entera sin esperar
+	for i := range 3 {
+		if ok, _ := c.tomar(t0); !ok {
+			t.Fatalf("request %d de la ráfaga rechazado", i+1)
+		}
+	}
+	ok, espera := c.tomar(t0)
+	if ok || espera != 500*time.Millisecond {
+		t.Fatalf("cubeta vacía: ok=%v espera=%v; esperaba 500ms", ok, espera)
+	}
+
+	// a 2/s, en 250ms hay medio token: falta otro cuarto de segundo
+	if ok, espera := c.tomar(t0.Add(250 * time.Millisecond)); ok || espera != 250*time.Millisecond {
+		t.Fatalf("medio token: ok=%v espera=%v", ok, espera)
+	}
+	if ok, _ := c.tomar(t0.Add(500 * time.Millisecond)); !ok {
+		t.Fatal("sin recarga después de 500ms")
+	}
+
+	// la recarga no pasa de la ráfaga aunque pase mucho tiempo
+	t1 := t0.Add(time.Hour)
+	for i := range 3 {
+		if ok, _ := c.tomar(t1); !ok {
+			t.Fatalf("request %d después de una hora rechazado", i+1)
+		}
+	}
+	if ok, _ := c.tomar(t1); ok {
+		t.Fatal("la recarga superó la ráfaga")
+	}
+}
+
+func TestCubetasPorClave(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	p := &cubetasPorClave{tasa: 1, rafaga: 1, inactividad: time.Minute, cubetas: make(map[string]*cubeta)}
+
+	if ok, _ := p.tomar("apikey:a", t0); !ok {
+		t.Fatal("primer request de a rechazado")
+	}
+	if ok, _ := p.tomar("apikey:a", t0); ok {
+		t.Fatal("segundo request de a aceptado")
+	}
+	// otra credencial del mismo tenant tiene su propia cubeta
+	if ok, _ := p.tomar("apikey:b", t0); !ok {
+		t.Fatal("b rechazado por el consumo de a")
+	}
+
+	// b sigue activa; a queda inactiva más de un minuto y se descarta
+	p.tomar("apikey:b", t0.Add(50*time.Second))
+	p.tomar("apikey:c", t0.Add(61*time.Second))
+	if _, ok := p.cubetas["apikey:a"]; ok {
+		t.Error("cubeta inactiva no descartada")
+	}
+	if _, ok := p.cubetas["apikey:b"]; !ok {
+		t.Error("cubeta activa descartada")
+	}
+	if len(p.cubetas) != 2 {
+		t.Errorf("%d cubetas, esperaba 2", len(p.cubetas))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	casos := []struct {
+		espera time.Duration
+		quiero string
+	}{
+		{0, "1"},
+		{200 * time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1001 * time.Millisecond, "2"},
+		{2300 * time.Millisecond, "3"},
+	}
+	for _, c := range casos {
+		w := httptest.NewRecorder()
+		rechazar(w, http.StatusTooManyRequests, "prueba", c.espera)
+		if got := w.Header().Get("Retry-After"); got != c.quiero || w.Code != http.StatusTooManyRequests {
+			t.Errorf("espera %v: %d Retry-After %q, quiero 429 %q", c.espera, w.Code, got, c.quiero)
+		}
+	}
+}
+
+func TestPorCredencialOK(t *testing.T) {
+	l := &limitador{porCred: &cubetasPorClave{tasa: 0.5, rafaga: 2, inactividad: time.Minute, cubetas: make(map[string]*cubeta)}}
+	for i := range 2 {
+		if !l.porCredencialOK(httptest.NewRecorder(), "jwt:caja-1") {
+			t.Fatalf("request %d de la ráfaga rechazado", i+1)
+		}
+	}
+	w := httptest.NewRecorder()
+	if l.porCredencialOK(w, "jwt:caja-1") {
+		t.Fatal("request sobre la ráfaga aceptado")
+	}
+	// a 0.5/s falta un token entero: 2s
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("%d Retry-After %q, quiero 429 \"2\"", w.Code, w.Header().Get("Retry-After"))
+	}
+	if !l.porCredencialOK(httptest.NewRecorder(), "jwt:caja-2") {
+		t.Error("otra credencial rechazada")
+	}
+	if !l.porCredencialOK(httptest.NewRecorder(), "") {
+		t.Error("sin autenticación no hay límite por credencial")
+	}
+}
+
+func TestConcurrenciaAIMD(t *testing.T) {
+	objetivo := 100 * time.Millisecond
+	c := nuevaConcurrencia(10, 5, 12, objetivo)
+	t0 := time.Unix(1000, 0)
+
+	// el límite acepta hasta 10 en vuelo
+	for i := range 10 {
+		if !c.entrar() {
+			t.Fatalf("entrada %d rechazada con límite 10", i+1)
+		}
+	}
+	if c.entrar() {
+		t.Fatal("entrada 11 aceptada con límite 10")
+	}
+
+	// baja multiplicativa: una sola vez por ventana aunque lleguen varias lentas
+	c.salir(2*objetivo, false, t0)
+	c.salir(2*objetivo, false, t0.Add(objetivo/2))
+	c.salir(time.Millisecond, true, t0.Add(objetivo/2))
+	if c.limite != 9 {
+		t.Fatalf("límite %v después de una ventana lenta, esperaba 9", c.limite)
+	}
+	c.salir(2*objetivo, false, t0.Add(2*objetivo))
+	if c.limite != 8.1 {
+		t.Fatalf("límite %v en la ventana siguiente, esperaba 8.1", c.limite)
+	}
+
+	// no baja del mínimo
+	for i := range 20 {
+		c.enVuelo++
+		c.salir(time.Second, true, t0.Add(time.Duration(3+i)*2*objetivo))
+	}
+	if c.limite != 5 {
+		t.Fatalf("límite %v, esperaba el mínimo 5", c.limite)
+	}
+
+	// suba aditiva: 1/límite por respuesta rápida, hasta el máximo
+	c.enVuelo++
+	c.salir(time.Millisecond, false, t0.Add(time.Hour))
+	if c.limite != 5.2 {
+		t.Fatalf("límite %v después de una respuesta rápida, esperaba 5.2", c.limite)
+	}
+	for range 200 {
+		c.enVuelo++
+		c.salir(time.Millisecond, false, t0.Add(time.Hour))
+	}
+	if c.limite != 12 {
+		t.Fatalf("límite %v, esperaba el máximo 12", c.limite)
+	}
+	if c.enVuelo != 6 {
+		t.Fatalf("en vuelo %d, esperaba 6", c.enVuelo)
+	}
+}
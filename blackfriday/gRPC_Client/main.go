@@ -3,15 +3,27 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"blackfriday/auth"
+	"blackfriday/certs"
+	"blackfriday/interceptores"
 	pb "blackfriday/proto"
+	"blackfriday/registro"
+	"comun/config"
 )
 
 type saleJSON struct {
@@ -36,20 +48,149 @@ func mapCategoria(cat string) pb.CategoriaProducto {
 	}
 }
 
+// rechazoVenta traduce los rechazos de ProcesarVenta (stock, catálogo) a
+// status HTTP y Estado.
+func rechazoVenta(c codes.Code) (int, string, bool) {
+	switch c {
+	case codes.FailedPrecondition:
+		return http.StatusConflict, "AGOTADO", true
+	case codes.NotFound:
+		return http.StatusNotFound, "PRODUCTO_DESCONOCIDO", true
+	case codes.InvalidArgument:
+		return http.StatusBadRequest, "VENTA_INVALIDA", true
+	}
+	return 0, "", false
+}
+
+// credencialesGRPC arma TLS si GRPC_TLS=true o hay algún archivo
+// configurado; si no, texto plano como antes.
+//
+//	GRPC_TLS_CA           CA para verificar al server (por defecto las del sistema)
+//	GRPC_TLS_CERT/KEY     certificado de cliente para mTLS
+//	GRPC_TLS_SERVER_NAME  nombre esperado en el certificado del server
+//	GRPC_TLS_RELOAD       cada cuánto se releen los archivos (por defecto 30s)
+func credencialesGRPC() credentials.TransportCredentials {
+	arch := certs.Archivos{
+		Cert: os.Getenv("GRPC_TLS_CERT"),
+		Key:  os.Getenv("GRPC_TLS_KEY"),
+		CA:   os.Getenv("GRPC_TLS_CA"),
+	}
+	if os.Getenv("GRPC_TLS") != "true" && arch == (certs.Archivos{}) {
+		return insecure.NewCredentials()
+	}
+	rec, err := certs.Nuevo(arch)
+	if err != nil {
+		log.Fatalf("TLS: %v", err)
+	}
+	go rec.Vigilar(context.Background(), config.LeerDuracion("GRPC_TLS_RELOAD", 30*time.Second))
+	slog.Info("gRPC con TLS", "ca", arch.CA, "mtls", arch.Cert != "")
+	return credentials.NewTLS(rec.ConfigCliente(os.Getenv("GRPC_TLS_SERVER_NAME")))
+}
+
+// autenticadorDesdeEnv habilita la autenticación de /ventas si hay API keys
+// o JWKS configurados; si no, /ventas queda abierto como antes.
+//
+//	AUTH_API_KEYS          archivo JSON {"<clave>": "<tenant>", ...}
+//	AUTH_JWKS              JWKS local con claves RS256 (RSA) o HS256 (oct)
+//	AUTH_JWT_ISSUER        iss esperado (opcional)
+//	AUTH_JWT_AUDIENCE      aud esperado (opcional)
+//	AUTH_JWT_TENANT_CLAIM  claim con el tenant (por defecto "tenant")
+func autenticadorDesdeEnv() *auth.Autenticador {
+	cfg := auth.Config{
+		APIKeysArchivo: os.Getenv("AUTH_API_KEYS"),
+		JWKSArchivo:    os.Getenv("AUTH_JWKS"),
+		Issuer:         os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:       os.Getenv("AUTH_JWT_AUDIENCE"),
+		ClaimTenant:    os.Getenv("AUTH_JWT_TENANT_CLAIM"),
+	}
+	if cfg.APIKeysArchivo == "" && cfg.JWKSArchivo == "" {
+		slog.Warn("auth desactivada: /ventas sin autenticación")
+		return nil
+	}
+	a, err := auth.Nuevo(cfg)
+	if err != nil {
+		log.Fatalf("Auth: %v", err)
+	}
+	slog.Info("auth activa en /ventas", "metodos", a.Metodos())
+	return a
+}
+
+// noAutorizado responde 401 con el motivo.
+func noAutorizado(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ventas"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(map[string]any{
+		"estado": "NO_AUTORIZADO",
+		"error":  err.Error(),
+	})
+}
+
+// requestIDDe toma el X-Request-ID que manda la API Rust o genera uno. El ID
+// va a los logs, a la metadata gRPC y al evento de Kafka, así que solo se
+// acepta si es corto y de caracteres seguros ([A-Za-z0-9._-], hasta 128
+// bytes); cualquier otro se reemplaza.
+func requestIDDe(r *http.Request) string {
+	if v := r.Header.Get(registro.HeaderRequestID); requestIDValido(v) {
+		return v
+	}
+	return interceptores.NuevoRequestID()
+}
+
+func requestIDValido(v string) bool {
+	if v == "" || len(v) > 128 {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func main() {
+	// Archivo (--config), entorno y flags; --print-config muestra el resultado
+	cfg, _, err := config.Cargar("grpc-client", opciones, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Config inválida:\n%v", err)
+	}
+	if cfg.Imprimir {
+		cfg.Escribir(os.Stdout)
+		return
+	}
+	if err := registro.Configurar("grpc-client"); err != nil {
+		log.Fatalf("Logs: %v", err)
+	}
+
 	grpcAddr := os.Getenv("GRPC_SERVER_ADDR")
-	if grpcAddr == "" {
-		grpcAddr = "localhost:50051"
+	httpAddr := os.Getenv("HTTP_ADDR")
+
+	icfg, err := interceptores.DesdeEnv("cliente")
+	if err != nil {
+		log.Fatalf("Interceptores: %v", err)
 	}
+	slog.Info("interceptores gRPC", "cadena", icfg.String())
 
 	// Conexión gRPC (cliente)
-	conn, err := grpc.Dial(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(credencialesGRPC())}, icfg.OpcionesCliente()...)
+	conn, err := conectarGRPC(grpcAddr, dialOpts...)
 	if err != nil {
 		log.Fatalf("No pude conectar a gRPC %s: %v", grpcAddr, err)
 	}
 	defer conn.Close()
 
 	client := pb.NewProductSaleServiceClient(conn)
+	autenticador := autenticadorDesdeEnv()
+	lim := limitadorDesdeEnv()
+	tmo := timeoutsDesdeEnv()
 
 	// REST endpoint
 	http.HandleFunc("/ventas", func(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +199,29 @@ func main() {
 			return
 		}
 
+		// El request ID vuelve en la respuesta y sigue al gRPC server y a Kafka
+		rid := requestIDDe(r)
+		w.Header().Set(registro.HeaderRequestID, rid)
+
+		if !lim.antesDeAuth(w, r) {
+			return
+		}
+
+		// El tenant autenticado viaja al gRPC server como metadata
+		var id auth.Identidad
+		if autenticador != nil {
+			var err error
+			id, err = autenticador.Autenticar(r)
+			if err != nil {
+				slog.Warn("venta no autorizada", "rid", rid, "err", err)
+				noAutorizado(w, err)
+				return
+			}
+		}
+		if !lim.porCredencialOK(w, id.Credencial) {
+			return
+		}
+
 		var s saleJSON
 		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -65,18 +229,55 @@ func main() {
 			return
 		}
 
+		timeout, err := tmo.delPedido(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
 		// Llamada gRPC
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
+		ctx = metadata.AppendToOutgoingContext(ctx, interceptores.MetadataRequestID, rid)
+		if id.Tenant != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, auth.MetadataTenant, id.Tenant)
+		}
 
+		// Sin lugar en el límite de concurrencia se descarta ya, sin esperar
+		if lim.conc != nil && !lim.conc.entrar() {
+			rechazar(w, http.StatusServiceUnavailable, "concurrencia", time.Second)
+			return
+		}
+		inicio := time.Now()
 		resp, err := client.ProcesarVenta(ctx, &pb.ProductSaleRequest{
 			Categoria:       mapCategoria(s.Categoria),
 			ProductoId:      s.ProductoID,
 			Precio:          s.Precio,
 			CantidadVendida: s.CantidadVendida,
 		})
+		if lim.conc != nil {
+			switch status.Code(err) {
+			case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+				lim.conc.salir(time.Since(inicio), true, time.Now())
+			default:
+				lim.conc.salir(time.Since(inicio), false, time.Now())
+			}
+		}
+		if st, ok := status.FromError(err); ok {
+			// rechazos de negocio del server: se devuelven al cliente, no son fallas
+			if code, estado, ok := rechazoVenta(st.Code()); ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(code)
+				json.NewEncoder(w).Encode(map[string]any{
+					"estado": estado,
+					"error":  st.Message(),
+				})
+				return
+			}
+		}
 		if err != nil {
-			log.Printf("Error llamando gRPC: %v", err)
+			slog.Error("error llamando gRPC", "rid", rid, "err", err)
 			w.WriteHeader(http.StatusBadGateway)
 			w.Write([]byte("Error llamando gRPC"))
 			return
@@ -92,6 +293,6 @@ func main() {
 		w.Write([]byte("ok"))
 	})
 
-	log.Println("Go REST (cliente gRPC) escuchando en :8081, apuntando a gRPC:", grpcAddr)
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	slog.Info("Go REST (cliente gRPC) escuchando", "addr", httpAddr, "grpc", grpcAddr)
+	log.Fatal(http.ListenAndServe(httpAddr, nil))
 }
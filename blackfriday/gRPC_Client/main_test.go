@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blackfriday/registro"
+)
+
+func TestRequestIDDe(t *testing.T) {
+	casos := []struct {
+		nombre string
+		id     string
+		acepta bool
+	}{
+		{"uuid", "3f2b6c1e-8a4d-4e0f-9b7a-1c2d3e4f5a6b", true},
+		{"con punto y guion bajo", "api.rust_01-xyz", true},
+		{"128 bytes", strings.Repeat("a", 128), true},
+		{"129 bytes", strings.Repeat("a", 129), false},
+		{"salto de línea", "abc\ninyectado", false},
+		{"espacio", "abc def", false},
+		{"comillas", `abc"def`, false},
+		{"no ASCII", "año", false},
+		{"control", "abc\x00", false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/ventas", nil)
+			r.Header.Set(registro.HeaderRequestID, c.id)
+			got := requestIDDe(r)
+			if c.acepta && got != c.id {
+				t.Fatalf("%q reemplazado por %q", c.id, got)
+			}
+			if !c.acepta && (got == c.id || !requestIDValido(got)) {
+				t.Fatalf("%q: quedó %q, esperaba un ID nuevo válido", c.id, got)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("POST", "/ventas", nil)
+	if id := requestIDDe(r); !requestIDValido(id) {
+		t.Errorf("sin header: ID generado inválido %q", id)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "blackfriday/proto"
+)
+
+// Servicios de administración (InventarioService y CatalogoService): cargan
+// stock y editan el catálogo, así que no van en el puerto de ventas, que
+// atiende al gateway sin autenticar. Escuchan en un listener aparte:
+//
+//	GRPC_ADMIN_PORT   puerto de administración (por defecto 50052; el
+//	                  Service de k8s no lo expone, se llega con
+//	                  port-forward o desde un Job)
+//	GRPC_ADMIN_TOKEN  token requerido en "authorization: Bearer <token>"
+//
+// Con TLS comparte certificados con el puerto de ventas; con mTLS
+// (GRPC_TLS_CLIENT_CA) el certificado de cliente ya autentica y el token es
+// opcional. Sin token ni mTLS los servicios de administración no se
+// levantan.
+func servirAdmin(opts []grpc.ServerOption, inv *inventario, cat *catalogo) (*grpc.Server, error) {
+	token := os.Getenv("GRPC_ADMIN_TOKEN")
+	mtls := os.Getenv("GRPC_TLS_CERT") != "" && os.Getenv("GRPC_TLS_CLIENT_CA") != ""
+	if token == "" && !mtls {
+		slog.Warn("servicios de inventario y catálogo desactivados: falta GRPC_ADMIN_TOKEN o mTLS")
+		return nil, nil
+	}
+	if token != "" {
+		opts = append(opts, grpc.ChainUnaryInterceptor(tokenAdmin(token)))
+	}
+
+	port := os.Getenv("GRPC_ADMIN_PORT")
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("no pude escuchar :%s: %w", port, err)
+	}
+	srv := grpc.NewServer(opts...)
+	pb.RegisterInventarioServiceServer(srv, &inventarioServer{inv: inv})
+	pb.RegisterCatalogoServiceServer(srv, &catalogoServer{cat: cat})
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("gRPC admin", "err", err)
+		}
+	}()
+	slog.Info("gRPC admin escuchando", "puerto", port, "token", token != "", "mtls", mtls)
+	return srv, nil
+}
+
+// tokenAdmin exige el token de administración. Se comparan los hashes para
+// que el tiempo no dependa del largo ni del contenido del token recibido.
+func tokenAdmin(token string) grpc.UnaryServerInterceptor {
+	esperado := sha256.Sum256([]byte(token))
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var recibido string
+		bearer := false
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				recibido, bearer = strings.CutPrefix(v[0], "Bearer ")
+			}
+		}
+		h := sha256.Sum256([]byte(recibido))
+		if !bearer || recibido == "" || subtle.ConstantTimeCompare(h[:], esperado[:]) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "token de administración inválido")
+		}
+		return handler(ctx, req)
+	}
+}
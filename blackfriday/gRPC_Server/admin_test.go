@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenAdmin(t *testing.T) {
+	interceptor := tokenAdmin("secreto")
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	casos := []struct {
+		nombre string
+		md     metadata.MD
+		ok     bool
+	}{
+		{"sin metadata", nil, false},
+		{"sin header", metadata.Pairs("x", "y"), false},
+		{"token correcto", metadata.Pairs("authorization", "Bearer secreto"), true},
+		{"token incorrecto", metadata.Pairs("authorization", "Bearer otro"), false},
+		{"sin Bearer", metadata.Pairs("authorization", "secreto"), false},
+		{"Bearer vacío", metadata.Pairs("authorization", "Bearer "), false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			ctx := context.Background()
+			if c.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, c.md)
+			}
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/blackfriday.InventarioService/CargarStock"}, handler)
+			if c.ok && err != nil {
+				t.Fatalf("esperaba OK, fue %v", err)
+			}
+			if !c.ok && status.Code(err) != codes.Unauthenticated {
+				t.Fatalf("esperaba Unauthenticated, fue %v", err)
+			}
+		})
+	}
+}
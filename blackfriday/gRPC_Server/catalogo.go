@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"blackfriday/interceptores"
+	pb "blackfriday/proto"
+	"comun/keys"
+)
+
+// catalogo guarda los productos en Valkey, en las keys del paquete keys
+// (Catalogo, CatalogoNombres) que también lee el consumer:
+//
+//	<prefix>:catalogo          HASH productoId -> JSON (productoCatalogo)
+//	<prefix>:catalogo:nombres  HASH productoId -> nombre (para los dashboards)
+//
+// Las dos keys se escriben juntas en un MULTI. El consumer lee el catálogo
+// para enriquecer las ventas con nombre y profundidad de descuento.
+type catalogo struct {
+	rdb     redis.UniversalClient
+	key     string
+	nombres string
+	validar bool
+}
+
+// productoCatalogo es el JSON guardado; la categoría va con el mismo nombre
+// que en los eventos de Kafka ("Electronica", "Ropa", ...).
+type productoCatalogo struct {
+	ID           string  `json:"productoId"`
+	Nombre       string  `json:"nombre"`
+	Categoria    string  `json:"categoria"`
+	PrecioLista  float64 `json:"precioLista"`
+	DescuentoPct float64 `json:"descuentoPct"`
+}
+
+// catalogoDesdeEnv arma el catálogo sobre la conexión a Valkey.
+//
+//	CATALOGO_VALIDAR  rechazar ventas de productos fuera del catálogo o con
+//	                  otra categoría (por defecto false: con el catálogo vacío
+//	                  se rechazaría todo)
+//	CATALOGO_INICIAL  archivo JSON [{"productoId": ..., "nombre": ..., ...}]
+//	                  que se carga al arrancar sin pisar productos existentes
+//	                  (ver cargarInicial)
+func catalogoDesdeEnv(rdb redis.UniversalClient, ks keys.Schema) *catalogo {
+	return &catalogo{
+		rdb:     rdb,
+		key:     ks.Catalogo(),
+		nombres: ks.CatalogoNombres(),
+		validar: os.Getenv("CATALOGO_VALIDAR") == "true",
+	}
+}
+
+// cargarInicial carga CATALOGO_INICIAL, si está definido.
+func (c *catalogo) cargarInicial(ctx context.Context) error {
+	path := os.Getenv("CATALOGO_INICIAL")
+	if path == "" {
+		return nil
+	}
+	n, err := c.cargarArchivo(ctx, path)
+	if err != nil {
+		return fmt.Errorf("CATALOGO_INICIAL: %w", err)
+	}
+	slog.Info("catálogo: productos cargados", "cantidad", n, "archivo", path)
+	return nil
+}
+
+// verificar confirma que el producto existe y que la categoría de la venta
+// es la del catálogo.
+func (c *catalogo) verificar(ctx context.Context, productoID string, categoria pb.CategoriaProducto) error {
+	p, err := c.obtener(ctx, productoID)
+	if err != nil {
+		return err
+	}
+	if p.Categoria != categoria.String() {
+		return status.Errorf(codes.InvalidArgument, "producto %s es de la categoría %s, no %s", productoID, p.Categoria, categoria)
+	}
+	return nil
+}
+
+func (c *catalogo) obtener(ctx context.Context, productoID string) (*productoCatalogo, error) {
+	b, err := c.rdb.HGet(ctx, c.key, productoID).Bytes()
+	if err == redis.Nil {
+		return nil, status.Errorf(codes.NotFound, "producto %s no está en el catálogo", productoID)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "catálogo: %v", err)
+	}
+	var p productoCatalogo
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, status.Errorf(codes.Internal, "catálogo: producto %s ilegible: %v", productoID, err)
+	}
+	return &p, nil
+}
+
+// intentosGuardar acota los reintentos de guardar: el WATCH es sobre el
+// HASH entero, así que cualquier otra escritura del catálogo entre el WATCH
+// y el EXEC hace fallar la transacción.
+const intentosGuardar = 10
+
+// guardar escribe el producto; con soloSiNuevo no pisa uno existente y con
+// soloSiExiste no crea uno nuevo. Devuelve false si no escribió.
+func (c *catalogo) guardar(ctx context.Context, p productoCatalogo, soloSiNuevo, soloSiExiste bool) (bool, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return false, err
+	}
+	for range intentosGuardar {
+		escrito, err := c.guardarTx(ctx, p, b, soloSiNuevo, soloSiExiste)
+		if err != redis.TxFailedErr {
+			return escrito, err
+		}
+	}
+	return false, redis.TxFailedErr
+}
+
+func (c *catalogo) guardarTx(ctx context.Context, p productoCatalogo, b []byte, soloSiNuevo, soloSiExiste bool) (bool, error) {
+	escrito := false
+	err := c.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		existe, err := tx.HExists(ctx, c.key, p.ID).Result()
+		if err != nil {
+			return err
+		}
+		if (soloSiNuevo && existe) || (soloSiExiste && !existe) {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, c.key, p.ID, b)
+			pipe.HSet(ctx, c.nombres, p.ID, p.Nombre)
+			return nil
+		})
+		escrito = err == nil
+		return err
+	}, c.key)
+	return escrito, err
+}
+
+func (c *catalogo) cargarArchivo(ctx context.Context, path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var productos []productoCatalogo
+	if err := json.Unmarshal(b, &productos); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	n := 0
+	for _, p := range productos {
+		cat, ok := pb.CategoriaProducto_value[p.Categoria]
+		if !ok {
+			return n, fmt.Errorf("%s: producto %q con categoría desconocida %q", path, p.ID, p.Categoria)
+		}
+		if err := validarProducto(productoProto(p, pb.CategoriaProducto(cat))); err != nil {
+			return n, fmt.Errorf("%s: %v", path, status.Convert(err).Message())
+		}
+		nuevo, err := c.guardar(ctx, p, true, false)
+		if err != nil {
+			return n, err
+		}
+		if nuevo {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func validarProducto(p *pb.Producto) error {
+	switch {
+	case p.ProductoId == "":
+		return status.Error(codes.InvalidArgument, "producto_id vacío")
+	case p.Categoria == pb.CategoriaProducto_CATEGORIA_PRODUCTO_UNSPECIFIED:
+		return status.Errorf(codes.InvalidArgument, "producto %s sin categoría", p.ProductoId)
+	case p.PrecioLista < 0:
+		return status.Errorf(codes.InvalidArgument, "producto %s con precio de lista negativo", p.ProductoId)
+	case p.DescuentoPct < 0 || p.DescuentoPct > 100:
+		return status.Errorf(codes.InvalidArgument, "producto %s con descuento fuera de 0-100", p.ProductoId)
+	}
+	return nil
+}
+
+func productoProto(p productoCatalogo, cat pb.CategoriaProducto) *pb.Producto {
+	return &pb.Producto{
+		ProductoId:   p.ID,
+		Nombre:       p.Nombre,
+		Categoria:    cat,
+		PrecioLista:  p.PrecioLista,
+		DescuentoPct: p.DescuentoPct,
+	}
+}
+
+func desdeProto(p *pb.Producto) productoCatalogo {
+	return productoCatalogo{
+		ID:           p.ProductoId,
+		Nombre:       p.Nombre,
+		Categoria:    p.Categoria.String(),
+		PrecioLista:  p.PrecioLista,
+		DescuentoPct: p.DescuentoPct,
+	}
+}
+
+// catalogoServer implementa el CRUD de CatalogoService.
+type catalogoServer struct {
+	pb.UnimplementedCatalogoServiceServer
+	cat *catalogo
+}
+
+func (s *catalogoServer) CrearProducto(ctx context.Context, req *pb.Producto) (*pb.Producto, error) {
+	if err := validarProducto(req); err != nil {
+		return nil, err
+	}
+	ok, err := s.cat.guardar(ctx, desdeProto(req), true, false)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "catálogo: %v", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.AlreadyExists, "producto %s ya existe", req.ProductoId)
+	}
+	slog.Info("catálogo: producto creado", "rid", interceptores.RequestID(ctx), "producto_id", req.ProductoId, "nombre", req.Nombre)
+	return req, nil
+}
+
+func (s *catalogoServer) ObtenerProducto(ctx context.Context, req *pb.ProductoIdRequest) (*pb.Producto, error) {
+	p, err := s.cat.obtener(ctx, req.ProductoId)
+	if err != nil {
+		return nil, err
+	}
+	return productoProto(*p, pb.CategoriaProducto(pb.CategoriaProducto_value[p.Categoria])), nil
+}
+
+func (s *catalogoServer) ActualizarProducto(ctx context.Context, req *pb.Producto) (*pb.Producto, error) {
+	if err := validarProducto(req); err != nil {
+		return nil, err
+	}
+	ok, err := s.cat.guardar(ctx, desdeProto(req), false, true)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "catálogo: %v", err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "producto %s no está en el catálogo", req.ProductoId)
+	}
+	slog.Info("catálogo: producto actualizado", "rid", interceptores.RequestID(ctx), "producto_id", req.ProductoId)
+	return req, nil
+}
+
+func (s *catalogoServer) EliminarProducto(ctx context.Context, req *pb.ProductoIdRequest) (*pb.EliminarProductoResponse, error) {
+	var n *redis.IntCmd
+	if _, err := s.cat.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		n = pipe.HDel(ctx, s.cat.key, req.ProductoId)
+		pipe.HDel(ctx, s.cat.nombres, req.ProductoId)
+		return nil
+	}); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "catálogo: %v", err)
+	}
+	if n.Val() == 0 {
+		return nil, status.Errorf(codes.NotFound, "producto %s no está en el catálogo", req.ProductoId)
+	}
+	slog.Info("catálogo: producto eliminado", "rid", interceptores.RequestID(ctx), "producto_id", req.ProductoId)
+	return &pb.EliminarProductoResponse{}, nil
+}
+
+func (s *catalogoServer) ListarProductos(ctx context.Context, req *pb.ListarProductosRequest) (*pb.ListarProductosResponse, error) {
+	todos, err := s.cat.rdb.HGetAll(ctx, s.cat.key).Result()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "catálogo: %v", err)
+	}
+	resp := &pb.ListarProductosResponse{}
+	for id, v := range todos {
+		var p productoCatalogo
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			slog.Warn("catálogo: producto ilegible", "producto_id", id, "err", err)
+			continue
+		}
+		cat := pb.CategoriaProducto(pb.CategoriaProducto_value[p.Categoria])
+		if req.Categoria != pb.CategoriaProducto_CATEGORIA_PRODUCTO_UNSPECIFIED && cat != req.Categoria {
+			continue
+		}
+		resp.Productos = append(resp.Productos, productoProto(p, cat))
+	}
+	return resp, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "blackfriday/proto"
+	"comun/keys"
+)
+
+func nuevoCatalogoPrueba(t *testing.T, validar bool) (*catalogo, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	ks := keys.New("venta", false)
+	return &catalogo{rdb: rdb, key: ks.Catalogo(), nombres: ks.CatalogoNombres(), validar: validar}, mr
+}
+
+func productoPrueba(id string) *pb.Producto {
+	return &pb.Producto{ProductoId: id, Nombre: "Auriculares", Categoria: pb.CategoriaProducto_Electronica, PrecioLista: 100, DescuentoPct: 20}
+}
+
+func TestCatalogoCRUD(t *testing.T) {
+	cat, mr := nuevoCatalogoPrueba(t, false)
+	s := &catalogoServer{cat: cat}
+	ctx := context.Background()
+
+	if _, err := s.CrearProducto(ctx, productoPrueba("p1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CrearProducto(ctx, productoPrueba("p1")); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("crear repetido: %v, esperaba AlreadyExists", err)
+	}
+	invalido := productoPrueba("p2")
+	invalido.DescuentoPct = 120
+	if _, err := s.CrearProducto(ctx, invalido); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("crear inválido: %v, esperaba InvalidArgument", err)
+	}
+	if mr.HGet(cat.nombres, "p1") != "Auriculares" {
+		t.Fatal("crear no escribió el nombre")
+	}
+
+	p, err := s.ObtenerProducto(ctx, &pb.ProductoIdRequest{ProductoId: "p1"})
+	if err != nil || p.Nombre != "Auriculares" || p.Categoria != pb.CategoriaProducto_Electronica || p.DescuentoPct != 20 {
+		t.Fatalf("obtener: %v, %v", p, err)
+	}
+
+	cambio := productoPrueba("p1")
+	cambio.Nombre, cambio.Categoria = "Auriculares BT", pb.CategoriaProducto_Hogar
+	if _, err := s.ActualizarProducto(ctx, cambio); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ActualizarProducto(ctx, productoPrueba("p9")); status.Code(err) != codes.NotFound {
+		t.Fatalf("actualizar inexistente: %v, esperaba NotFound", err)
+	}
+	if mr.HGet(cat.key, "p9") != "" {
+		t.Fatal("actualizar creó un producto")
+	}
+	if p, err := s.ObtenerProducto(ctx, &pb.ProductoIdRequest{ProductoId: "p1"}); err != nil || p.Nombre != "Auriculares BT" || p.Categoria != pb.CategoriaProducto_Hogar {
+		t.Fatalf("obtener después de actualizar: %v, %v", p, err)
+	}
+	if mr.HGet(cat.nombres, "p1") != "Auriculares BT" {
+		t.Fatal("actualizar no cambió el nombre")
+	}
+
+	if _, err := s.CrearProducto(ctx, productoPrueba("p2")); err != nil {
+		t.Fatal(err)
+	}
+	lista, err := s.ListarProductos(ctx, &pb.ListarProductosRequest{Categoria: pb.CategoriaProducto_Hogar})
+	if err != nil || len(lista.Productos) != 1 || lista.Productos[0].ProductoId != "p1" {
+		t.Fatalf("listar Hogar: %v, %v", lista, err)
+	}
+
+	if _, err := s.EliminarProducto(ctx, &pb.ProductoIdRequest{ProductoId: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EliminarProducto(ctx, &pb.ProductoIdRequest{ProductoId: "p1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("eliminar dos veces: %v, esperaba NotFound", err)
+	}
+	if _, err := s.ObtenerProducto(ctx, &pb.ProductoIdRequest{ProductoId: "p1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("obtener eliminado: %v, esperaba NotFound", err)
+	}
+	if mr.HGet(cat.nombres, "p1") != "" {
+		t.Fatal("eliminar dejó el nombre")
+	}
+}
+
+func TestVerificar(t *testing.T) {
+	cat, mr := nuevoCatalogoPrueba(t, true)
+	ctx := context.Background()
+	if ok, err := cat.guardar(ctx, desdeProto(productoPrueba("p1")), true, false); !ok || err != nil {
+		t.Fatalf("guardar: %v, %v", ok, err)
+	}
+	mr.HSet(cat.key, "roto", "{")
+
+	casos := []struct {
+		nombre    string
+		producto  string
+		categoria pb.CategoriaProducto
+		codigo    codes.Code
+	}{
+		{"existe", "p1", pb.CategoriaProducto_Electronica, codes.OK},
+		{"desconocido", "p9", pb.CategoriaProducto_Electronica, codes.NotFound},
+		{"otra categoría", "p1", pb.CategoriaProducto_Ropa, codes.InvalidArgument},
+		{"JSON ilegible", "roto", pb.CategoriaProducto_Electronica, codes.Internal},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if err := cat.verificar(ctx, c.producto, c.categoria); status.Code(err) != c.codigo {
+				t.Fatalf("verificar: %v, esperaba %v", err, c.codigo)
+			}
+		})
+	}
+}
+
+// conflictoWatch escribe en la key del catálogo desde otro cliente justo
+// después del HEXISTS de guardar, como otra réplica que guarda un producto
+// entre el WATCH y el EXEC.
+type conflictoWatch struct {
+	otro     *redis.Client
+	key      string
+	quedan   atomic.Int32
+	hechos   atomic.Int32
+	intentos atomic.Int32
+}
+
+func (c *conflictoWatch) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (c *conflictoWatch) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (c *conflictoWatch) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if cmd.Name() == "hexists" {
+			c.intentos.Add(1)
+			if c.quedan.Add(-1) >= 0 {
+				n := c.hechos.Add(1)
+				c.otro.HSet(ctx, c.key, fmt.Sprint("otro-", n), "{}")
+			}
+		}
+		return err
+	}
+}
+
+func TestGuardarReintentaWatch(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		conflictos int32
+		codigo     codes.Code
+	}{
+		{"sin conflicto", 0, codes.OK},
+		{"dos conflictos", 2, codes.OK},
+		{"conflicto en todos los intentos", intentosGuardar, codes.Unavailable},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			cat, mr := nuevoCatalogoPrueba(t, false)
+			otro := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer otro.Close()
+			hook := &conflictoWatch{otro: otro, key: cat.key}
+			hook.quedan.Store(c.conflictos)
+			cat.rdb.AddHook(hook)
+
+			_, err := (&catalogoServer{cat: cat}).CrearProducto(context.Background(), productoPrueba("p1"))
+			if status.Code(err) != c.codigo {
+				t.Fatalf("crear: %v, esperaba %v", err, c.codigo)
+			}
+			if want := min(c.conflictos+1, intentosGuardar); hook.intentos.Load() != want {
+				t.Fatalf("%d intentos, esperaba %d", hook.intentos.Load(), want)
+			}
+			// las escrituras concurrentes no se pierden
+			for i := int32(1); i <= c.conflictos; i++ {
+				if mr.HGet(cat.key, fmt.Sprint("otro-", i)) == "" {
+					t.Fatalf("se perdió la escritura concurrente %d", i)
+				}
+			}
+			if guardado := mr.HGet(cat.key, "p1") != ""; guardado != (c.codigo == codes.OK) {
+				t.Fatalf("p1 guardado = %v con resultado %v", guardado, c.codigo)
+			}
+		})
+	}
+}
+
+// Con CATALOGO_VALIDAR=true una venta de un producto fuera del catálogo se
+// rechaza antes de reservar stock y de publicar en Kafka.
+func TestProcesarVentaCatalogoValidar(t *testing.T) {
+	part, err := nuevoParticionado("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	venta := &pb.ProductSaleRequest{Categoria: pb.CategoriaProducto_Electronica, ProductoId: "p9", Precio: 10, CantidadVendida: 1}
+
+	casos := []struct {
+		nombre  string
+		validar bool
+		codigo  codes.Code
+	}{
+		{"validar", true, codes.NotFound},
+		{"sin validar", false, codes.OK},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			cat, _ := nuevoCatalogoPrueba(t, c.validar)
+			inv, mr := nuevoInventarioPrueba(t, false)
+			cargarStock(t, inv, "p9", 5)
+			kw := &escritorFalso{}
+			s := &server{kw: kw, part: part, inv: inv, cat: cat}
+
+			_, err := s.ProcesarVenta(context.Background(), venta)
+			if status.Code(err) != c.codigo {
+				t.Fatalf("err %v, esperaba %v", err, c.codigo)
+			}
+			publicadas, restante := 1, 4
+			if c.codigo != codes.OK {
+				publicadas, restante = 0, 5
+			}
+			if len(kw.mensajes) != publicadas || stockDe(t, mr, inv, "p9") != restante {
+				t.Fatalf("%d mensajes y stock %d; esperaba %d y %d", len(kw.mensajes), stockDe(t, mr, inv, "p9"), publicadas, restante)
+			}
+		})
+	}
+}
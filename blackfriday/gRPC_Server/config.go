@@ -0,0 +1,73 @@
+package main
+
+import (
+	"comun/config"
+	"comun/keys"
+	"comun/valkeycon"
+)
+
+// opciones es toda la configuración del gRPC server (ver paquete config:
+// archivo, entorno o flags).
+var opciones = []config.Opcion{
+	{Clave: "GRPC_PORT", Tipo: config.Puerto, Defecto: "50051", Desc: "puerto gRPC"},
+	{Clave: "GRPC_ADMIN_PORT", Tipo: config.Puerto, Defecto: "50052", Desc: "puerto de InventarioService y CatalogoService (ver admin.go)"},
+	{Clave: "GRPC_ADMIN_TOKEN", Secreto: true, Desc: "token Bearer de los servicios de administración (sin token ni mTLS no se levantan)"},
+	{Clave: "METRICS_ADDR", Tipo: config.Direccion, Defecto: ":9103", Desc: "dirección de /debug/vars"},
+	{Clave: "GRPC_MAX_CONNECTION_AGE", Tipo: config.Duracion, Desc: "vida máxima de cada conexión; los clientes re-resuelven el DNS (vacío: sin límite)"},
+
+	{Clave: "KAFKA_BROKERS", Tipo: config.Lista, Defecto: "kafka:9092", Desc: "brokers de Kafka"},
+	{Clave: "KAFKA_TOPIC", Defecto: "ventas", Desc: "topic de ventas"},
+	{Clave: "KAFKA_PARTICIONADO", Defecto: "producto", Valores: modosParticionado, Desc: "reparto en particiones: orden por producto, por categoría o sin orden (ver particionado.go)"},
+	{Clave: "KAFKA_BATCH_SIZE", Tipo: config.Entero, Defecto: "100", Positivo: true, Desc: "mensajes por lote del producer"},
+	{Clave: "KAFKA_BATCH_TIMEOUT", Tipo: config.Duracion, Defecto: "1s", Positivo: true, Desc: "espera máxima para completar un lote"},
+	{Clave: "KAFKA_WRITE_TIMEOUT", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "timeout de escritura en Kafka"},
+	{Clave: "KAFKA_TIMEOUT", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "timeout de conexión a los brokers"},
+	{Clave: "KAFKA_SASL_MECHANISM", Valores: []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"}, Desc: "autenticación SASL (vacío: sin SASL)"},
+	{Clave: "KAFKA_SASL_USERNAME", Desc: "usuario SASL"},
+	{Clave: "KAFKA_SASL_PASSWORD", Secreto: true, Desc: "contraseña SASL"},
+	{Clave: "KAFKA_TLS", Tipo: config.Bool, Defecto: "false", Desc: "TLS hacia los brokers con las CA del sistema"},
+	{Clave: "KAFKA_TLS_CA", Desc: "CA PEM del cluster (activa TLS)"},
+	{Clave: "KAFKA_TLS_CERT", Desc: "certificado de cliente PEM (activa TLS)"},
+	{Clave: "KAFKA_TLS_KEY", Desc: "clave del certificado de cliente"},
+	{Clave: "KAFKA_TLS_SERVER_NAME", Desc: "nombre esperado en el certificado del broker"},
+	{Clave: "KAFKA_TLS_INSECURE", Tipo: config.Bool, Defecto: "false", Desc: "no verificar el certificado (solo pruebas)"},
+
+	{Clave: "GRPC_TLS_CERT", Desc: "certificado PEM del server (activa TLS)"},
+	{Clave: "GRPC_TLS_KEY", Desc: "clave PEM del server"},
+	{Clave: "GRPC_TLS_CLIENT_CA", Desc: "CA de los clientes (activa mTLS)"},
+	{Clave: "GRPC_TLS_RELOAD", Tipo: config.Duracion, Defecto: "30s", Positivo: true, Desc: "cada cuánto se releen los certificados"},
+
+	{Clave: "GRPC_INTERCEPTORES", Desc: "interceptores: log,metricas,recuperar,deadline o ninguno (vacío: todos)"},
+	{Clave: "GRPC_LOG", Defecto: "todo", Valores: []string{"todo", "errores"}, Desc: "qué llamadas gRPC se loguean"},
+	{Clave: "GRPC_DEADLINE_MIN", Tipo: config.Duracion, Defecto: "5ms", Positivo: true, Desc: "deadline mínimo aceptado"},
+	{Clave: "GRPC_DEADLINE_MAX", Tipo: config.Duracion, Defecto: "10s", Desc: "deadline máximo (y el de las llamadas sin deadline)"},
+
+	{Clave: "VALKEY_MODE", Defecto: "standalone", Valores: valkeycon.Modos, Desc: "topología de Valkey (igual que el consumer)"},
+	{Clave: "VALKEY_ADDR", Tipo: config.Lista, Desc: "Valkey host:puerto (vacío: sin inventario ni catálogo)"},
+	{Clave: "VALKEY_ADDRS", Tipo: config.Lista, Desc: "direcciones (sentinels o nodos del cluster); reemplaza a VALKEY_ADDR"},
+	{Clave: "VALKEY_MASTER_NAME", Desc: "nombre del master en sentinel"},
+	{Clave: "VALKEY_DB", Tipo: config.Entero, Defecto: "0", Desc: "base de datos"},
+	{Clave: "VALKEY_USERNAME", Desc: "usuario ACL"},
+	{Clave: "VALKEY_PASSWORD", Secreto: true, Desc: "contraseña de Valkey"},
+	{Clave: "VALKEY_SENTINEL_USERNAME", Desc: "usuario de los sentinels"},
+	{Clave: "VALKEY_SENTINEL_PASSWORD", Secreto: true, Desc: "contraseña de los sentinels"},
+	{Clave: "VALKEY_TLS", Tipo: config.Bool, Defecto: "false", Desc: "TLS hacia Valkey"},
+	{Clave: "VALKEY_TLS_CA", Desc: "CA PEM (activa TLS)"},
+	{Clave: "VALKEY_TLS_CERT", Desc: "certificado de cliente PEM (activa TLS)"},
+	{Clave: "VALKEY_TLS_KEY", Desc: "clave del certificado de cliente"},
+	{Clave: "VALKEY_TLS_SERVER_NAME", Desc: "nombre esperado en el certificado"},
+	{Clave: "VALKEY_TLS_INSECURE", Tipo: config.Bool, Defecto: "false", Desc: "no verificar el certificado (solo pruebas)"},
+	{Clave: "VALKEY_DIAL_TIMEOUT", Tipo: config.Duracion, Defecto: "5s", Positivo: true, Desc: "timeout de conexión y del PING de arranque"},
+	{Clave: "VALKEY_TIMEOUT", Tipo: config.Duracion, Defecto: "3s", Positivo: true, Desc: "timeout de lectura y escritura"},
+	{Clave: "VALKEY_KEY_PREFIX", Defecto: keys.DefaultPrefix, Desc: "prefijo de keys (igual que el consumer)"},
+	{Clave: "VALKEY_HASH_TAG", Tipo: config.Bool, Defecto: "false", Desc: "envolver el prefijo en {} (forzado en cluster)"},
+	{Clave: "INVENTARIO_ESTRICTO", Tipo: config.Bool, Defecto: "false", Desc: "rechazar productos sin stock cargado"},
+	{Clave: "INVENTARIO_LIBERAR_TIMEOUT", Tipo: config.Duracion, Defecto: "2s", Positivo: true, Desc: "timeout para devolver una reserva si falla Kafka"},
+	{Clave: "STOCK_INICIAL", Desc: "JSON con stock inicial por producto"},
+	{Clave: "CATALOGO_VALIDAR", Tipo: config.Bool, Defecto: "false", Desc: "rechazar ventas de productos fuera del catálogo"},
+	{Clave: "CATALOGO_INICIAL", Desc: "JSON con productos a cargar al arrancar"},
+
+	{Clave: "LOG_LEVEL", Defecto: "info", Valores: []string{"debug", "info", "warn", "error"}, Desc: "nivel de log"},
+	{Clave: "LOG_FORMAT", Defecto: "json", Valores: []string{"json", "texto", "text"}, Desc: "formato de log"},
+	{Clave: "LOG_MUESTREO_OK", Tipo: config.Entero, Defecto: "100", Positivo: true, Desc: "se loguea 1 de cada N llamadas OK"},
+}
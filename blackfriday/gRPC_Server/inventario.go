@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"blackfriday/interceptores"
+	pb "blackfriday/proto"
+	"comun/config"
+	"comun/keys"
+)
+
+// inventario lleva el stock por producto en Valkey. Las keys son las del
+// paquete keys (Stock, StockInicial, StockAgotado), el mismo esquema que usa
+// el consumer para publicar restante y hora de agotamiento:
+//
+//	<prefix>:stock          HASH productoId -> unidades disponibles
+//	<prefix>:stock:inicial  HASH productoId -> unidades cargadas
+//	<prefix>:stock:agotado  HASH productoId -> unix ms en que llegó a 0
+//
+// Cada venta reserva su cantidad con un script Lua (lectura y decremento
+// atómicos), así dos réplicas del server no pueden vender la misma unidad.
+// Los productos sin stock cargado no se controlan, salvo con
+// INVENTARIO_ESTRICTO=true, donde se rechazan.
+type inventario struct {
+	rdb      redis.UniversalClient
+	stock    string
+	inicial  string
+	agotado  string
+	estricto bool
+	// timeout para devolver una reserva (INVENTARIO_LIBERAR_TIMEOUT)
+	liberarTimeout time.Duration
+}
+
+// Resultados especiales de scriptReservar (un resultado >= 0 es el stock
+// que queda después de reservar).
+const (
+	reservaInsuficiente = -1
+	reservaDesconocido  = -2
+	reservaSinControl   = -3
+)
+
+// scriptReservar descuenta ARGV[2] unidades de ARGV[1] si alcanzan.
+// KEYS: stock, agotado. ARGV: producto, cantidad, ahora (ms), estricto (0/1).
+var scriptReservar = redis.NewScript(`
+local s = redis.call('HGET', KEYS[1], ARGV[1])
+if not s then
+  if ARGV[4] == '1' then return -2 end
+  return -3
+end
+local n = tonumber(ARGV[2])
+if tonumber(s) < n then return -1 end
+local r = redis.call('HINCRBY', KEYS[1], ARGV[1], -n)
+if r == 0 then redis.call('HSETNX', KEYS[2], ARGV[1], ARGV[3]) end
+return r
+`)
+
+// scriptLiberar devuelve una reserva (la venta no llegó a Kafka).
+// KEYS: stock, agotado. ARGV: producto, cantidad.
+var scriptLiberar = redis.NewScript(`
+local r = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+if r > 0 then redis.call('HDEL', KEYS[2], ARGV[1]) end
+return r
+`)
+
+// scriptCargar fija, suma o carga-si-falta el stock de un producto y
+// mantiene la marca de agotado. Devuelve el stock resultante o -1 si en modo
+// si_falta el producto ya tenía stock.
+// KEYS: stock, inicial, agotado. ARGV: producto, cantidad, modo, ahora (ms).
+var scriptCargar = redis.NewScript(`
+local r
+if ARGV[3] == 'SUMAR' then
+  r = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+  redis.call('HINCRBY', KEYS[2], ARGV[1], ARGV[2])
+else
+  if ARGV[3] == 'SI_FALTA' and redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+    return -1
+  end
+  redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+  redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+  r = tonumber(ARGV[2])
+end
+if r > 0 then
+  redis.call('HDEL', KEYS[3], ARGV[1])
+else
+  redis.call('HSETNX', KEYS[3], ARGV[1], ARGV[4])
+end
+return r
+`)
+
+// inventarioDesdeEnv arma el inventario sobre la conexión a Valkey.
+//
+//	INVENTARIO_ESTRICTO  rechazar productos sin stock cargado
+//	STOCK_INICIAL        archivo JSON {"productoId": unidades, ...} que se
+//	                     carga al arrancar sin pisar el stock existente (ver
+//	                     cargarInicial)
+func inventarioDesdeEnv(rdb redis.UniversalClient, ks keys.Schema) *inventario {
+	return &inventario{
+		rdb:      rdb,
+		stock:    ks.Stock(),
+		inicial:  ks.StockInicial(),
+		agotado:  ks.StockAgotado(),
+		estricto: os.Getenv("INVENTARIO_ESTRICTO") == "true",
+
+		liberarTimeout: config.LeerDuracion("INVENTARIO_LIBERAR_TIMEOUT", 2*time.Second),
+	}
+}
+
+// cargarInicial carga STOCK_INICIAL, si está definido.
+func (inv *inventario) cargarInicial(ctx context.Context) error {
+	path := os.Getenv("STOCK_INICIAL")
+	if path == "" {
+		return nil
+	}
+	n, err := inv.cargarArchivo(ctx, path)
+	if err != nil {
+		return fmt.Errorf("STOCK_INICIAL: %w", err)
+	}
+	slog.Info("inventario: productos cargados", "cantidad", n, "archivo", path)
+	return nil
+}
+
+// reservar descuenta la cantidad vendida. Devuelve reservado=false si el
+// producto no se controla (no hay nada que liberar después).
+func (inv *inventario) reservar(ctx context.Context, productoID string, cantidad int32) (reservado bool, err error) {
+	if cantidad <= 0 {
+		return false, status.Errorf(codes.InvalidArgument, "cantidad inválida: %d", cantidad)
+	}
+	estricto := "0"
+	if inv.estricto {
+		estricto = "1"
+	}
+	r, err := scriptReservar.Run(ctx, inv.rdb, []string{inv.stock, inv.agotado},
+		productoID, cantidad, time.Now().UnixMilli(), estricto).Int64()
+	if err != nil {
+		return false, status.Errorf(codes.Unavailable, "inventario: %v", err)
+	}
+	switch r {
+	case reservaInsuficiente:
+		return false, status.Errorf(codes.FailedPrecondition, "producto %s agotado o sin stock suficiente para %d unidades", productoID, cantidad)
+	case reservaDesconocido:
+		return false, status.Errorf(codes.FailedPrecondition, "producto %s sin stock cargado", productoID)
+	case reservaSinControl:
+		return false, nil
+	}
+	return true, nil
+}
+
+// liberar devuelve una reserva cuyo evento no se pudo publicar.
+func (inv *inventario) liberar(ctx context.Context, productoID string, cantidad int32) {
+	// el ctx del request puede estar vencido justo cuando falló Kafka
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), inv.liberarTimeout)
+	defer cancel()
+	if err := scriptLiberar.Run(ctx, inv.rdb, []string{inv.stock, inv.agotado}, productoID, cantidad).Err(); err != nil {
+		slog.Error("inventario: no pude liberar la reserva", "rid", interceptores.RequestID(ctx), "producto_id", productoID, "cantidad", cantidad, "err", err)
+	}
+}
+
+// cargar aplica un StockProducto con el modo dado; devuelve false si en
+// modo SI_FALTA el producto ya tenía stock.
+func (inv *inventario) cargar(ctx context.Context, p *pb.StockProducto, modo pb.ModoCargaStock) (bool, error) {
+	r, err := scriptCargar.Run(ctx, inv.rdb, []string{inv.stock, inv.inicial, inv.agotado},
+		p.ProductoId, p.Cantidad, modo.String(), time.Now().UnixMilli()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return !(modo == pb.ModoCargaStock_SI_FALTA && r < 0), nil
+}
+
+func (inv *inventario) cargarArchivo(ctx context.Context, path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var stock map[string]int64
+	if err := json.Unmarshal(b, &stock); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	n := 0
+	for id, cant := range stock {
+		if id == "" || cant < 0 {
+			return n, fmt.Errorf("%s: stock inválido %q=%d", path, id, cant)
+		}
+		ok, err := inv.cargar(ctx, &pb.StockProducto{ProductoId: id, Cantidad: cant}, pb.ModoCargaStock_SI_FALTA)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// inventarioServer implementa el servicio de administración InventarioService.
+type inventarioServer struct {
+	pb.UnimplementedInventarioServiceServer
+	inv *inventario
+}
+
+func (s *inventarioServer) CargarStock(ctx context.Context, req *pb.CargarStockRequest) (*pb.CargarStockResponse, error) {
+	for _, p := range req.Productos {
+		if p.ProductoId == "" {
+			return nil, status.Error(codes.InvalidArgument, "producto_id vacío")
+		}
+		if p.Cantidad < 0 && req.Modo != pb.ModoCargaStock_SUMAR {
+			return nil, status.Errorf(codes.InvalidArgument, "cantidad negativa para %s", p.ProductoId)
+		}
+	}
+	var n int32
+	for _, p := range req.Productos {
+		ok, err := s.inv.cargar(ctx, p, req.Modo)
+		if err != nil {
+			return &pb.CargarStockResponse{Cargados: n}, status.Errorf(codes.Unavailable, "inventario: %v", err)
+		}
+		if ok {
+			n++
+		}
+	}
+	slog.Info("inventario: CargarStock", "rid", interceptores.RequestID(ctx), "modo", req.Modo.String(), "productos", len(req.Productos), "cargados", n)
+	return &pb.CargarStockResponse{Cargados: n}, nil
+}
+
+func (s *inventarioServer) ConsultarStock(ctx context.Context, req *pb.ConsultarStockRequest) (*pb.ConsultarStockResponse, error) {
+	resp := &pb.ConsultarStockResponse{}
+	if len(req.ProductoIds) == 0 {
+		todos, err := s.inv.rdb.HGetAll(ctx, s.inv.stock).Result()
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "inventario: %v", err)
+		}
+		for id, v := range todos {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			resp.Productos = append(resp.Productos, &pb.StockProducto{ProductoId: id, Cantidad: n})
+		}
+		return resp, nil
+	}
+
+	vals, err := s.inv.rdb.HMGet(ctx, s.inv.stock, req.ProductoIds...).Result()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "inventario: %v", err)
+	}
+	for i, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue // sin stock cargado
+		}
+		n, _ := strconv.ParseInt(str, 10, 64)
+		resp.Productos = append(resp.Productos, &pb.StockProducto{ProductoId: req.ProductoIds[i], Cantidad: n})
+	}
+	return resp, nil
+}
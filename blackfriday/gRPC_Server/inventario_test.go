@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "blackfriday/proto"
+	"comun/keys"
+)
+
+func nuevoInventarioPrueba(t *testing.T, estricto bool) (*inventario, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	ks := keys.New("venta", false)
+	return &inventario{
+		rdb:            rdb,
+		stock:          ks.Stock(),
+		inicial:        ks.StockInicial(),
+		agotado:        ks.StockAgotado(),
+		estricto:       estricto,
+		liberarTimeout: time.Second,
+	}, mr
+}
+
+func cargarStock(t *testing.T, inv *inventario, id string, n int64) {
+	t.Helper()
+	if _, err := inv.cargar(context.Background(), &pb.StockProducto{ProductoId: id, Cantidad: n}, pb.ModoCargaStock_REEMPLAZAR); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func stockDe(t *testing.T, mr *miniredis.Miniredis, inv *inventario, id string) int {
+	t.Helper()
+	n, err := strconv.Atoi(mr.HGet(inv.stock, id))
+	if err != nil {
+		t.Fatalf("stock de %s: %v", id, err)
+	}
+	return n
+}
+
+// Muchas reservas en paralelo sobre el mismo producto no venden más de lo
+// que hay: el script lee y descuenta en un solo paso.
+func TestReservarSinSobreventa(t *testing.T) {
+	inv, mr := nuevoInventarioPrueba(t, false)
+	cargarStock(t, inv, "p1", 100)
+
+	var ok, agotado atomic.Int32
+	var wg sync.WaitGroup
+	for range 60 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservado, err := inv.reservar(context.Background(), "p1", 3)
+			switch {
+			case err == nil && reservado:
+				ok.Add(1)
+			case status.Code(err) == codes.FailedPrecondition:
+				agotado.Add(1)
+			default:
+				t.Errorf("reservar: %v, %v", reservado, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ok.Load() != 33 || agotado.Load() != 27 {
+		t.Fatalf("%d reservas y %d rechazos, esperaba 33 y 27", ok.Load(), agotado.Load())
+	}
+	if n := stockDe(t, mr, inv, "p1"); n != 1 {
+		t.Fatalf("stock %d, esperaba 1", n)
+	}
+	if mr.HGet(inv.agotado, "p1") != "" {
+		t.Fatal("marcado agotado con 1 unidad")
+	}
+
+	// la última unidad lo agota y deja la hora
+	if reservado, err := inv.reservar(context.Background(), "p1", 1); err != nil || !reservado {
+		t.Fatalf("última unidad: %v, %v", reservado, err)
+	}
+	if mr.HGet(inv.agotado, "p1") == "" {
+		t.Fatal("sin marca de agotado con stock 0")
+	}
+}
+
+func TestReservarCodigos(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		estricto  bool
+		producto  string
+		cantidad  int32
+		reservado bool
+		codigo    codes.Code
+	}{
+		{"alcanza", false, "p1", 5, true, codes.OK},
+		{"no alcanza", false, "p1", 6, false, codes.FailedPrecondition},
+		{"sin stock cargado", false, "otro", 1, false, codes.OK},
+		{"sin stock cargado estricto", true, "otro", 1, false, codes.FailedPrecondition},
+		{"cantidad cero", false, "p1", 0, false, codes.InvalidArgument},
+		{"cantidad negativa", false, "p1", -1, false, codes.InvalidArgument},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			inv, mr := nuevoInventarioPrueba(t, c.estricto)
+			cargarStock(t, inv, "p1", 5)
+			reservado, err := inv.reservar(context.Background(), c.producto, c.cantidad)
+			if reservado != c.reservado || status.Code(err) != c.codigo {
+				t.Fatalf("reservar = %v, %v; esperaba %v, %v", reservado, err, c.reservado, c.codigo)
+			}
+			if c.codigo != codes.OK && stockDe(t, mr, inv, "p1") != 5 {
+				t.Fatal("un rechazo modificó el stock")
+			}
+		})
+	}
+
+	t.Run("valkey caído", func(t *testing.T) {
+		inv, mr := nuevoInventarioPrueba(t, false)
+		mr.Close()
+		if _, err := inv.reservar(context.Background(), "p1", 1); status.Code(err) != codes.Unavailable {
+			t.Fatalf("err %v, esperaba Unavailable", err)
+		}
+	})
+}
+
+func TestCargar(t *testing.T) {
+	inv, mr := nuevoInventarioPrueba(t, false)
+	ctx := context.Background()
+	cargar := func(id string, n int64, modo pb.ModoCargaStock) bool {
+		t.Helper()
+		ok, err := inv.cargar(ctx, &pb.StockProducto{ProductoId: id, Cantidad: n}, modo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !cargar("p1", 0, pb.ModoCargaStock_REEMPLAZAR) || mr.HGet(inv.agotado, "p1") == "" {
+		t.Fatal("cargar 0 no marcó agotado")
+	}
+	if !cargar("p1", 10, pb.ModoCargaStock_SUMAR) || stockDe(t, mr, inv, "p1") != 10 || mr.HGet(inv.agotado, "p1") != "" {
+		t.Fatal("SUMAR no repuso ni quitó la marca de agotado")
+	}
+	if mr.HGet(inv.inicial, "p1") != "10" {
+		t.Fatalf("inicial %q, esperaba 10", mr.HGet(inv.inicial, "p1"))
+	}
+	if cargar("p1", 50, pb.ModoCargaStock_SI_FALTA) || stockDe(t, mr, inv, "p1") != 10 {
+		t.Fatal("SI_FALTA pisó un stock existente")
+	}
+	if !cargar("p2", 7, pb.ModoCargaStock_SI_FALTA) || stockDe(t, mr, inv, "p2") != 7 {
+		t.Fatal("SI_FALTA no cargó un producto nuevo")
+	}
+	if !cargar("p1", 3, pb.ModoCargaStock_REEMPLAZAR) || stockDe(t, mr, inv, "p1") != 3 || mr.HGet(inv.inicial, "p1") != "3" {
+		t.Fatal("REEMPLAZAR no fijó stock e inicial")
+	}
+}
+
+// escritorFalso reemplaza al *kafka.Writer.
+type escritorFalso struct {
+	err      error
+	mensajes []kafka.Message
+}
+
+func (e *escritorFalso) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.mensajes = append(e.mensajes, msgs...)
+	return nil
+}
+
+// Si Kafka no acepta el evento la venta no existe: la reserva se devuelve,
+// incluida la marca de agotado si esa venta lo había agotado.
+func TestProcesarVentaLiberaSiFallaKafka(t *testing.T) {
+	inv, mr := nuevoInventarioPrueba(t, false)
+	cargarStock(t, inv, "p1", 4)
+	part, err := nuevoParticionado("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kw := &escritorFalso{err: errors.New("broker caído")}
+	s := &server{kw: kw, part: part, inv: inv}
+	venta := func(n int32) (*pb.ProductSaleResponse, error) {
+		return s.ProcesarVenta(context.Background(), &pb.ProductSaleRequest{
+			Categoria: pb.CategoriaProducto_Electronica, ProductoId: "p1", Precio: 10, CantidadVendida: n,
+		})
+	}
+
+	resp, err := venta(4)
+	if err != nil || resp.Estado != "ERROR_KAFKA" {
+		t.Fatalf("respuesta %v, %v; esperaba ERROR_KAFKA", resp, err)
+	}
+	if n := stockDe(t, mr, inv, "p1"); n != 4 {
+		t.Fatalf("stock %d después de fallar Kafka, esperaba 4", n)
+	}
+	if mr.HGet(inv.agotado, "p1") != "" {
+		t.Fatal("quedó la marca de agotado de una venta que no existió")
+	}
+
+	kw.err = nil
+	if resp, err := venta(3); err != nil || resp.Estado != "OK" || len(kw.mensajes) != 1 {
+		t.Fatalf("respuesta %v, %v con %d mensajes", resp, err, len(kw.mensajes))
+	}
+	if n := stockDe(t, mr, inv, "p1"); n != 1 {
+		t.Fatalf("stock %d después de vender 3, esperaba 1", n)
+	}
+
+	// sin stock suficiente no se publica nada y el gateway recibe FailedPrecondition
+	if _, err := venta(2); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("err %v, esperaba FailedPrecondition", err)
+	}
+	if len(kw.mensajes) != 1 {
+		t.Fatal("se publicó una venta rechazada por stock")
+	}
+}
@@ -3,34 +3,100 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"blackfriday/auth"
+	"blackfriday/certs"
+	"blackfriday/interceptores"
 	pb "blackfriday/proto"
+	"blackfriday/registro"
+	"comun/config"
+	"comun/kafkaseg"
+	"comun/keys"
+	"comun/valkeycon"
 )
 
+// escritorVentas es lo que el server usa de *kafka.Writer; los tests de
+// inventario (inventario_test.go) lo reemplazan por uno en memoria.
+type escritorVentas interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
 type server struct {
 	pb.UnimplementedProductSaleServiceServer
-	kw *kafka.Writer
+	kw   escritorVentas
+	part particionado
+	inv  *inventario // nil sin VALKEY_ADDR: no se controla stock
+	cat  *catalogo   // nil sin VALKEY_ADDR
 }
 
+// estados cuenta las respuestas por Estado (OK, ERROR_KAFKA, AGOTADO, ...); se
+// publica en /debug/vars para las reglas de avisos del consumer.
+var estados = expvar.NewMap("estados")
+
 type SaleEvent struct {
 	Categoria       string  `json:"categoria"`
 	ProductoId      string  `json:"productoId"`
 	Precio          float64 `json:"precio"`
 	CantidadVendida int32   `json:"cantidadVendida"`
 	TimestampUnixMs int64   `json:"timestampUnixMs"`
+	Tenant          string  `json:"tenant,omitempty"` // tienda autenticada en el gateway
 }
 
 func (s *server) ProcesarVenta(ctx context.Context, req *pb.ProductSaleRequest) (*pb.ProductSaleResponse, error) {
-	log.Printf("gRPC: venta recibida categoria=%s producto_id=%s precio=%.2f cantidad=%d",
-		req.Categoria.String(), req.ProductoId, req.Precio, req.CantidadVendida)
+	tenant := tenantDe(ctx)
+	rid := interceptores.RequestID(ctx)
+	lg := slog.With("rid", rid, "producto_id", req.ProductoId, "tenant", tenant)
+	lg.Debug("venta recibida", "categoria", req.Categoria.String(), "precio", req.Precio, "cantidad", req.CantidadVendida)
+
+	// El producto tiene que existir en el catálogo con esa categoría
+	if s.cat != nil && s.cat.validar {
+		if err := s.cat.verificar(ctx, req.ProductoId, req.Categoria); err != nil {
+			switch status.Code(err) {
+			case codes.NotFound:
+				estados.Add("PRODUCTO_DESCONOCIDO", 1)
+			case codes.InvalidArgument:
+				estados.Add("CATEGORIA_INCORRECTA", 1)
+			default:
+				estados.Add("ERROR_CATALOGO", 1)
+			}
+			lg.Warn("venta rechazada por catálogo", "err", status.Convert(err).Message())
+			return nil, err
+		}
+	}
+
+	// Reserva de stock antes de publicar: si no alcanza, la venta no existe
+	reservado := false
+	if s.inv != nil {
+		var err error
+		reservado, err = s.inv.reservar(ctx, req.ProductoId, req.CantidadVendida)
+		if err != nil {
+			if status.Code(err) == codes.FailedPrecondition {
+				estados.Add("AGOTADO", 1)
+			} else {
+				estados.Add("ERROR_INVENTARIO", 1)
+			}
+			lg.Warn("venta rechazada por inventario", "err", status.Convert(err).Message())
+			return nil, err
+		}
+	}
 
 	ev := SaleEvent{
 		Categoria:       req.Categoria.String(),
@@ -38,57 +104,210 @@ func (s *server) ProcesarVenta(ctx context.Context, req *pb.ProductSaleRequest)
 		Precio:          req.Precio,
 		CantidadVendida: req.CantidadVendida,
 		TimestampUnixMs: time.Now().UnixMilli(),
+		Tenant:          tenant,
 	}
 
 	b, err := json.Marshal(ev)
 	if err != nil {
-		log.Printf("Error serializando evento: %v", err)
+		lg.Error("error serializando evento", "err", err)
+		if reservado {
+			s.inv.liberar(ctx, req.ProductoId, req.CantidadVendida)
+		}
+		estados.Add("ERROR_SERIALIZE", 1)
 		return &pb.ProductSaleResponse{Estado: "ERROR_SERIALIZE"}, nil
 	}
 
-	// Produce a Kafka
-	if err := s.kw.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(req.ProductoId), // clave de partición
+	// Produce a Kafka; el request ID va en un header para los logs del consumer
+	msg := kafka.Message{
+		Key:   s.part.clave(ev), // ver particionado
 		Value: b,
-	}); err != nil {
-		log.Printf("Kafka write error: %v", err)
+	}
+	if rid != "" {
+		msg.Headers = []kafka.Header{{Key: interceptores.MetadataRequestID, Value: []byte(rid)}}
+	}
+	if err := s.kw.WriteMessages(ctx, msg); err != nil {
+		lg.Error("error escribiendo en Kafka", "err", err)
+		if reservado {
+			s.inv.liberar(ctx, req.ProductoId, req.CantidadVendida)
+		}
+		estados.Add("ERROR_KAFKA", 1)
 		return &pb.ProductSaleResponse{Estado: "ERROR_KAFKA"}, nil
 	}
 
+	estados.Add("OK", 1)
 	return &pb.ProductSaleResponse{Estado: "OK"}, nil
 }
 
+// tenantDe lee el tenant que puso el gateway en la metadata. El server
+// confía en el gateway: para que otro cliente no pueda fijar un tenant
+// arbitrario conviene exigir mTLS (GRPC_TLS_CLIENT_CA).
+func tenantDe(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(auth.MetadataTenant); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 func main() {
-	grpcPort := os.Getenv("GRPC_PORT")
-	if grpcPort == "" {
-		grpcPort = "50051"
+	// Archivo (--config), entorno y flags; --print-config muestra el resultado
+	cfg, _, err := config.Cargar("grpc-server", opciones, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Config inválida:\n%v", err)
+	}
+	if cfg.Imprimir {
+		cfg.Escribir(os.Stdout)
+		return
+	}
+	if err := registro.Configurar("grpc-server"); err != nil {
+		log.Fatalf("Logs: %v", err)
 	}
 
+	grpcPort := os.Getenv("GRPC_PORT")
 	brokers := os.Getenv("KAFKA_BROKERS") // ej: "kafka:9092" o "my-cluster-kafka-bootstrap:9092"
-	if brokers == "" {
-		brokers = "kafka:9092"
-	}
 	topic := os.Getenv("KAFKA_TOPIC")
-	if topic == "" {
-		topic = "ventas"
+
+	seg, err := kafkaseg.Nueva(kafkaseg.DesdeEnv())
+	if err != nil {
+		log.Fatalf("Config Kafka inválida:\n%v", err)
+	}
+	if seg.PlanoConPassword() {
+		slog.Warn("KAFKA_SASL_MECHANISM=PLAIN sin TLS: la contraseña viaja sin cifrar")
+	}
+
+	part, err := nuevoParticionado(os.Getenv("KAFKA_PARTICIONADO"))
+	if err != nil {
+		log.Fatalf("Config inválida: %v", err)
 	}
 
 	kw := &kafka.Writer{
-		Addr:     kafka.TCP(strings.Split(brokers, ",")...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+		Transport:    seg.Transport(config.LeerDuracion("KAFKA_TIMEOUT", 10*time.Second)),
+		Topic:        topic,
+		Balancer:     part.balancer,
+		BatchSize:    config.LeerEntero("KAFKA_BATCH_SIZE", 100),
+		BatchTimeout: config.LeerDuracion("KAFKA_BATCH_TIMEOUT", time.Second),
+		WriteTimeout: config.LeerDuracion("KAFKA_WRITE_TIMEOUT", 10*time.Second),
 	}
 	defer kw.Close()
 
+	// Inventario y catálogo en Valkey (opcionales), con la conexión y el
+	// esquema de keys del consumer (paquetes valkeycon y keys)
+	var (
+		inv *inventario
+		cat *catalogo
+	)
+	valkeyCfg, err := valkeycon.DesdeEnv()
+	if err != nil {
+		log.Fatalf("Config Valkey inválida:\n%v", err)
+	}
+	if len(valkeyCfg.Addrs) > 0 {
+		rdb, err := valkeycon.Nuevo(valkeyCfg)
+		if err != nil {
+			log.Fatalf("Config Valkey inválida: %v", err)
+		}
+		defer rdb.Close()
+		ks := keys.New(valkeyCfg.Prefijo, valkeyCfg.HashTag)
+		inv = inventarioDesdeEnv(rdb, ks)
+		cat = catalogoDesdeEnv(rdb, ks)
+		cargarIniciales := func(ctx context.Context) error {
+			return errors.Join(inv.cargarInicial(ctx), cat.cargarInicial(ctx))
+		}
+
+		// Sin Valkey al arrancar el server atiende igual: lo que use
+		// inventario o catálogo responde Unavailable hasta que vuelva, y
+		// recién entonces se cargan STOCK_INICIAL y CATALOGO_INICIAL.
+		ctx := context.Background()
+		if err := valkeycon.Ping(ctx, rdb, valkeyCfg.DialTimeout); err != nil {
+			slog.Error("Valkey no disponible al arrancar: inventario y catálogo degradados", "valkey", valkeyCfg.String(), "err", err)
+			go func() {
+				if valkeycon.Esperar(ctx, rdb, valkeyCfg.DialTimeout) != nil {
+					return
+				}
+				slog.Info("Valkey disponible", "valkey", valkeyCfg.String())
+				if err := cargarIniciales(ctx); err != nil {
+					slog.Error("carga inicial", "err", err)
+				}
+			}()
+		} else if err := cargarIniciales(ctx); err != nil {
+			log.Fatalf("Carga inicial: %v", err)
+		}
+		slog.Info("inventario y catálogo activos", "valkey", valkeyCfg.String(), "prefijo", ks.Prefix(), "estricto", inv.estricto, "validar", cat.validar)
+	} else {
+		slog.Info("inventario y catálogo desactivados: VALKEY_ADDR vacío")
+	}
+
+	// Métricas (expvar) en /debug/vars
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, nil); err != nil {
+			slog.Error("servidor de métricas", "err", err)
+		}
+	}()
+
 	lis, err := net.Listen("tcp", ":"+grpcPort)
 	if err != nil {
 		log.Fatalf("No pude escuchar :%s: %v", grpcPort, err)
 	}
 
-	grpcSrv := grpc.NewServer()
-	pb.RegisterProductSaleServiceServer(grpcSrv, &server{kw: kw})
+	// TLS opcional; con GRPC_TLS_CLIENT_CA además exige certificado de
+	// cliente (mTLS). Los archivos se recargan cada GRPC_TLS_RELOAD.
+	var opts []grpc.ServerOption
+	if cert := os.Getenv("GRPC_TLS_CERT"); cert != "" {
+		rec, err := certs.Nuevo(certs.Archivos{
+			Cert: cert,
+			Key:  os.Getenv("GRPC_TLS_KEY"),
+			CA:   os.Getenv("GRPC_TLS_CLIENT_CA"),
+		})
+		if err != nil {
+			log.Fatalf("TLS: %v", err)
+		}
+		cfg, err := rec.ConfigServidor()
+		if err != nil {
+			log.Fatalf("TLS: %v", err)
+		}
+		go rec.Vigilar(context.Background(), config.LeerDuracion("GRPC_TLS_RELOAD", 30*time.Second))
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+		slog.Info("gRPC con TLS", "cert", cert, "mtls", os.Getenv("GRPC_TLS_CLIENT_CA") != "")
+	}
+
+	// Con GRPC_MAX_CONNECTION_AGE las conexiones se cierran cada tanto y los
+	// clientes con round_robin vuelven a resolver el DNS y ven réplicas nuevas
+	if d := config.LeerDuracion("GRPC_MAX_CONNECTION_AGE", 0); d > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge:      d,
+			MaxConnectionAgeGrace: 10 * time.Second,
+		}))
+		slog.Info("gRPC: conexiones con vida máxima", "max_connection_age", d.String())
+	}
+
+	// Recuperación de panics, log, métricas y límites de deadline
+	icfg, err := interceptores.DesdeEnv("servidor")
+	if err != nil {
+		log.Fatalf("Interceptores: %v", err)
+	}
+	opts = append(opts, icfg.OpcionesServidor()...)
+	slog.Info("interceptores gRPC", "cadena", icfg.String())
+
+	grpcSrv := grpc.NewServer(opts...)
+	pb.RegisterProductSaleServiceServer(grpcSrv, &server{kw: kw, part: part, inv: inv, cat: cat})
+	if inv != nil {
+		admin, err := servirAdmin(opts, inv, cat)
+		if err != nil {
+			log.Fatalf("gRPC admin: %v", err)
+		}
+		if admin != nil {
+			defer admin.Stop()
+		}
+	}
 
-	log.Printf("gRPC Server escuchando :%s | Kafka brokers=%s topic=%s", grpcPort, brokers, topic)
+	slog.Info("gRPC Server escuchando", "puerto", grpcPort, "brokers", brokers, "topic", topic, "kafka", seg.String(), "particionado", part.modo)
 	if err := grpcSrv.Serve(lis); err != nil {
 		log.Fatalf("gRPC Serve error: %v", err)
 	}
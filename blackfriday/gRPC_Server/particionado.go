@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Particionado de las ventas en el topic (KAFKA_PARTICIONADO). El consumer
+// procesa cada partición en orden, así que lo que garantiza el orden es qué
+// mensajes caen juntos:
+//
+//	producto     (por defecto) key = productoId, murmur2 como el
+//	             DefaultPartitioner de Java: todas las ventas de un producto
+//	             van a la misma partición y se procesan en el orden en que se
+//	             publicaron. Un producto Java con la misma key elige la misma
+//	             partición.
+//	categoria    key = categoría, murmur2: orden por categoría (y por lo tanto
+//	             por producto), pero con pocas categorías la carga se reparte
+//	             mal entre particiones.
+//	round_robin  key = productoId, pero se ignora: reparto parejo y sin orden
+//	             entre ventas del mismo producto.
+//	least_bytes  key = productoId, pero se ignora: va a la partición con menos
+//	             bytes pendientes en este producer; sin orden.
+//
+// Con producto o categoria el orden vale mientras no cambie la cantidad de
+// particiones del topic: al agregar particiones una key puede pasar a otra y
+// convivir un rato con mensajes viejos en la anterior. El orden es el de
+// llegada a este server; entre réplicas del gRPC server no hay un orden
+// global (dos ventas simultáneas del mismo producto en réplicas distintas
+// pueden quedar en cualquier orden).
+type particionado struct {
+	modo     string
+	balancer kafka.Balancer
+	clave    func(SaleEvent) []byte
+}
+
+// Modos de KAFKA_PARTICIONADO.
+var modosParticionado = []string{"producto", "categoria", "round_robin", "least_bytes"}
+
+func nuevoParticionado(modo string) (particionado, error) {
+	porProducto := func(ev SaleEvent) []byte { return []byte(ev.ProductoId) }
+	switch modo {
+	case "", "producto":
+		return particionado{"producto", kafka.Murmur2Balancer{}, porProducto}, nil
+	case "categoria":
+		return particionado{modo, kafka.Murmur2Balancer{}, func(ev SaleEvent) []byte { return []byte(ev.Categoria) }}, nil
+	case "round_robin":
+		return particionado{modo, &kafka.RoundRobin{}, porProducto}, nil
+	case "least_bytes":
+		return particionado{modo, &kafka.LeastBytes{}, porProducto}, nil
+	}
+	return particionado{}, fmt.Errorf("KAFKA_PARTICIONADO inválido: %q (%s)", modo, strings.Join(modosParticionado, "|"))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Vectores de org.apache.kafka.common.utils.Utils.murmur2 (Java). El
+// DefaultPartitioner elige la partición toPositive(murmur2(key)) % n, con
+// toPositive = h & 0x7fffffff.
+var vectoresMurmur2 = []struct {
+	key string
+	h   uint32
+}{
+	{"kafka", 0xd067cf64},
+	{"1234", 0x9fc97b14},
+	{"4", 0x5a4b5ca1},
+}
+
+func particionJava(h uint32, n int) int {
+	return int(h&0x7fffffff) % n
+}
+
+func particiones(n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	return p
+}
+
+func TestParticionadoComoJava(t *testing.T) {
+	p, err := nuevoParticionado("producto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vectoresMurmur2 {
+		for _, n := range []int{1, 3, 6, 7, 12, 100} {
+			key := p.clave(SaleEvent{ProductoId: v.key})
+			got := p.balancer.Balance(kafka.Message{Key: key}, particiones(n)...)
+			if want := particionJava(v.h, n); got != want {
+				t.Errorf("key %q con %d particiones: %d, Java elige %d", v.key, n, got, want)
+			}
+		}
+	}
+}
+
+func TestParticionadoCategoria(t *testing.T) {
+	p, err := nuevoParticionado("categoria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := p.clave(SaleEvent{ProductoId: "otro", Categoria: "kafka"})
+	if got, want := p.balancer.Balance(kafka.Message{Key: key}, particiones(12)...), particionJava(0xd067cf64, 12); got != want {
+		t.Errorf("categoría kafka con 12 particiones: %d, Java elige %d", got, want)
+	}
+}
+
+func TestNuevoParticionado(t *testing.T) {
+	for _, modo := range append([]string{""}, modosParticionado...) {
+		if _, err := nuevoParticionado(modo); err != nil {
+			t.Errorf("%q: %v", modo, err)
+		}
+	}
+	if p, _ := nuevoParticionado(""); p.modo != "producto" {
+		t.Errorf("modo por defecto %q, esperaba producto", p.modo)
+	}
+	if _, err := nuevoParticionado("hash"); err == nil {
+		t.Error("modo inválido aceptado")
+	}
+}
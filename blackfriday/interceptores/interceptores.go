@@ -0,0 +1,365 @@
+// Package interceptores arma la cadena de interceptores gRPC (unary y
+// stream) que comparten el gRPC server y el cliente Go del gateway:
+//
+//   - request ID: viaja en la metadata x-request-id; el cliente lo genera si
+//     no viene y el server lo toma (o genera) y lo deja en el contexto.
+//   - log: una línea slog por llamada con request ID, método, código y
+//     duración. Los errores van siempre (warn, o error si son Internal,
+//     Unknown o DataLoss); las llamadas OK se muestrean (LOG_MUESTREO_OK).
+//   - métricas: por método, llamadas, en vuelo, códigos y latencia, en
+//     /debug/vars bajo grpc_servidor / grpc_cliente.
+//   - recuperar: un panic en el handler (o en el cliente) se convierte en
+//     codes.Internal en vez de tirar el proceso.
+//   - deadline (solo server): rechaza con DeadlineExceeded las llamadas con
+//     menos tiempo que DeadlineMin y recorta a DeadlineMax las que no traen
+//     deadline o traen uno más largo.
+//
+// El orden es fijo: request ID, log y métricas afuera (ven el código final,
+// incluido el Internal de un panic o el rechazo por deadline), después
+// recuperar y deadline, y por último el handler.
+package interceptores
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"blackfriday/registro"
+)
+
+// MetadataRequestID es la key de metadata con el request ID.
+const MetadataRequestID = "x-request-id"
+
+// Config elige qué interceptores van en la cadena.
+type Config struct {
+	Lado           string // "servidor" o "cliente": prefijo de logs y métricas
+	Recuperar      bool
+	Log            bool
+	LogSoloErrores bool // no loguear las llamadas OK
+	Metricas       bool
+	DeadlineMin    time.Duration // solo servidor; 0 no controla
+	DeadlineMax    time.Duration // solo servidor; 0 no recorta
+}
+
+// DesdeEnv lee la configuración:
+//
+//	GRPC_INTERCEPTORES  lista entre log,metricas,recuperar,deadline
+//	                    (por defecto todos; "ninguno" los desactiva)
+//	GRPC_LOG            "todo" (por defecto) o "errores"
+//	GRPC_DEADLINE_MIN   deadline mínimo aceptado por el server (por defecto 5ms)
+//	GRPC_DEADLINE_MAX   deadline máximo del server (por defecto 10s)
+//
+// En el cliente "deadline" no aplica y los GRPC_DEADLINE_* se ignoran.
+// El request ID va siempre: no cuesta nada y lo usan los logs de la
+// aplicación.
+func DesdeEnv(lado string) (Config, error) {
+	activos := []string{"log", "metricas", "recuperar", "deadline"}
+	if v := strings.TrimSpace(os.Getenv("GRPC_INTERCEPTORES")); v == "ninguno" {
+		activos = nil
+	} else if v != "" {
+		activos = nil
+		for _, x := range strings.Split(v, ",") {
+			x = strings.TrimSpace(x)
+			switch x {
+			case "log", "metricas", "recuperar", "deadline":
+				activos = append(activos, x)
+			case "":
+			default:
+				return Config{}, fmt.Errorf("GRPC_INTERCEPTORES: %q desconocido", x)
+			}
+		}
+	}
+
+	cfg := Config{
+		Lado:      lado,
+		Log:       slices.Contains(activos, "log"),
+		Metricas:  slices.Contains(activos, "metricas"),
+		Recuperar: slices.Contains(activos, "recuperar"),
+	}
+	switch os.Getenv("GRPC_LOG") {
+	case "", "todo":
+	case "errores":
+		cfg.LogSoloErrores = true
+	default:
+		return Config{}, fmt.Errorf("GRPC_LOG: %q, se espera todo o errores", os.Getenv("GRPC_LOG"))
+	}
+	if lado == "servidor" && slices.Contains(activos, "deadline") {
+		var err error
+		if cfg.DeadlineMin, err = duracion("GRPC_DEADLINE_MIN", 5*time.Millisecond); err != nil {
+			return Config{}, err
+		}
+		if cfg.DeadlineMax, err = duracion("GRPC_DEADLINE_MAX", 10*time.Second); err != nil {
+			return Config{}, err
+		}
+		if cfg.DeadlineMax > 0 && cfg.DeadlineMin > cfg.DeadlineMax {
+			return Config{}, fmt.Errorf("GRPC_DEADLINE_MIN (%s) mayor que GRPC_DEADLINE_MAX (%s)", cfg.DeadlineMin, cfg.DeadlineMax)
+		}
+	}
+	return cfg, nil
+}
+
+func duracion(k string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(k)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: duración inválida %q", k, v)
+	}
+	return d, nil
+}
+
+// String resume la cadena para el log de arranque.
+func (c Config) String() string {
+	var p []string
+	if c.Log {
+		if c.LogSoloErrores {
+			p = append(p, "log(errores)")
+		} else {
+			p = append(p, "log")
+		}
+	}
+	if c.Metricas {
+		p = append(p, "metricas")
+	}
+	if c.Recuperar {
+		p = append(p, "recuperar")
+	}
+	if c.DeadlineMin > 0 || c.DeadlineMax > 0 {
+		p = append(p, fmt.Sprintf("deadline[%s,%s]", c.DeadlineMin, c.DeadlineMax))
+	}
+	return "request_id," + strings.Join(p, ",")
+}
+
+// requestIDKey guarda el request ID en el contexto del handler.
+type requestIDKey struct{}
+
+// RequestID devuelve el request ID de la llamada: el que dejó el
+// interceptor del server, o el de la metadata saliente en el cliente.
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if v := md.Get(MetadataRequestID); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// NuevoRequestID genera un ID aleatorio de 16 caracteres hex.
+func NuevoRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// idEntrante toma el request ID de la metadata o genera uno.
+func idEntrante(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataRequestID); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	return NuevoRequestID()
+}
+
+// idSaliente asegura que la metadata saliente tenga request ID.
+func idSaliente(ctx context.Context) (context.Context, string) {
+	if id := RequestID(ctx); id != "" {
+		return ctx, id
+	}
+	id := NuevoRequestID()
+	return metadata.AppendToOutgoingContext(ctx, MetadataRequestID, id), id
+}
+
+// muestreoOK limita las líneas de llamadas OK, que son una por venta.
+var muestreoOK registro.Muestreo
+
+// registrar loguea y mide una llamada terminada.
+func (c Config) registrar(m *metricas, id, metodo string, inicio time.Time, err error) {
+	dur := time.Since(inicio)
+	code := status.Code(err)
+	if c.Metricas {
+		m.fin(metodo, code, dur)
+	}
+	if !c.Log {
+		return
+	}
+	attrs := []any{
+		"lado", c.Lado, "rid", id, "metodo", metodo, "code", code.String(),
+		"dur_ms", float64(dur.Microseconds()) / 1000,
+	}
+	switch code {
+	case codes.OK:
+		if !c.LogSoloErrores && muestreoOK.Toca() {
+			slog.Info("llamada gRPC", attrs...)
+		}
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		slog.Error("llamada gRPC", append(attrs, "err", status.Convert(err).Message())...)
+	default:
+		slog.Warn("llamada gRPC", append(attrs, "err", status.Convert(err).Message())...)
+	}
+}
+
+// recuperado convierte un panic en codes.Internal.
+func (c Config) recuperado(id, metodo string, p any) error {
+	slog.Error("panic en llamada gRPC", "lado", c.Lado, "rid", id, "metodo", metodo,
+		"panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+	return status.Errorf(codes.Internal, "error interno (request %s)", id)
+}
+
+// deadline aplica DeadlineMin/DeadlineMax al contexto entrante.
+func (c Config) deadline(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	d, ok := ctx.Deadline()
+	if ok && c.DeadlineMin > 0 {
+		if resta := time.Until(d); resta < c.DeadlineMin {
+			return ctx, func() {}, status.Errorf(codes.DeadlineExceeded, "deadline de %s menor al mínimo %s", resta.Round(time.Millisecond), c.DeadlineMin)
+		}
+	}
+	if c.DeadlineMax > 0 && (!ok || time.Until(d) > c.DeadlineMax) {
+		ctx, cancel := context.WithTimeout(ctx, c.DeadlineMax)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// OpcionesServidor devuelve las opciones de grpc.NewServer con la cadena.
+func (c Config) OpcionesServidor() []grpc.ServerOption {
+	m := metricasDe(c.Lado)
+	aplicarDeadline := c.DeadlineMin > 0 || c.DeadlineMax > 0
+
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		id := idEntrante(ctx)
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+		inicio := time.Now()
+		if c.Metricas {
+			m.inicio(info.FullMethod)
+		}
+		defer func() { c.registrar(m, id, info.FullMethod, inicio, err) }()
+		if c.Recuperar {
+			defer func() {
+				if p := recover(); p != nil {
+					err = c.recuperado(id, info.FullMethod, p)
+				}
+			}()
+		}
+		if aplicarDeadline {
+			var cancel context.CancelFunc
+			ctx, cancel, err = c.deadline(ctx)
+			defer cancel()
+			if err != nil {
+				return err
+			}
+		}
+		return handler(srv, &streamServidor{ServerStream: ss, ctx: ctx})
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.unaryServidor(m)),
+		grpc.ChainStreamInterceptor(stream),
+	}
+}
+
+// unaryServidor es la cadena unary del server.
+func (c Config) unaryServidor(m *metricas) grpc.UnaryServerInterceptor {
+	aplicarDeadline := c.DeadlineMin > 0 || c.DeadlineMax > 0
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		id := idEntrante(ctx)
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+		inicio := time.Now()
+		if c.Metricas {
+			m.inicio(info.FullMethod)
+		}
+		defer func() { c.registrar(m, id, info.FullMethod, inicio, err) }()
+		if c.Recuperar {
+			defer func() {
+				if p := recover(); p != nil {
+					resp, err = nil, c.recuperado(id, info.FullMethod, p)
+				}
+			}()
+		}
+		if aplicarDeadline {
+			var cancel context.CancelFunc
+			ctx, cancel, err = c.deadline(ctx)
+			defer cancel()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamServidor reemplaza el contexto del stream por el que tiene el
+// request ID y el deadline ajustado.
+type streamServidor struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *streamServidor) Context() context.Context { return s.ctx }
+
+// OpcionesCliente devuelve las opciones de grpc.Dial con la cadena.
+// En los streams el log y las métricas se registran al abrir el stream: el
+// cliente no tiene un punto único donde termina.
+func (c Config) OpcionesCliente() []grpc.DialOption {
+	m := metricasDe(c.Lado)
+
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, metodo string, streamer grpc.Streamer, opts ...grpc.CallOption) (cs grpc.ClientStream, err error) {
+		ctx, id := idSaliente(ctx)
+		inicio := time.Now()
+		if c.Metricas {
+			m.inicio(metodo)
+		}
+		defer func() { c.registrar(m, id, metodo, inicio, err) }()
+		if c.Recuperar {
+			defer func() {
+				if p := recover(); p != nil {
+					cs, err = nil, c.recuperado(id, metodo, p)
+				}
+			}()
+		}
+		return streamer(ctx, desc, cc, metodo, opts...)
+	}
+
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(c.unaryCliente(m)),
+		grpc.WithChainStreamInterceptor(stream),
+	}
+}
+
+// unaryCliente es la cadena unary del cliente.
+func (c Config) unaryCliente(m *metricas) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, metodo string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		ctx, id := idSaliente(ctx)
+		inicio := time.Now()
+		if c.Metricas {
+			m.inicio(metodo)
+		}
+		defer func() { c.registrar(m, id, metodo, inicio, err) }()
+		if c.Recuperar {
+			defer func() {
+				if p := recover(); p != nil {
+					err = c.recuperado(id, metodo, p)
+				}
+			}()
+		}
+		return invoker(ctx, metodo, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,250 @@
+package interceptores
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var infoVenta = &grpc.UnaryServerInfo{FullMethod: "/blackfriday.ProductSaleService/ProcesarVenta"}
+
+func TestRecuperar(t *testing.T) {
+	cfg := Config{Lado: "prueba_recuperar", Recuperar: true}
+	unary := cfg.unaryServidor(metricasDe(cfg.Lado))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataRequestID, "rid-1"))
+
+	resp, err := unary(ctx, nil, infoVenta, func(ctx context.Context, req any) (any, error) {
+		panic("se rompió")
+	})
+	if resp != nil || status.Code(err) != codes.Internal {
+		t.Fatalf("panic: %v, %v; esperaba Internal", resp, err)
+	}
+	if !strings.Contains(status.Convert(err).Message(), "rid-1") {
+		t.Errorf("el error %q no lleva el request ID", err)
+	}
+
+	// sin recuperar el panic sigue de largo
+	defer func() {
+		if recover() == nil {
+			t.Error("sin Recuperar el panic no llegó al llamador")
+		}
+	}()
+	Config{Lado: cfg.Lado}.unaryServidor(metricasDe(cfg.Lado))(ctx, nil, infoVenta, func(ctx context.Context, req any) (any, error) {
+		panic("se rompió")
+	})
+}
+
+func TestRecuperarCliente(t *testing.T) {
+	cfg := Config{Lado: "prueba_recuperar_cliente", Recuperar: true}
+	err := cfg.unaryCliente(metricasDe(cfg.Lado))(context.Background(), "/m", nil, nil, nil,
+		func(ctx context.Context, metodo string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			panic("se rompió")
+		})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("panic en el cliente: %v, esperaba Internal", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		min, max time.Duration
+		entrante time.Duration // 0: sin deadline
+		codigo   codes.Code
+		quedan   time.Duration // deadline que ve el handler; 0: ninguno
+	}{
+		{"sin deadline toma el máximo", 5 * time.Millisecond, time.Second, 0, codes.OK, time.Second},
+		{"más largo que el máximo se recorta", 5 * time.Millisecond, time.Second, time.Minute, codes.OK, time.Second},
+		{"dentro del rango se respeta", 5 * time.Millisecond, time.Second, 500 * time.Millisecond, codes.OK, 500 * time.Millisecond},
+		{"menor al mínimo se rechaza", 50 * time.Millisecond, time.Second, 10 * time.Millisecond, codes.DeadlineExceeded, 0},
+		{"sin máximo no se agrega", 5 * time.Millisecond, 0, 0, codes.OK, 0},
+		{"sin mínimo se acepta cualquiera", 0, time.Second, time.Millisecond, codes.OK, time.Millisecond},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			cfg := Config{Lado: "prueba_deadline", DeadlineMin: c.min, DeadlineMax: c.max}
+			unary := cfg.unaryServidor(metricasDe(cfg.Lado))
+			ctx := context.Background()
+			if c.entrante > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.entrante)
+				defer cancel()
+			}
+
+			llamado := false
+			var quedan time.Duration
+			_, err := unary(ctx, nil, infoVenta, func(ctx context.Context, req any) (any, error) {
+				llamado = true
+				if d, ok := ctx.Deadline(); ok {
+					quedan = time.Until(d)
+				}
+				return "ok", nil
+			})
+			if status.Code(err) != c.codigo {
+				t.Fatalf("err %v, esperaba %v", err, c.codigo)
+			}
+			if llamado != (c.codigo == codes.OK) {
+				t.Fatalf("handler llamado = %v con %v", llamado, c.codigo)
+			}
+			// margen para lo que tarda el test entre armar el ctx y el handler
+			if quedan > c.quedan || (c.quedan > 0 && quedan < c.quedan-100*time.Millisecond) || (c.quedan == 0 && quedan != 0) {
+				t.Fatalf("el handler ve %v de deadline, esperaba %v", quedan, c.quedan)
+			}
+		})
+	}
+}
+
+func TestRequestIDServidor(t *testing.T) {
+	unary := Config{Lado: "prueba_rid"}.unaryServidor(metricasDe("prueba_rid"))
+	visto := func(ctx context.Context) string {
+		t.Helper()
+		var id string
+		if _, err := unary(ctx, nil, infoVenta, func(ctx context.Context, req any) (any, error) {
+			id = RequestID(ctx)
+			return nil, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	if id := visto(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataRequestID, "abc-123"))); id != "abc-123" {
+		t.Errorf("request ID entrante %q, esperaba abc-123", id)
+	}
+	for nombre, ctx := range map[string]context.Context{
+		"sin metadata": context.Background(),
+		"vacío":        metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataRequestID, "")),
+	} {
+		id := visto(ctx)
+		if _, err := hex.DecodeString(id); err != nil || len(id) != 16 {
+			t.Errorf("%s: request ID generado %q, esperaba 16 hex", nombre, id)
+		}
+	}
+	if visto(context.Background()) == visto(context.Background()) {
+		t.Error("dos llamadas sin request ID recibieron el mismo")
+	}
+}
+
+func TestRequestIDCliente(t *testing.T) {
+	unary := Config{Lado: "prueba_rid_cliente"}.unaryCliente(metricasDe("prueba_rid_cliente"))
+	saliente := func(ctx context.Context) []string {
+		t.Helper()
+		var ids []string
+		err := unary(ctx, "/m", nil, nil, nil, func(ctx context.Context, metodo string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			ids = md.Get(MetadataRequestID)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	// el gateway ya puso el suyo: viaja sin duplicarse
+	if ids := saliente(metadata.AppendToOutgoingContext(context.Background(), MetadataRequestID, "rid-gw")); len(ids) != 1 || ids[0] != "rid-gw" {
+		t.Errorf("request ID saliente %q, esperaba [rid-gw]", ids)
+	}
+	if ids := saliente(context.Background()); len(ids) != 1 || len(ids[0]) != 16 {
+		t.Errorf("request ID generado %q, esperaba uno de 16 hex", ids)
+	}
+}
+
+func TestMetricasPorMetodo(t *testing.T) {
+	cfg := Config{Lado: "prueba_metricas", Metricas: true, Recuperar: true}
+	m := metricasDe(cfg.Lado)
+	unary := cfg.unaryServidor(m)
+	llamar := func(metodo string, h grpc.UnaryHandler) {
+		unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: metodo}, h)
+	}
+	ok := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	llamar("/s/Venta", ok)
+	llamar("/s/Venta", ok)
+	llamar("/s/Venta", func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.FailedPrecondition, "agotado")
+	})
+	llamar("/s/Venta", func(ctx context.Context, req any) (any, error) { panic("x") })
+	llamar("/s/Venta", func(ctx context.Context, req any) (any, error) { return nil, errors.New("sin código") })
+	llamar("/s/Stock", ok)
+
+	casos := []struct {
+		metodo, clave string
+		quiero        int64
+	}{
+		{"/s/Venta", "llamadas", 5},
+		{"/s/Venta", "en_vuelo", 0},
+		{"/s/Venta", "OK", 2},
+		{"/s/Venta", "FailedPrecondition", 1},
+		{"/s/Venta", "Internal", 1},
+		{"/s/Venta", "Unknown", 1},
+		{"/s/Stock", "llamadas", 1},
+		{"/s/Stock", "OK", 1},
+		{"/s/Stock", "Internal", 0},
+	}
+	for _, c := range casos {
+		var got int64
+		if v, ok := m.metodo(c.metodo).Get(c.clave).(*expvar.Int); ok {
+			got = v.Value()
+		}
+		if got != c.quiero {
+			t.Errorf("%s %s = %d, quiero %d", c.metodo, c.clave, got, c.quiero)
+		}
+	}
+	if v, ok := m.metodo("/s/Venta").Get("latencia_ms_total").(*expvar.Float); !ok || v.Value() < 0 {
+		t.Error("falta latencia_ms_total")
+	}
+
+	// en vuelo mientras el handler corre
+	llamar("/s/Lenta", func(ctx context.Context, req any) (any, error) {
+		if n := m.metodo("/s/Lenta").Get("en_vuelo").(*expvar.Int).Value(); n != 1 {
+			t.Errorf("en_vuelo = %d durante la llamada, quiero 1", n)
+		}
+		return nil, nil
+	})
+}
+
+func TestDesdeEnv(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		entorno map[string]string
+		lado    string
+		quiero  string // Config.String(); vacío espera error
+	}{
+		{"por defecto", nil, "servidor", "request_id,log,metricas,recuperar,deadline[5ms,10s]"},
+		{"cliente sin deadline", nil, "cliente", "request_id,log,metricas,recuperar"},
+		{"ninguno", map[string]string{"GRPC_INTERCEPTORES": "ninguno"}, "servidor", "request_id,"},
+		{"lista", map[string]string{"GRPC_INTERCEPTORES": "recuperar, deadline", "GRPC_DEADLINE_MAX": "2s"}, "servidor", "request_id,recuperar,deadline[5ms,2s]"},
+		{"solo errores", map[string]string{"GRPC_INTERCEPTORES": "log", "GRPC_LOG": "errores"}, "servidor", "request_id,log(errores)"},
+		{"interceptor desconocido", map[string]string{"GRPC_INTERCEPTORES": "log,cache"}, "servidor", ""},
+		{"GRPC_LOG inválido", map[string]string{"GRPC_LOG": "nada"}, "servidor", ""},
+		{"deadline inválido", map[string]string{"GRPC_DEADLINE_MAX": "10"}, "servidor", ""},
+		{"mínimo mayor que máximo", map[string]string{"GRPC_DEADLINE_MIN": "2s", "GRPC_DEADLINE_MAX": "1s"}, "servidor", ""},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			for _, k := range []string{"GRPC_INTERCEPTORES", "GRPC_LOG", "GRPC_DEADLINE_MIN", "GRPC_DEADLINE_MAX"} {
+				t.Setenv(k, c.entorno[k])
+			}
+			cfg, err := DesdeEnv(c.lado)
+			if c.quiero == "" {
+				if err == nil {
+					t.Fatalf("config inválida aceptada: %s", cfg)
+				}
+				return
+			}
+			if err != nil || cfg.String() != c.quiero {
+				t.Fatalf("%q, %v; quiero %q", cfg.String(), err, c.quiero)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package interceptores
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// metricas publica en /debug/vars, por método:
+//
+//	"grpc_servidor": {"/blackfriday.ProductSaleService/ProcesarVenta":
+//	    {"llamadas": 10, "en_vuelo": 1, "OK": 9, "Unavailable": 1,
+//	     "latencia_ms_total": 123.4}}
+//
+// El promedio sale de latencia_ms_total / (llamadas - en_vuelo).
+type metricas struct {
+	raiz *expvar.Map
+}
+
+var (
+	muMetricas    sync.Mutex
+	metricasLados = map[string]*metricas{}
+)
+
+// metricasDe devuelve las métricas del lado, creándolas una sola vez
+// (expvar no admite publicar dos veces el mismo nombre).
+func metricasDe(lado string) *metricas {
+	muMetricas.Lock()
+	defer muMetricas.Unlock()
+	if m, ok := metricasLados[lado]; ok {
+		return m
+	}
+	m := &metricas{raiz: expvar.NewMap("grpc_" + lado)}
+	metricasLados[lado] = m
+	return m
+}
+
+func (m *metricas) metodo(nombre string) *expvar.Map {
+	if v, ok := m.raiz.Get(nombre).(*expvar.Map); ok {
+		return v
+	}
+	muMetricas.Lock()
+	defer muMetricas.Unlock()
+	if v, ok := m.raiz.Get(nombre).(*expvar.Map); ok {
+		return v
+	}
+	v := new(expvar.Map).Init()
+	m.raiz.Set(nombre, v)
+	return v
+}
+
+func (m *metricas) inicio(metodo string) {
+	mm := m.metodo(metodo)
+	mm.Add("llamadas", 1)
+	mm.Add("en_vuelo", 1)
+}
+
+func (m *metricas) fin(metodo string, code codes.Code, dur time.Duration) {
+	mm := m.metodo(metodo)
+	mm.Add("en_vuelo", -1)
+	mm.Add(code.String(), 1)
+	mm.AddFloat("latencia_ms_total", float64(dur)/float64(time.Millisecond))
+}
@@ -77,6 +77,56 @@ func (CategoriaProducto) EnumDescriptor() ([]byte, []int) {
 	return file_proto_blackfriday_proto_rawDescGZIP(), []int{0}
 }
 
+// Modo de carga del stock
+type ModoCargaStock int32
+
+const (
+	ModoCargaStock_REEMPLAZAR ModoCargaStock = 0 // fija el stock a la cantidad indicada
+	ModoCargaStock_SUMAR      ModoCargaStock = 1 // suma la cantidad al stock actual (reposición)
+	ModoCargaStock_SI_FALTA   ModoCargaStock = 2 // solo carga productos que todavía no tienen stock
+)
+
+// Enum value maps for ModoCargaStock.
+var (
+	ModoCargaStock_name = map[int32]string{
+		0: "REEMPLAZAR",
+		1: "SUMAR",
+		2: "SI_FALTA",
+	}
+	ModoCargaStock_value = map[string]int32{
+		"REEMPLAZAR": 0,
+		"SUMAR":      1,
+		"SI_FALTA":   2,
+	}
+)
+
+func (x ModoCargaStock) Enum() *ModoCargaStock {
+	p := new(ModoCargaStock)
+	*p = x
+	return p
+}
+
+func (x ModoCargaStock) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ModoCargaStock) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_blackfriday_proto_enumTypes[1].Descriptor()
+}
+
+func (ModoCargaStock) Type() protoreflect.EnumType {
+	return &file_proto_blackfriday_proto_enumTypes[1]
+}
+
+func (x ModoCargaStock) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ModoCargaStock.Descriptor instead.
+func (ModoCargaStock) EnumDescriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{1}
+}
+
 // Mensaje con información de venta de producto durante Black Friday
 type ProductSaleRequest struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
@@ -191,6 +241,488 @@ func (x *ProductSaleResponse) GetEstado() string {
 	return ""
 }
 
+// Stock de un producto
+type StockProducto struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductoId    string                 `protobuf:"bytes,1,opt,name=producto_id,json=productoId,proto3" json:"producto_id,omitempty"`
+	Cantidad      int64                  `protobuf:"varint,2,opt,name=cantidad,proto3" json:"cantidad,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StockProducto) Reset() {
+	*x = StockProducto{}
+	mi := &file_proto_blackfriday_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StockProducto) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StockProducto) ProtoMessage() {}
+
+func (x *StockProducto) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StockProducto.ProtoReflect.Descriptor instead.
+func (*StockProducto) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StockProducto) GetProductoId() string {
+	if x != nil {
+		return x.ProductoId
+	}
+	return ""
+}
+
+func (x *StockProducto) GetCantidad() int64 {
+	if x != nil {
+		return x.Cantidad
+	}
+	return 0
+}
+
+type CargarStockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Productos     []*StockProducto       `protobuf:"bytes,1,rep,name=productos,proto3" json:"productos,omitempty"`
+	Modo          ModoCargaStock         `protobuf:"varint,2,opt,name=modo,proto3,enum=blackfriday.ModoCargaStock" json:"modo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CargarStockRequest) Reset() {
+	*x = CargarStockRequest{}
+	mi := &file_proto_blackfriday_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CargarStockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CargarStockRequest) ProtoMessage() {}
+
+func (x *CargarStockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CargarStockRequest.ProtoReflect.Descriptor instead.
+func (*CargarStockRequest) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CargarStockRequest) GetProductos() []*StockProducto {
+	if x != nil {
+		return x.Productos
+	}
+	return nil
+}
+
+func (x *CargarStockRequest) GetModo() ModoCargaStock {
+	if x != nil {
+		return x.Modo
+	}
+	return ModoCargaStock_REEMPLAZAR
+}
+
+type CargarStockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cargados      int32                  `protobuf:"varint,1,opt,name=cargados,proto3" json:"cargados,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CargarStockResponse) Reset() {
+	*x = CargarStockResponse{}
+	mi := &file_proto_blackfriday_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CargarStockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CargarStockResponse) ProtoMessage() {}
+
+func (x *CargarStockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CargarStockResponse.ProtoReflect.Descriptor instead.
+func (*CargarStockResponse) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CargarStockResponse) GetCargados() int32 {
+	if x != nil {
+		return x.Cargados
+	}
+	return 0
+}
+
+type ConsultarStockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductoIds   []string               `protobuf:"bytes,1,rep,name=producto_ids,json=productoIds,proto3" json:"producto_ids,omitempty"` // vacío: todos los productos
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConsultarStockRequest) Reset() {
+	*x = ConsultarStockRequest{}
+	mi := &file_proto_blackfriday_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConsultarStockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsultarStockRequest) ProtoMessage() {}
+
+func (x *ConsultarStockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsultarStockRequest.ProtoReflect.Descriptor instead.
+func (*ConsultarStockRequest) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConsultarStockRequest) GetProductoIds() []string {
+	if x != nil {
+		return x.ProductoIds
+	}
+	return nil
+}
+
+type ConsultarStockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Productos     []*StockProducto       `protobuf:"bytes,1,rep,name=productos,proto3" json:"productos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConsultarStockResponse) Reset() {
+	*x = ConsultarStockResponse{}
+	mi := &file_proto_blackfriday_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConsultarStockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConsultarStockResponse) ProtoMessage() {}
+
+func (x *ConsultarStockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConsultarStockResponse.ProtoReflect.Descriptor instead.
+func (*ConsultarStockResponse) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ConsultarStockResponse) GetProductos() []*StockProducto {
+	if x != nil {
+		return x.Productos
+	}
+	return nil
+}
+
+// Producto del catálogo
+type Producto struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductoId    string                 `protobuf:"bytes,1,opt,name=producto_id,json=productoId,proto3" json:"producto_id,omitempty"`
+	Nombre        string                 `protobuf:"bytes,2,opt,name=nombre,proto3" json:"nombre,omitempty"`
+	Categoria     CategoriaProducto      `protobuf:"varint,3,opt,name=categoria,proto3,enum=blackfriday.CategoriaProducto" json:"categoria,omitempty"`
+	PrecioLista   float64                `protobuf:"fixed64,4,opt,name=precio_lista,json=precioLista,proto3" json:"precio_lista,omitempty"`
+	DescuentoPct  float64                `protobuf:"fixed64,5,opt,name=descuento_pct,json=descuentoPct,proto3" json:"descuento_pct,omitempty"` // descuento anunciado, 0 a 100
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Producto) Reset() {
+	*x = Producto{}
+	mi := &file_proto_blackfriday_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Producto) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Producto) ProtoMessage() {}
+
+func (x *Producto) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Producto.ProtoReflect.Descriptor instead.
+func (*Producto) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Producto) GetProductoId() string {
+	if x != nil {
+		return x.ProductoId
+	}
+	return ""
+}
+
+func (x *Producto) GetNombre() string {
+	if x != nil {
+		return x.Nombre
+	}
+	return ""
+}
+
+func (x *Producto) GetCategoria() CategoriaProducto {
+	if x != nil {
+		return x.Categoria
+	}
+	return CategoriaProducto_CATEGORIA_PRODUCTO_UNSPECIFIED
+}
+
+func (x *Producto) GetPrecioLista() float64 {
+	if x != nil {
+		return x.PrecioLista
+	}
+	return 0
+}
+
+func (x *Producto) GetDescuentoPct() float64 {
+	if x != nil {
+		return x.DescuentoPct
+	}
+	return 0
+}
+
+type ProductoIdRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ProductoId    string                 `protobuf:"bytes,1,opt,name=producto_id,json=productoId,proto3" json:"producto_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductoIdRequest) Reset() {
+	*x = ProductoIdRequest{}
+	mi := &file_proto_blackfriday_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductoIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductoIdRequest) ProtoMessage() {}
+
+func (x *ProductoIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductoIdRequest.ProtoReflect.Descriptor instead.
+func (*ProductoIdRequest) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ProductoIdRequest) GetProductoId() string {
+	if x != nil {
+		return x.ProductoId
+	}
+	return ""
+}
+
+type ListarProductosRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Categoria     CategoriaProducto      `protobuf:"varint,1,opt,name=categoria,proto3,enum=blackfriday.CategoriaProducto" json:"categoria,omitempty"` // sin especificar: todas
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListarProductosRequest) Reset() {
+	*x = ListarProductosRequest{}
+	mi := &file_proto_blackfriday_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListarProductosRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListarProductosRequest) ProtoMessage() {}
+
+func (x *ListarProductosRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListarProductosRequest.ProtoReflect.Descriptor instead.
+func (*ListarProductosRequest) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListarProductosRequest) GetCategoria() CategoriaProducto {
+	if x != nil {
+		return x.Categoria
+	}
+	return CategoriaProducto_CATEGORIA_PRODUCTO_UNSPECIFIED
+}
+
+type ListarProductosResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Productos     []*Producto            `protobuf:"bytes,1,rep,name=productos,proto3" json:"productos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListarProductosResponse) Reset() {
+	*x = ListarProductosResponse{}
+	mi := &file_proto_blackfriday_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListarProductosResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListarProductosResponse) ProtoMessage() {}
+
+func (x *ListarProductosResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListarProductosResponse.ProtoReflect.Descriptor instead.
+func (*ListarProductosResponse) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListarProductosResponse) GetProductos() []*Producto {
+	if x != nil {
+		return x.Productos
+	}
+	return nil
+}
+
+type EliminarProductoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EliminarProductoResponse) Reset() {
+	*x = EliminarProductoResponse{}
+	mi := &file_proto_blackfriday_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EliminarProductoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EliminarProductoResponse) ProtoMessage() {}
+
+func (x *EliminarProductoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_blackfriday_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EliminarProductoResponse.ProtoReflect.Descriptor instead.
+func (*EliminarProductoResponse) Descriptor() ([]byte, []int) {
+	return file_proto_blackfriday_proto_rawDescGZIP(), []int{11}
+}
+
 var File_proto_blackfriday_proto protoreflect.FileDescriptor
 
 const file_proto_blackfriday_proto_rawDesc = "" +
@@ -203,15 +735,57 @@ const file_proto_blackfriday_proto_rawDesc = "" +
 	"\x06precio\x18\x03 \x01(\x01R\x06precio\x12)\n" +
 	"\x10cantidad_vendida\x18\x04 \x01(\x05R\x0fcantidadVendida\"-\n" +
 	"\x13ProductSaleResponse\x12\x16\n" +
-	"\x06estado\x18\x01 \x01(\tR\x06estado*j\n" +
+	"\x06estado\x18\x01 \x01(\tR\x06estado\"L\n" +
+	"\rStockProducto\x12\x1f\n" +
+	"\vproducto_id\x18\x01 \x01(\tR\n" +
+	"productoId\x12\x1a\n" +
+	"\bcantidad\x18\x02 \x01(\x03R\bcantidad\"\x7f\n" +
+	"\x12CargarStockRequest\x128\n" +
+	"\tproductos\x18\x01 \x03(\v2\x1a.blackfriday.StockProductoR\tproductos\x12/\n" +
+	"\x04modo\x18\x02 \x01(\x0e2\x1b.blackfriday.ModoCargaStockR\x04modo\"1\n" +
+	"\x13CargarStockResponse\x12\x1a\n" +
+	"\bcargados\x18\x01 \x01(\x05R\bcargados\":\n" +
+	"\x15ConsultarStockRequest\x12!\n" +
+	"\fproducto_ids\x18\x01 \x03(\tR\vproductoIds\"R\n" +
+	"\x16ConsultarStockResponse\x128\n" +
+	"\tproductos\x18\x01 \x03(\v2\x1a.blackfriday.StockProductoR\tproductos\"\xc9\x01\n" +
+	"\bProducto\x12\x1f\n" +
+	"\vproducto_id\x18\x01 \x01(\tR\n" +
+	"productoId\x12\x16\n" +
+	"\x06nombre\x18\x02 \x01(\tR\x06nombre\x12<\n" +
+	"\tcategoria\x18\x03 \x01(\x0e2\x1e.blackfriday.CategoriaProductoR\tcategoria\x12!\n" +
+	"\fprecio_lista\x18\x04 \x01(\x01R\vprecioLista\x12#\n" +
+	"\rdescuento_pct\x18\x05 \x01(\x01R\fdescuentoPct\"4\n" +
+	"\x11ProductoIdRequest\x12\x1f\n" +
+	"\vproducto_id\x18\x01 \x01(\tR\n" +
+	"productoId\"V\n" +
+	"\x16ListarProductosRequest\x12<\n" +
+	"\tcategoria\x18\x01 \x01(\x0e2\x1e.blackfriday.CategoriaProductoR\tcategoria\"N\n" +
+	"\x17ListarProductosResponse\x123\n" +
+	"\tproductos\x18\x01 \x03(\v2\x15.blackfriday.ProductoR\tproductos\"\x1a\n" +
+	"\x18EliminarProductoResponse*j\n" +
 	"\x11CategoriaProducto\x12\"\n" +
 	"\x1eCATEGORIA_PRODUCTO_UNSPECIFIED\x10\x00\x12\x0f\n" +
 	"\vElectronica\x10\x01\x12\b\n" +
 	"\x04Ropa\x10\x02\x12\t\n" +
 	"\x05Hogar\x10\x03\x12\v\n" +
-	"\aBelleza\x10\x042h\n" +
+	"\aBelleza\x10\x04*9\n" +
+	"\x0eModoCargaStock\x12\x0e\n" +
+	"\n" +
+	"REEMPLAZAR\x10\x00\x12\t\n" +
+	"\x05SUMAR\x10\x01\x12\f\n" +
+	"\bSI_FALTA\x10\x022h\n" +
 	"\x12ProductSaleService\x12R\n" +
-	"\rProcesarVenta\x12\x1f.blackfriday.ProductSaleRequest\x1a .blackfriday.ProductSaleResponseB\x19Z\x17blackfriday/proto;protob\x06proto3"
+	"\rProcesarVenta\x12\x1f.blackfriday.ProductSaleRequest\x1a .blackfriday.ProductSaleResponse2\xc0\x01\n" +
+	"\x11InventarioService\x12P\n" +
+	"\vCargarStock\x12\x1f.blackfriday.CargarStockRequest\x1a .blackfriday.CargarStockResponse\x12Y\n" +
+	"\x0eConsultarStock\x12\".blackfriday.ConsultarStockRequest\x1a#.blackfriday.ConsultarStockResponse2\x97\x03\n" +
+	"\x0fCatalogoService\x12=\n" +
+	"\rCrearProducto\x12\x15.blackfriday.Producto\x1a\x15.blackfriday.Producto\x12H\n" +
+	"\x0fObtenerProducto\x12\x1e.blackfriday.ProductoIdRequest\x1a\x15.blackfriday.Producto\x12B\n" +
+	"\x12ActualizarProducto\x12\x15.blackfriday.Producto\x1a\x15.blackfriday.Producto\x12Y\n" +
+	"\x10EliminarProducto\x12\x1e.blackfriday.ProductoIdRequest\x1a%.blackfriday.EliminarProductoResponse\x12\\\n" +
+	"\x0fListarProductos\x12#.blackfriday.ListarProductosRequest\x1a$.blackfriday.ListarProductosResponseB\x19Z\x17blackfriday/proto;protob\x06proto3"
 
 var (
 	file_proto_blackfriday_proto_rawDescOnce sync.Once
@@ -225,22 +799,53 @@ func file_proto_blackfriday_proto_rawDescGZIP() []byte {
 	return file_proto_blackfriday_proto_rawDescData
 }
 
-var file_proto_blackfriday_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_blackfriday_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_blackfriday_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_blackfriday_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_proto_blackfriday_proto_goTypes = []any{
-	(CategoriaProducto)(0),      // 0: blackfriday.CategoriaProducto
-	(*ProductSaleRequest)(nil),  // 1: blackfriday.ProductSaleRequest
-	(*ProductSaleResponse)(nil), // 2: blackfriday.ProductSaleResponse
+	(CategoriaProducto)(0),           // 0: blackfriday.CategoriaProducto
+	(ModoCargaStock)(0),              // 1: blackfriday.ModoCargaStock
+	(*ProductSaleRequest)(nil),       // 2: blackfriday.ProductSaleRequest
+	(*ProductSaleResponse)(nil),      // 3: blackfriday.ProductSaleResponse
+	(*StockProducto)(nil),            // 4: blackfriday.StockProducto
+	(*CargarStockRequest)(nil),       // 5: blackfriday.CargarStockRequest
+	(*CargarStockResponse)(nil),      // 6: blackfriday.CargarStockResponse
+	(*ConsultarStockRequest)(nil),    // 7: blackfriday.ConsultarStockRequest
+	(*ConsultarStockResponse)(nil),   // 8: blackfriday.ConsultarStockResponse
+	(*Producto)(nil),                 // 9: blackfriday.Producto
+	(*ProductoIdRequest)(nil),        // 10: blackfriday.ProductoIdRequest
+	(*ListarProductosRequest)(nil),   // 11: blackfriday.ListarProductosRequest
+	(*ListarProductosResponse)(nil),  // 12: blackfriday.ListarProductosResponse
+	(*EliminarProductoResponse)(nil), // 13: blackfriday.EliminarProductoResponse
 }
 var file_proto_blackfriday_proto_depIdxs = []int32{
-	0, // 0: blackfriday.ProductSaleRequest.categoria:type_name -> blackfriday.CategoriaProducto
-	1, // 1: blackfriday.ProductSaleService.ProcesarVenta:input_type -> blackfriday.ProductSaleRequest
-	2, // 2: blackfriday.ProductSaleService.ProcesarVenta:output_type -> blackfriday.ProductSaleResponse
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: blackfriday.ProductSaleRequest.categoria:type_name -> blackfriday.CategoriaProducto
+	4,  // 1: blackfriday.CargarStockRequest.productos:type_name -> blackfriday.StockProducto
+	1,  // 2: blackfriday.CargarStockRequest.modo:type_name -> blackfriday.ModoCargaStock
+	4,  // 3: blackfriday.ConsultarStockResponse.productos:type_name -> blackfriday.StockProducto
+	0,  // 4: blackfriday.Producto.categoria:type_name -> blackfriday.CategoriaProducto
+	0,  // 5: blackfriday.ListarProductosRequest.categoria:type_name -> blackfriday.CategoriaProducto
+	9,  // 6: blackfriday.ListarProductosResponse.productos:type_name -> blackfriday.Producto
+	2,  // 7: blackfriday.ProductSaleService.ProcesarVenta:input_type -> blackfriday.ProductSaleRequest
+	5,  // 8: blackfriday.InventarioService.CargarStock:input_type -> blackfriday.CargarStockRequest
+	7,  // 9: blackfriday.InventarioService.ConsultarStock:input_type -> blackfriday.ConsultarStockRequest
+	9,  // 10: blackfriday.CatalogoService.CrearProducto:input_type -> blackfriday.Producto
+	10, // 11: blackfriday.CatalogoService.ObtenerProducto:input_type -> blackfriday.ProductoIdRequest
+	9,  // 12: blackfriday.CatalogoService.ActualizarProducto:input_type -> blackfriday.Producto
+	10, // 13: blackfriday.CatalogoService.EliminarProducto:input_type -> blackfriday.ProductoIdRequest
+	11, // 14: blackfriday.CatalogoService.ListarProductos:input_type -> blackfriday.ListarProductosRequest
+	3,  // 15: blackfriday.ProductSaleService.ProcesarVenta:output_type -> blackfriday.ProductSaleResponse
+	6,  // 16: blackfriday.InventarioService.CargarStock:output_type -> blackfriday.CargarStockResponse
+	8,  // 17: blackfriday.InventarioService.ConsultarStock:output_type -> blackfriday.ConsultarStockResponse
+	9,  // 18: blackfriday.CatalogoService.CrearProducto:output_type -> blackfriday.Producto
+	9,  // 19: blackfriday.CatalogoService.ObtenerProducto:output_type -> blackfriday.Producto
+	9,  // 20: blackfriday.CatalogoService.ActualizarProducto:output_type -> blackfriday.Producto
+	13, // 21: blackfriday.CatalogoService.EliminarProducto:output_type -> blackfriday.EliminarProductoResponse
+	12, // 22: blackfriday.CatalogoService.ListarProductos:output_type -> blackfriday.ListarProductosResponse
+	15, // [15:23] is the sub-list for method output_type
+	7,  // [7:15] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_proto_blackfriday_proto_init() }
@@ -253,10 +858,10 @@ func file_proto_blackfriday_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_blackfriday_proto_rawDesc), len(file_proto_blackfriday_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      2,
+			NumMessages:   12,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   3,
 		},
 		GoTypes:           file_proto_blackfriday_proto_goTypes,
 		DependencyIndexes: file_proto_blackfriday_proto_depIdxs,
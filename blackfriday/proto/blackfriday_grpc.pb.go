@@ -123,3 +123,405 @@ var ProductSaleService_ServiceDesc = grpc.ServiceDesc{
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/blackfriday.proto",
 }
+
+const (
+	InventarioService_CargarStock_FullMethodName    = "/blackfriday.InventarioService/CargarStock"
+	InventarioService_ConsultarStock_FullMethodName = "/blackfriday.InventarioService/ConsultarStock"
+)
+
+// InventarioServiceClient is the client API for InventarioService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Servicio gRPC de administración del inventario
+type InventarioServiceClient interface {
+	CargarStock(ctx context.Context, in *CargarStockRequest, opts ...grpc.CallOption) (*CargarStockResponse, error)
+	ConsultarStock(ctx context.Context, in *ConsultarStockRequest, opts ...grpc.CallOption) (*ConsultarStockResponse, error)
+}
+
+type inventarioServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInventarioServiceClient(cc grpc.ClientConnInterface) InventarioServiceClient {
+	return &inventarioServiceClient{cc}
+}
+
+func (c *inventarioServiceClient) CargarStock(ctx context.Context, in *CargarStockRequest, opts ...grpc.CallOption) (*CargarStockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CargarStockResponse)
+	err := c.cc.Invoke(ctx, InventarioService_CargarStock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inventarioServiceClient) ConsultarStock(ctx context.Context, in *ConsultarStockRequest, opts ...grpc.CallOption) (*ConsultarStockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConsultarStockResponse)
+	err := c.cc.Invoke(ctx, InventarioService_ConsultarStock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InventarioServiceServer is the server API for InventarioService service.
+// All implementations must embed UnimplementedInventarioServiceServer
+// for forward compatibility.
+//
+// Servicio gRPC de administración del inventario
+type InventarioServiceServer interface {
+	CargarStock(context.Context, *CargarStockRequest) (*CargarStockResponse, error)
+	ConsultarStock(context.Context, *ConsultarStockRequest) (*ConsultarStockResponse, error)
+	mustEmbedUnimplementedInventarioServiceServer()
+}
+
+// UnimplementedInventarioServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInventarioServiceServer struct{}
+
+func (UnimplementedInventarioServiceServer) CargarStock(context.Context, *CargarStockRequest) (*CargarStockResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CargarStock not implemented")
+}
+func (UnimplementedInventarioServiceServer) ConsultarStock(context.Context, *ConsultarStockRequest) (*ConsultarStockResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ConsultarStock not implemented")
+}
+func (UnimplementedInventarioServiceServer) mustEmbedUnimplementedInventarioServiceServer() {}
+func (UnimplementedInventarioServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeInventarioServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InventarioServiceServer will
+// result in compilation errors.
+type UnsafeInventarioServiceServer interface {
+	mustEmbedUnimplementedInventarioServiceServer()
+}
+
+func RegisterInventarioServiceServer(s grpc.ServiceRegistrar, srv InventarioServiceServer) {
+	// If the following call panics, it indicates UnimplementedInventarioServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&InventarioService_ServiceDesc, srv)
+}
+
+func _InventarioService_CargarStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CargarStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventarioServiceServer).CargarStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InventarioService_CargarStock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventarioServiceServer).CargarStock(ctx, req.(*CargarStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InventarioService_ConsultarStock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConsultarStockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InventarioServiceServer).ConsultarStock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InventarioService_ConsultarStock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InventarioServiceServer).ConsultarStock(ctx, req.(*ConsultarStockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// InventarioService_ServiceDesc is the grpc.ServiceDesc for InventarioService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var InventarioService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "blackfriday.InventarioService",
+	HandlerType: (*InventarioServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CargarStock",
+			Handler:    _InventarioService_CargarStock_Handler,
+		},
+		{
+			MethodName: "ConsultarStock",
+			Handler:    _InventarioService_ConsultarStock_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/blackfriday.proto",
+}
+
+const (
+	CatalogoService_CrearProducto_FullMethodName      = "/blackfriday.CatalogoService/CrearProducto"
+	CatalogoService_ObtenerProducto_FullMethodName    = "/blackfriday.CatalogoService/ObtenerProducto"
+	CatalogoService_ActualizarProducto_FullMethodName = "/blackfriday.CatalogoService/ActualizarProducto"
+	CatalogoService_EliminarProducto_FullMethodName   = "/blackfriday.CatalogoService/EliminarProducto"
+	CatalogoService_ListarProductos_FullMethodName    = "/blackfriday.CatalogoService/ListarProductos"
+)
+
+// CatalogoServiceClient is the client API for CatalogoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Servicio gRPC de administración del catálogo de productos
+type CatalogoServiceClient interface {
+	CrearProducto(ctx context.Context, in *Producto, opts ...grpc.CallOption) (*Producto, error)
+	ObtenerProducto(ctx context.Context, in *ProductoIdRequest, opts ...grpc.CallOption) (*Producto, error)
+	ActualizarProducto(ctx context.Context, in *Producto, opts ...grpc.CallOption) (*Producto, error)
+	EliminarProducto(ctx context.Context, in *ProductoIdRequest, opts ...grpc.CallOption) (*EliminarProductoResponse, error)
+	ListarProductos(ctx context.Context, in *ListarProductosRequest, opts ...grpc.CallOption) (*ListarProductosResponse, error)
+}
+
+type catalogoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCatalogoServiceClient(cc grpc.ClientConnInterface) CatalogoServiceClient {
+	return &catalogoServiceClient{cc}
+}
+
+func (c *catalogoServiceClient) CrearProducto(ctx context.Context, in *Producto, opts ...grpc.CallOption) (*Producto, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Producto)
+	err := c.cc.Invoke(ctx, CatalogoService_CrearProducto_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogoServiceClient) ObtenerProducto(ctx context.Context, in *ProductoIdRequest, opts ...grpc.CallOption) (*Producto, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Producto)
+	err := c.cc.Invoke(ctx, CatalogoService_ObtenerProducto_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogoServiceClient) ActualizarProducto(ctx context.Context, in *Producto, opts ...grpc.CallOption) (*Producto, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Producto)
+	err := c.cc.Invoke(ctx, CatalogoService_ActualizarProducto_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogoServiceClient) EliminarProducto(ctx context.Context, in *ProductoIdRequest, opts ...grpc.CallOption) (*EliminarProductoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EliminarProductoResponse)
+	err := c.cc.Invoke(ctx, CatalogoService_EliminarProducto_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *catalogoServiceClient) ListarProductos(ctx context.Context, in *ListarProductosRequest, opts ...grpc.CallOption) (*ListarProductosResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListarProductosResponse)
+	err := c.cc.Invoke(ctx, CatalogoService_ListarProductos_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CatalogoServiceServer is the server API for CatalogoService service.
+// All implementations must embed UnimplementedCatalogoServiceServer
+// for forward compatibility.
+//
+// Servicio gRPC de administración del catálogo de productos
+type CatalogoServiceServer interface {
+	CrearProducto(context.Context, *Producto) (*Producto, error)
+	ObtenerProducto(context.Context, *ProductoIdRequest) (*Producto, error)
+	ActualizarProducto(context.Context, *Producto) (*Producto, error)
+	EliminarProducto(context.Context, *ProductoIdRequest) (*EliminarProductoResponse, error)
+	ListarProductos(context.Context, *ListarProductosRequest) (*ListarProductosResponse, error)
+	mustEmbedUnimplementedCatalogoServiceServer()
+}
+
+// UnimplementedCatalogoServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCatalogoServiceServer struct{}
+
+func (UnimplementedCatalogoServiceServer) CrearProducto(context.Context, *Producto) (*Producto, error) {
+	return nil, status.Error(codes.Unimplemented, "method CrearProducto not implemented")
+}
+func (UnimplementedCatalogoServiceServer) ObtenerProducto(context.Context, *ProductoIdRequest) (*Producto, error) {
+	return nil, status.Error(codes.Unimplemented, "method ObtenerProducto not implemented")
+}
+func (UnimplementedCatalogoServiceServer) ActualizarProducto(context.Context, *Producto) (*Producto, error) {
+	return nil, status.Error(codes.Unimplemented, "method ActualizarProducto not implemented")
+}
+func (UnimplementedCatalogoServiceServer) EliminarProducto(context.Context, *ProductoIdRequest) (*EliminarProductoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method EliminarProducto not implemented")
+}
+func (UnimplementedCatalogoServiceServer) ListarProductos(context.Context, *ListarProductosRequest) (*ListarProductosResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListarProductos not implemented")
+}
+func (UnimplementedCatalogoServiceServer) mustEmbedUnimplementedCatalogoServiceServer() {}
+func (UnimplementedCatalogoServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeCatalogoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CatalogoServiceServer will
+// result in compilation errors.
+type UnsafeCatalogoServiceServer interface {
+	mustEmbedUnimplementedCatalogoServiceServer()
+}
+
+func RegisterCatalogoServiceServer(s grpc.ServiceRegistrar, srv CatalogoServiceServer) {
+	// If the following call panics, it indicates UnimplementedCatalogoServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CatalogoService_ServiceDesc, srv)
+}
+
+func _CatalogoService_CrearProducto_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Producto)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogoServiceServer).CrearProducto(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogoService_CrearProducto_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogoServiceServer).CrearProducto(ctx, req.(*Producto))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogoService_ObtenerProducto_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProductoIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogoServiceServer).ObtenerProducto(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogoService_ObtenerProducto_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogoServiceServer).ObtenerProducto(ctx, req.(*ProductoIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogoService_ActualizarProducto_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Producto)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogoServiceServer).ActualizarProducto(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogoService_ActualizarProducto_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogoServiceServer).ActualizarProducto(ctx, req.(*Producto))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogoService_EliminarProducto_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProductoIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogoServiceServer).EliminarProducto(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogoService_EliminarProducto_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogoServiceServer).EliminarProducto(ctx, req.(*ProductoIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CatalogoService_ListarProductos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListarProductosRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CatalogoServiceServer).ListarProductos(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CatalogoService_ListarProductos_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CatalogoServiceServer).ListarProductos(ctx, req.(*ListarProductosRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CatalogoService_ServiceDesc is the grpc.ServiceDesc for CatalogoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CatalogoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "blackfriday.CatalogoService",
+	HandlerType: (*CatalogoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CrearProducto",
+			Handler:    _CatalogoService_CrearProducto_Handler,
+		},
+		{
+			MethodName: "ObtenerProducto",
+			Handler:    _CatalogoService_ObtenerProducto_Handler,
+		},
+		{
+			MethodName: "ActualizarProducto",
+			Handler:    _CatalogoService_ActualizarProducto_Handler,
+		},
+		{
+			MethodName: "EliminarProducto",
+			Handler:    _CatalogoService_EliminarProducto_Handler,
+		},
+		{
+			MethodName: "ListarProductos",
+			Handler:    _CatalogoService_ListarProductos_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/blackfriday.proto",
+}
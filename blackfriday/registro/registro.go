@@ -0,0 +1,70 @@
+// Package registro configura log/slog para el gateway y el gRPC server:
+// una línea JSON por evento con nivel, servicio y atributos, lista para el
+// agregador de logs.
+//
+//	LOG_LEVEL        debug, info (por defecto), warn o error
+//	LOG_FORMAT       json (por defecto) o texto
+//	LOG_MUESTREO_OK  en el camino caliente se loguea 1 de cada N éxitos (por defecto 100; 1 todos)
+//
+// Las líneas que todavía usan el paquete log salen por el mismo handler con
+// nivel info.
+package registro
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// HeaderRequestID es el header HTTP con el que llega (o se devuelve) el
+// request ID; en gRPC y Kafka viaja como x-request-id.
+const HeaderRequestID = "X-Request-ID"
+
+// Configurar instala el logger por defecto con el atributo servicio.
+func Configurar(servicio string) error {
+	var nivel slog.Level
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := nivel.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("LOG_LEVEL: %q, se espera debug, info, warn o error", v)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: nivel}
+
+	var h slog.Handler
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "", "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	case "texto", "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT: %q, se espera json o texto", os.Getenv("LOG_FORMAT"))
+	}
+	slog.SetDefault(slog.New(h).With("servicio", servicio))
+
+	if v := os.Getenv("LOG_MUESTREO_OK"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("LOG_MUESTREO_OK: %q, se espera un entero >= 1", v)
+		}
+		muestreoOK.Store(uint64(n))
+	}
+	return nil
+}
+
+var muestreoOK atomic.Uint64
+
+func init() { muestreoOK.Store(100) }
+
+// Muestreo deja pasar una de cada N llamadas a Toca.
+type Muestreo struct {
+	n atomic.Uint64
+}
+
+// Toca devuelve true para la primera llamada y después una de cada
+// LOG_MUESTREO_OK.
+func (m *Muestreo) Toca() bool {
+	return (m.n.Add(1)-1)%muestreoOK.Load() == 0
+}
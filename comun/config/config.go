@@ -0,0 +1,383 @@
+// Package config carga la configuración de un binario en capas, de menor a
+// mayor prioridad:
+//
+//	valores por defecto < archivo (--config o CONFIG_FILE) < variables de entorno < flags
+//
+// Cada opción se declara una sola vez (Opcion) con su variable de entorno,
+// tipo, valor por defecto y descripción. El archivo puede ser YAML (.yaml,
+// .yml) o TOML (.toml) y usa las mismas claves, planas o anidadas por
+// prefijo; estas dos formas son equivalentes:
+//
+//	GRPC_PORT: 50051        grpc:
+//	                          port: 50051
+//
+// Los flags son la clave en minúsculas con guiones: --grpc-port 50051.
+// --print-config muestra la configuración efectiva y de dónde salió cada
+// valor.
+//
+// Todo se valida al arrancar (tipo, rango, valores permitidos, claves
+// desconocidas en el archivo) y los errores se informan juntos. Los valores
+// efectivos quedan en el entorno del proceso, así que el resto del código
+// sigue leyendo os.Getenv como antes.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Tipo define cómo se valida (y normaliza) el valor de una opción.
+type Tipo int
+
+const (
+	Texto     Tipo = iota
+	Entero         // entero >= 0
+	Decimal        // número >= 0
+	Bool           // se normaliza a "true" / "false"
+	Duracion       // time.ParseDuration, >= 0
+	Puerto         // 1-65535
+	Direccion      // host:puerto o :puerto
+	Lista          // valores separados por coma; en el archivo también como lista
+)
+
+// Opcion es una opción de configuración.
+type Opcion struct {
+	Clave   string // variable de entorno, ej. GRPC_PORT
+	Tipo    Tipo
+	Defecto string
+	Desc    string
+	Valores []string // valores permitidos; vacío acepta cualquiera
+	Secreto bool     // no se muestra en --print-config
+
+	// Positivo exige > 0 en Entero, Decimal o Duracion (intervalos de
+	// tickers, tamaños de lote, fracciones de error).
+	Positivo bool
+	// Menor es una cota superior excluida para Entero o Decimal, ej. "1"
+	// para una fracción.
+	Menor string
+}
+
+// Origen de un valor efectivo.
+const (
+	origenDefecto = "defecto"
+	origenEntorno = "entorno"
+	origenFlag    = "flag"
+)
+
+// Config es la configuración efectiva ya validada.
+type Config struct {
+	Nombre   string
+	Imprimir bool // se pidió --print-config
+
+	opciones []Opcion
+	valores  map[string]string
+	origen   map[string]string
+}
+
+// Cargar lee archivo, entorno y flags de args (sin el nombre del programa)
+// y devuelve los argumentos que siguen a los flags (subcomandos). Con -h
+// devuelve flag.ErrHelp después de mostrar la ayuda.
+func Cargar(nombre string, opciones []Opcion, args []string) (*Config, []string, error) {
+	c := &Config{
+		Nombre:   nombre,
+		opciones: opciones,
+		valores:  make(map[string]string, len(opciones)),
+		origen:   make(map[string]string, len(opciones)),
+	}
+	porClave := make(map[string]Opcion, len(opciones))
+	for _, o := range opciones {
+		porClave[o.Clave] = o
+		if o.Defecto != "" {
+			c.valores[o.Clave], c.origen[o.Clave] = o.Defecto, origenDefecto
+		}
+	}
+
+	// Flags: se guardan y se aplican al final, después del archivo y el entorno
+	fs := flag.NewFlagSet(nombre, flag.ContinueOnError)
+	archivo := fs.String("config", os.Getenv("CONFIG_FILE"), "archivo de configuración YAML o TOML (CONFIG_FILE)")
+	fs.BoolVar(&c.Imprimir, "print-config", false, "mostrar la configuración efectiva y salir")
+	type valorFlag struct{ clave, valor string }
+	var desdeFlags []valorFlag
+	for _, o := range opciones {
+		uso := fmt.Sprintf("%s (%s)", o.Desc, o.Clave)
+		if o.Defecto != "" {
+			uso += fmt.Sprintf(" [%s]", o.Defecto)
+		}
+		guardar := func(v string) error {
+			desdeFlags = append(desdeFlags, valorFlag{o.Clave, v})
+			return nil
+		}
+		if o.Tipo == Bool {
+			fs.BoolFunc(nombreFlag(o.Clave), uso, guardar)
+		} else {
+			fs.Func(nombreFlag(o.Clave), uso, guardar)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	if *archivo != "" {
+		desdeArchivo, err := leerArchivo(*archivo)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, k := range ordenadas(desdeArchivo) {
+			if _, ok := porClave[k]; !ok {
+				errs = append(errs, fmt.Errorf("%s: clave desconocida %s", *archivo, k))
+				continue
+			}
+			c.valores[k], c.origen[k] = desdeArchivo[k], filepath.Base(*archivo)
+		}
+	}
+	for _, o := range opciones {
+		if v := os.Getenv(o.Clave); v != "" {
+			c.valores[o.Clave], c.origen[o.Clave] = v, origenEntorno
+		}
+	}
+	for _, f := range desdeFlags {
+		c.valores[f.clave], c.origen[f.clave] = f.valor, origenFlag
+	}
+
+	for _, o := range opciones {
+		v, ok := c.valores[o.Clave]
+		if !ok || v == "" {
+			continue
+		}
+		norm, err := validar(o, v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q (%s): %w", o.Clave, v, c.origen[o.Clave], err))
+			continue
+		}
+		c.valores[o.Clave] = norm
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	// Los valores efectivos quedan en el entorno para el resto del código
+	for k, v := range c.valores {
+		if v != "" {
+			os.Setenv(k, v)
+		}
+	}
+	return c, fs.Args(), nil
+}
+
+// nombreFlag convierte GRPC_PORT en grpc-port.
+func nombreFlag(clave string) string {
+	return strings.ReplaceAll(strings.ToLower(clave), "_", "-")
+}
+
+func validar(o Opcion, v string) (string, error) {
+	if len(o.Valores) > 0 && !slices.Contains(o.Valores, v) {
+		return "", fmt.Errorf("se espera uno de %s", strings.Join(o.Valores, ", "))
+	}
+	switch o.Tipo {
+	case Entero:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return "", errors.New("se espera un entero >= 0")
+		}
+		if err := rango(o, float64(n)); err != nil {
+			return "", err
+		}
+	case Decimal:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", errors.New("se espera un número >= 0")
+		}
+		if err := rango(o, f); err != nil {
+			return "", err
+		}
+	case Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", errors.New("se espera true o false")
+		}
+		return strconv.FormatBool(b), nil
+	case Duracion:
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return "", errors.New("se espera una duración como 500ms, 2s o 1m")
+		}
+		if o.Positivo && d == 0 {
+			return "", errors.New("se espera una duración > 0")
+		}
+	case Puerto:
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			return "", errors.New("se espera un puerto 1-65535")
+		}
+	case Direccion:
+		_, p, err := net.SplitHostPort(v)
+		if n, errP := strconv.Atoi(p); err != nil || errP != nil || n < 0 || n > 65535 {
+			return "", errors.New("se espera host:puerto o :puerto")
+		}
+	case Lista:
+		partes := strings.Split(v, ",")
+		for i := range partes {
+			partes[i] = strings.TrimSpace(partes[i])
+		}
+		return strings.Join(slices.DeleteFunc(partes, func(s string) bool { return s == "" }), ","), nil
+	}
+	return v, nil
+}
+
+// rango aplica Positivo y Menor a un número ya validado como >= 0.
+func rango(o Opcion, x float64) error {
+	if o.Positivo && x == 0 {
+		return errors.New("se espera un valor > 0")
+	}
+	if o.Menor != "" {
+		max, err := strconv.ParseFloat(o.Menor, 64)
+		if err != nil {
+			return fmt.Errorf("cota Menor inválida %q", o.Menor)
+		}
+		if x >= max {
+			return fmt.Errorf("se espera un valor menor que %s", o.Menor)
+		}
+	}
+	return nil
+}
+
+// leerArchivo devuelve las claves del archivo ya aplanadas (GRPC_PORT).
+func leerArchivo(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var crudo map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &crudo)
+	case ".toml":
+		_, err = toml.Decode(string(b), &crudo)
+	default:
+		return nil, fmt.Errorf("%s: extensión desconocida, se espera .yaml, .yml o .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	plano := map[string]string{}
+	if err := aplanar("", crudo, plano); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plano, nil
+}
+
+func aplanar(prefijo string, m map[string]any, out map[string]string) error {
+	for k, v := range m {
+		clave := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefijo != "" {
+			clave = prefijo + "_" + clave
+		}
+		switch x := v.(type) {
+		case map[string]any:
+			if err := aplanar(clave, x, out); err != nil {
+				return err
+			}
+		case []any:
+			partes := make([]string, len(x))
+			for i, e := range x {
+				s, err := escalar(clave, e)
+				if err != nil {
+					return err
+				}
+				partes[i] = s
+			}
+			out[clave] = strings.Join(partes, ",")
+		default:
+			s, err := escalar(clave, x)
+			if err != nil {
+				return err
+			}
+			out[clave] = s
+		}
+	}
+	return nil
+}
+
+func escalar(clave string, v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case int:
+		return strconv.Itoa(x), nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("%s: valor %v no soportado", clave, v)
+}
+
+func ordenadas(m map[string]string) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// LeerEntero, LeerDecimal y LeerDuracion devuelven el valor de una opción
+// ya validada por Cargar, o def si la variable está vacía (opción sin valor
+// o binario que no pasó por Cargar). Un valor inválido también devuelve def:
+// con Cargar no puede llegar hasta acá.
+func LeerEntero(clave string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(clave)); err == nil {
+		return n
+	}
+	return def
+}
+
+func LeerDecimal(clave string, def float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(clave), 64); err == nil {
+		return f
+	}
+	return def
+}
+
+func LeerDuracion(clave string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(clave)); err == nil {
+		return d
+	}
+	return def
+}
+
+// Escribir muestra la configuración efectiva como YAML plano (sirve de
+// archivo de configuración), con el origen de cada valor. Los secretos se
+// ocultan.
+func (c *Config) Escribir(w io.Writer) {
+	fmt.Fprintf(w, "# configuración efectiva de %s\n", c.Nombre)
+	for _, o := range c.opciones {
+		v := c.valores[o.Clave]
+		if o.Secreto && v != "" {
+			v = "****"
+		}
+		origen := c.origen[o.Clave]
+		if origen == "" {
+			origen = origenDefecto
+		}
+		fmt.Fprintf(w, "%s: %q # %s; %s\n", o.Clave, v, origen, o.Desc)
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var opcionesPrueba = []Opcion{
+	{Clave: "PRUEBA_PUERTO", Tipo: Puerto, Defecto: "50051", Desc: "puerto"},
+	{Clave: "PRUEBA_MODO", Tipo: Texto, Defecto: "a", Desc: "modo", Valores: []string{"a", "b", "c"}},
+	{Clave: "PRUEBA_LOTE", Tipo: Entero, Defecto: "10", Desc: "lote", Positivo: true},
+	{Clave: "PRUEBA_FRACCION", Tipo: Decimal, Defecto: "0.5", Desc: "fracción", Menor: "1"},
+	{Clave: "PRUEBA_TLS", Tipo: Bool, Desc: "tls"},
+	{Clave: "PRUEBA_INTERVALO", Tipo: Duracion, Defecto: "1s", Desc: "intervalo", Positivo: true},
+	{Clave: "PRUEBA_DIR", Tipo: Direccion, Desc: "dirección"},
+	{Clave: "PRUEBA_BROKERS", Tipo: Lista, Desc: "brokers"},
+	{Clave: "PRUEBA_TOKEN", Tipo: Texto, Desc: "token", Secreto: true},
+}
+
+// limpiarEntorno deja vacías las variables de prueba y CONFIG_FILE; Cargar
+// escribe los valores efectivos en el entorno y t.Setenv los restaura al
+// terminar cada test.
+func limpiarEntorno(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, o := range opcionesPrueba {
+		t.Setenv(o.Clave, "")
+	}
+}
+
+func escribirArchivo(t *testing.T, nombre, contenido string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), nombre)
+	if err := os.WriteFile(path, []byte(contenido), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCargarPrecedencia(t *testing.T) {
+	yaml := escribirArchivo(t, "config.yaml", "prueba:\n  puerto: 6000\n  modo: b\nPRUEBA_LOTE: 20\nPRUEBA_BROKERS: [k1:9092, k2:9092]\n")
+	toml := escribirArchivo(t, "config.toml", "PRUEBA_PUERTO = 7000\n[prueba]\nmodo = \"b\"\n")
+
+	casos := []struct {
+		nombre  string
+		entorno map[string]string
+		args    []string
+		esperar map[string]string
+		origen  map[string]string
+	}{
+		{
+			nombre:  "defecto",
+			esperar: map[string]string{"PRUEBA_PUERTO": "50051", "PRUEBA_MODO": "a", "PRUEBA_LOTE": "10"},
+			origen:  map[string]string{"PRUEBA_PUERTO": origenDefecto},
+		},
+		{
+			nombre:  "archivo yaml sobre defecto",
+			args:    []string{"--config", yaml},
+			esperar: map[string]string{"PRUEBA_PUERTO": "6000", "PRUEBA_MODO": "b", "PRUEBA_LOTE": "20", "PRUEBA_BROKERS": "k1:9092,k2:9092"},
+			origen:  map[string]string{"PRUEBA_PUERTO": "config.yaml", "PRUEBA_FRACCION": origenDefecto},
+		},
+		{
+			nombre:  "archivo toml por CONFIG_FILE",
+			entorno: map[string]string{"CONFIG_FILE": toml},
+			esperar: map[string]string{"PRUEBA_PUERTO": "7000", "PRUEBA_MODO": "b"},
+			origen:  map[string]string{"PRUEBA_PUERTO": "config.toml"},
+		},
+		{
+			nombre:  "entorno sobre archivo",
+			entorno: map[string]string{"PRUEBA_PUERTO": "6100", "PRUEBA_TLS": "1"},
+			args:    []string{"--config", yaml},
+			esperar: map[string]string{"PRUEBA_PUERTO": "6100", "PRUEBA_MODO": "b", "PRUEBA_TLS": "true"},
+			origen:  map[string]string{"PRUEBA_PUERTO": origenEntorno, "PRUEBA_MODO": "config.yaml"},
+		},
+		{
+			nombre:  "flags sobre entorno y archivo",
+			entorno: map[string]string{"PRUEBA_PUERTO": "6100", "PRUEBA_MODO": "c"},
+			args:    []string{"--config", yaml, "--prueba-puerto", "6200", "--prueba-tls", "--prueba-brokers", " x:1 ,, y:2 "},
+			esperar: map[string]string{"PRUEBA_PUERTO": "6200", "PRUEBA_MODO": "c", "PRUEBA_LOTE": "20", "PRUEBA_TLS": "true", "PRUEBA_BROKERS": "x:1,y:2"},
+			origen:  map[string]string{"PRUEBA_PUERTO": origenFlag, "PRUEBA_MODO": origenEntorno, "PRUEBA_LOTE": "config.yaml"},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			limpiarEntorno(t)
+			for k, v := range c.entorno {
+				t.Setenv(k, v)
+			}
+			cfg, resto, err := Cargar("prueba", opcionesPrueba, append(c.args, "subcomando"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resto) != 1 || resto[0] != "subcomando" {
+				t.Errorf("resto = %q", resto)
+			}
+			for k, v := range c.esperar {
+				if cfg.valores[k] != v {
+					t.Errorf("%s = %q, esperaba %q", k, cfg.valores[k], v)
+				}
+				if os.Getenv(k) != v {
+					t.Errorf("entorno %s = %q, esperaba %q", k, os.Getenv(k), v)
+				}
+			}
+			for k, v := range c.origen {
+				if cfg.origen[k] != v {
+					t.Errorf("origen de %s = %q, esperaba %q", k, cfg.origen[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCargarInvalido(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		entorno map[string]string
+		args    []string
+		archivo string // contenido de config.yaml
+		errores []string
+	}{
+		{nombre: "puerto no numérico", entorno: map[string]string{"PRUEBA_PUERTO": "http"}, errores: []string{"PRUEBA_PUERTO", "1-65535"}},
+		{nombre: "puerto fuera de rango", args: []string{"--prueba-puerto", "70000"}, errores: []string{"PRUEBA_PUERTO", "(flag)", "1-65535"}},
+		{nombre: "valor no permitido", entorno: map[string]string{"PRUEBA_MODO": "z"}, errores: []string{"uno de a, b, c"}},
+		{nombre: "entero negativo", entorno: map[string]string{"PRUEBA_LOTE": "-1"}, errores: []string{"entero >= 0"}},
+		{nombre: "entero cero con Positivo", entorno: map[string]string{"PRUEBA_LOTE": "0"}, errores: []string{"> 0"}},
+		{nombre: "decimal no numérico", entorno: map[string]string{"PRUEBA_FRACCION": "NaN"}, errores: []string{"número >= 0"}},
+		{nombre: "decimal en la cota Menor", entorno: map[string]string{"PRUEBA_FRACCION": "1"}, errores: []string{"menor que 1"}},
+		{nombre: "decimal sobre la cota Menor", entorno: map[string]string{"PRUEBA_FRACCION": "1.5"}, errores: []string{"menor que 1"}},
+		{nombre: "bool inválido", entorno: map[string]string{"PRUEBA_TLS": "si"}, errores: []string{"true o false"}},
+		{nombre: "duración sin unidad", entorno: map[string]string{"PRUEBA_INTERVALO": "5"}, errores: []string{"duración"}},
+		{nombre: "duración cero con Positivo", entorno: map[string]string{"PRUEBA_INTERVALO": "0s"}, errores: []string{"duración > 0"}},
+		{nombre: "dirección sin puerto", entorno: map[string]string{"PRUEBA_DIR": "localhost"}, errores: []string{"host:puerto"}},
+		{nombre: "clave desconocida en el archivo", archivo: "PRUEBA_PUERTO: 1\nPRUEBA_OTRA: x\n", errores: []string{"clave desconocida PRUEBA_OTRA"}},
+		{nombre: "valor inválido en el archivo", archivo: "prueba:\n  lote: cero\n", errores: []string{"PRUEBA_LOTE", "(config.yaml)"}},
+		{
+			nombre:  "varios errores juntos",
+			entorno: map[string]string{"PRUEBA_PUERTO": "0", "PRUEBA_TLS": "x", "PRUEBA_FRACCION": "2"},
+			errores: []string{"PRUEBA_PUERTO", "PRUEBA_TLS", "PRUEBA_FRACCION"},
+		},
+		{nombre: "flag desconocido", args: []string{"--prueba-nada", "1"}, errores: []string{"prueba-nada"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			limpiarEntorno(t)
+			for k, v := range c.entorno {
+				t.Setenv(k, v)
+			}
+			args := c.args
+			if c.archivo != "" {
+				args = append([]string{"--config", escribirArchivo(t, "config.yaml", c.archivo)}, args...)
+			}
+			_, _, err := Cargar("prueba", opcionesPrueba, args)
+			if err == nil {
+				t.Fatal("Cargar aceptó una configuración inválida")
+			}
+			for _, e := range c.errores {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("error %q no menciona %q", err, e)
+				}
+			}
+		})
+	}
+}
+
+func TestCargarNoModificaEntornoConError(t *testing.T) {
+	limpiarEntorno(t)
+	t.Setenv("PRUEBA_PUERTO", "6000")
+	t.Setenv("PRUEBA_TLS", "x")
+	if _, _, err := Cargar("prueba", opcionesPrueba, nil); err == nil {
+		t.Fatal("esperaba error")
+	}
+	if v := os.Getenv("PRUEBA_LOTE"); v != "" {
+		t.Errorf("PRUEBA_LOTE = %q; con error no se debe tocar el entorno", v)
+	}
+}
+
+func TestCargarAyuda(t *testing.T) {
+	limpiarEntorno(t)
+	_, _, err := Cargar("prueba", opcionesPrueba, []string{"-h"})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("err = %v, esperaba flag.ErrHelp", err)
+	}
+}
+
+func TestEscribir(t *testing.T) {
+	limpiarEntorno(t)
+	t.Setenv("PRUEBA_TOKEN", "secreto")
+	cfg, _, err := Cargar("prueba", opcionesPrueba, []string{"--print-config", "--prueba-lote", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Imprimir {
+		t.Error("--print-config no quedó en Imprimir")
+	}
+	var b bytes.Buffer
+	cfg.Escribir(&b)
+	salida := b.String()
+	for _, s := range []string{
+		`PRUEBA_LOTE: "5" # flag;`,
+		`PRUEBA_PUERTO: "50051" # defecto;`,
+		`PRUEBA_TOKEN: "****" # entorno;`,
+	} {
+		if !strings.Contains(salida, s) {
+			t.Errorf("falta %q en:\n%s", s, salida)
+		}
+	}
+	if strings.Contains(salida, "secreto") {
+		t.Error("--print-config muestra un secreto")
+	}
+}
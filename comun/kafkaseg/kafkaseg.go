@@ -0,0 +1,217 @@
+// Package kafkaseg arma la autenticación SASL y el TLS de las conexiones a
+// Kafka desde variables de entorno. El esquema es el mismo en el producer
+// (gRPC server) y en los consumers (k8s_kafka):
+//
+//	KAFKA_SASL_MECHANISM     vacío (sin SASL), PLAIN, SCRAM-SHA-256 o SCRAM-SHA-512
+//	KAFKA_SASL_USERNAME      usuario SASL
+//	KAFKA_SASL_PASSWORD      contraseña SASL
+//	KAFKA_TLS                true para TLS con las CA del sistema
+//	KAFKA_TLS_CA             CA PEM del cluster (activa TLS)
+//	KAFKA_TLS_CERT/KEY       certificado de cliente PEM (activa TLS, mTLS)
+//	KAFKA_TLS_SERVER_NAME    nombre esperado en el certificado del broker
+//	KAFKA_TLS_INSECURE       no verificar el certificado (solo pruebas)
+//
+// PLAIN manda la contraseña tal cual: sin TLS solo sirve dentro de una red
+// de confianza, así que se avisa al arrancar.
+package kafkaseg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Mecanismos SASL soportados (KAFKA_SASL_MECHANISM).
+const (
+	PLAIN       = "PLAIN"
+	SCRAMSHA256 = "SCRAM-SHA-256"
+	SCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// Mecanismos son los valores aceptados de KAFKA_SASL_MECHANISM.
+var Mecanismos = []string{PLAIN, SCRAMSHA256, SCRAMSHA512}
+
+// Config es la configuración de seguridad tal como viene del entorno.
+type Config struct {
+	Mecanismo string
+	Usuario   string
+	Password  string
+
+	TLS           bool
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+	TLSInseguro   bool
+}
+
+// DesdeEnv lee la configuración de las variables KAFKA_SASL_* y KAFKA_TLS_*.
+// Indicar CA o certificado de cliente activa TLS aunque KAFKA_TLS no esté.
+func DesdeEnv() Config {
+	c := Config{
+		Mecanismo: strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM")),
+		Usuario:   os.Getenv("KAFKA_SASL_USERNAME"),
+		Password:  os.Getenv("KAFKA_SASL_PASSWORD"),
+
+		TLSCA:         os.Getenv("KAFKA_TLS_CA"),
+		TLSCert:       os.Getenv("KAFKA_TLS_CERT"),
+		TLSKey:        os.Getenv("KAFKA_TLS_KEY"),
+		TLSServerName: os.Getenv("KAFKA_TLS_SERVER_NAME"),
+	}
+	c.TLS, _ = strconv.ParseBool(os.Getenv("KAFKA_TLS"))
+	c.TLS = c.TLS || c.TLSCA != "" || c.TLSCert != ""
+	c.TLSInseguro, _ = strconv.ParseBool(os.Getenv("KAFKA_TLS_INSECURE"))
+	return c
+}
+
+// Seguridad son el mecanismo SASL y el TLS ya armados; el valor cero es una
+// conexión en texto plano sin autenticación (el comportamiento original).
+type Seguridad struct {
+	SASL sasl.Mechanism
+	TLS  *tls.Config
+
+	desc string
+}
+
+// Nueva valida la configuración y carga los archivos PEM. Un error acá es
+// config inválida para el llamador.
+func Nueva(c Config) (*Seguridad, error) {
+	s := &Seguridad{}
+	var errs []error
+
+	switch c.Mecanismo {
+	case "":
+		if c.Usuario != "" || c.Password != "" {
+			errs = append(errs, errors.New("KAFKA_SASL_USERNAME/PASSWORD sin KAFKA_SASL_MECHANISM"))
+		}
+	case PLAIN, SCRAMSHA256, SCRAMSHA512:
+		if c.Usuario == "" || c.Password == "" {
+			errs = append(errs, fmt.Errorf("KAFKA_SASL_MECHANISM=%s requiere KAFKA_SASL_USERNAME y KAFKA_SASL_PASSWORD", c.Mecanismo))
+			break
+		}
+		m, err := mecanismo(c.Mecanismo, c.Usuario, c.Password)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		s.SASL = m
+	default:
+		errs = append(errs, fmt.Errorf("KAFKA_SASL_MECHANISM inválido: %q (%s)", c.Mecanismo, strings.Join(Mecanismos, ", ")))
+	}
+
+	if c.TLS {
+		tc, err := c.configTLS()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		s.TLS = tc
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	s.desc = c.String()
+	return s, nil
+}
+
+func mecanismo(nombre, usuario, password string) (sasl.Mechanism, error) {
+	switch nombre {
+	case PLAIN:
+		return plain.Mechanism{Username: usuario, Password: password}, nil
+	case SCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, usuario, password)
+	default:
+		return scram.Mechanism(scram.SHA512, usuario, password)
+	}
+}
+
+func (c Config) configTLS() (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInseguro,
+	}
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("leyendo KAFKA_TLS_CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("KAFKA_TLS_CA sin certificados válidos")
+		}
+		tc.RootCAs = pool
+	}
+	if c.TLSCert != "" || c.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("cargando KAFKA_TLS_CERT/KEY: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// String describe la configuración para el log de arranque, sin secretos.
+func (c Config) String() string {
+	partes := []string{"sasl=off"}
+	if c.Mecanismo != "" {
+		partes[0] = fmt.Sprintf("sasl=%s usuario=%s", c.Mecanismo, c.Usuario)
+	}
+	switch {
+	case !c.TLS:
+		partes = append(partes, "tls=off")
+	case c.TLSCert != "":
+		partes = append(partes, "tls=mtls")
+	default:
+		partes = append(partes, "tls=on")
+	}
+	if c.TLSInseguro {
+		partes = append(partes, "tls_insecure=true")
+	}
+	return strings.Join(partes, " ")
+}
+
+// String describe la seguridad efectiva para el log de arranque.
+func (s *Seguridad) String() string {
+	if s.desc == "" {
+		return "sasl=off tls=off"
+	}
+	return s.desc
+}
+
+// PlanoConPassword indica una contraseña PLAIN que viaja sin cifrar.
+func (s *Seguridad) PlanoConPassword() bool {
+	_, ok := s.SASL.(plain.Mechanism)
+	return ok && s.TLS == nil
+}
+
+// Transport es el transporte de kafka.Writer y kafka.Client.
+func (s *Seguridad) Transport(timeout time.Duration) *kafka.Transport {
+	return &kafka.Transport{
+		DialTimeout: timeout,
+		SASL:        s.SASL,
+		TLS:         s.TLS,
+	}
+}
+
+// Dialer es el dialer de kafka.Reader y de las conexiones directas
+// (kafka.Dialer.DialContext / DialLeader).
+func (s *Seguridad) Dialer(timeout time.Duration) *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       timeout,
+		DualStack:     true,
+		SASLMechanism: s.SASL,
+		TLS:           s.TLS,
+	}
+}
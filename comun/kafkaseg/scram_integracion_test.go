@@ -0,0 +1,121 @@
+//go:build integracion
+
+package kafkaseg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Prueba SCRAM contra un broker real; ver testdata/docker-compose.yml.
+//
+//	KAFKA_TEST_BROKER  broker con el listener SASL (por defecto localhost:9094)
+
+const (
+	usuarioPrueba  = "ventas"
+	passwordPrueba = "ventas-secreto"
+)
+
+func brokerPrueba() string {
+	if b := os.Getenv("KAFKA_TEST_BROKER"); b != "" {
+		return b
+	}
+	return "localhost:9094"
+}
+
+// esperarBroker reintenta hasta que el broker arranque y el servicio
+// usuarios haya dado de alta la credencial.
+func esperarBroker(t *testing.T, s *Seguridad) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for {
+		conn, err := s.Dialer(5*time.Second).DialContext(ctx, "tcp", brokerPrueba())
+		if err == nil {
+			conn.Close()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("broker %s sin responder con SASL: %v", brokerPrueba(), err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func TestSCRAMBroker(t *testing.T) {
+	for _, mec := range []string{SCRAMSHA256, SCRAMSHA512} {
+		t.Run(mec, func(t *testing.T) {
+			s, err := Nueva(Config{Mecanismo: mec, Usuario: usuarioPrueba, Password: passwordPrueba})
+			if err != nil {
+				t.Fatal(err)
+			}
+			esperarBroker(t, s)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// kafka.Client y kafka.Writer usan Transport
+			topic := "kafkaseg-" + mec + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+			cli := &kafka.Client{Addr: kafka.TCP(brokerPrueba()), Transport: s.Transport(5 * time.Second)}
+			resp, err := cli.CreateTopics(ctx, &kafka.CreateTopicsRequest{
+				Topics: []kafka.TopicConfig{{Topic: topic, NumPartitions: 1, ReplicationFactor: 1}},
+			})
+			if err != nil {
+				t.Fatalf("CreateTopics: %v", err)
+			}
+			if err := resp.Errors[topic]; err != nil {
+				t.Fatalf("CreateTopics %s: %v", topic, err)
+			}
+			w := &kafka.Writer{Addr: kafka.TCP(brokerPrueba()), Topic: topic, Transport: s.Transport(5 * time.Second)}
+			defer w.Close()
+			if err := w.WriteMessages(ctx, kafka.Message{Key: []byte("p1"), Value: []byte(mec)}); err != nil {
+				t.Fatalf("WriteMessages: %v", err)
+			}
+
+			// kafka.Reader usa Dialer
+			r := kafka.NewReader(kafka.ReaderConfig{
+				Brokers: []string{brokerPrueba()},
+				Topic:   topic,
+				Dialer:  s.Dialer(5 * time.Second),
+			})
+			defer r.Close()
+			m, err := r.ReadMessage(ctx)
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			if string(m.Value) != mec {
+				t.Fatalf("leí %q, esperaba %q", m.Value, mec)
+			}
+		})
+	}
+}
+
+func TestSCRAMPasswordIncorrecta(t *testing.T) {
+	bien, err := Nueva(Config{Mecanismo: SCRAMSHA512, Usuario: usuarioPrueba, Password: passwordPrueba})
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperarBroker(t, bien)
+
+	for _, c := range []Config{
+		{Mecanismo: SCRAMSHA512, Usuario: usuarioPrueba, Password: "otra"},
+		{Mecanismo: SCRAMSHA256, Usuario: "nadie", Password: passwordPrueba},
+	} {
+		s, err := Nueva(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = s.Dialer(5*time.Second).DialContext(ctx, "tcp", brokerPrueba())
+		cancel()
+		if !errors.Is(err, kafka.SASLAuthenticationFailed) {
+			t.Errorf("%s usuario=%s: err %v, esperaba SASLAuthenticationFailed", c.Mecanismo, c.Usuario, err)
+		}
+	}
+}
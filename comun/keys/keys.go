@@ -0,0 +1,266 @@
+// Package keys define el esquema de keys en Valkey que usan el consumer y
+// el gRPC server.
+//
+// Es la única definición de nombres de keys: el consumer la usa para
+// escribir, el gRPC server para el stock y el catálogo, y cualquier lector
+// de estadísticas puede importarla ("comun/keys") para leer exactamente las
+// mismas keys.
+//
+// El prefijo es configurable (por defecto "venta") para poder correr varios
+// ambientes o eventos contra el mismo Valkey. Con hash tag el prefijo se
+// escribe como "{venta}", de modo que en Valkey Cluster todas las keys del
+// namespace caen en el mismo slot y los scripts Lua / MULTI que tocan varias
+// keys siguen siendo válidos. El costo es que todo el namespace vive en un
+// solo nodo del cluster.
+package keys
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultPrefix es el prefijo histórico que usan los dashboards de Grafana.
+const DefaultPrefix = "venta"
+
+// Schema construye los nombres de keys para un prefijo dado.
+type Schema struct {
+	base string
+}
+
+// New crea un esquema. Si hashTag es true el prefijo se envuelve en llaves.
+func New(prefix string, hashTag bool) Schema {
+	prefix = strings.Trim(prefix, "{}:")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	if hashTag {
+		prefix = "{" + prefix + "}"
+	}
+	return Schema{base: prefix}
+}
+
+// Prefix devuelve el prefijo efectivo (con llaves si usa hash tag).
+func (s Schema) Prefix() string { return s.base }
+
+// HashTag indica si las keys comparten slot en Valkey Cluster.
+func (s Schema) HashTag() bool { return strings.HasPrefix(s.base, "{") }
+
+// Sombra devuelve el esquema del namespace temporal que usa el rebuild
+// (<prefix>:rebuild:...). Conserva el hash tag, así que en Cluster las keys
+// sombra y las vivas están en el mismo slot y se pueden renombrar juntas.
+func (s Schema) Sombra() Schema { return Schema{base: s.base + ":rebuild"} }
+
+// PatronesAgregados son los patrones SCAN que cubren todos los agregados
+// (stats, series de precio y offsets aplicados), sin eventos crudos ni locks.
+func (s Schema) PatronesAgregados() []string {
+	b := escaparGlob(s.base)
+	return []string{b + ":stats:*", b + ":ts:*", b + ":ts2:*", b + ":offset:*"}
+}
+
+func escaparGlob(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+	return r.Replace(v)
+}
+
+func (s Schema) k(partes ...string) string {
+	return s.base + ":" + strings.Join(partes, ":")
+}
+
+func (s Schema) stats(partes ...string) string {
+	return s.k(append([]string{"stats"}, partes...)...)
+}
+
+// ===== Evento crudo =====
+
+// Evento es la key del mensaje crudo: <prefix>:<topic>:<partition>:<offset>.
+func (s Schema) Evento(topic string, partition int, offset int64) string {
+	return s.k(topic, strconv.Itoa(partition), strconv.FormatInt(offset, 10))
+}
+
+// EventosStream es el Stream con los eventos crudos de un topic
+// (RAW_EVENTS_MODE=stream|compact).
+func (s Schema) EventosStream(topic string) string {
+	return s.k("eventos", topic)
+}
+
+// LockCompactacion evita que dos réplicas archiven el mismo tramo del Stream.
+func (s Schema) LockCompactacion(topic string) string {
+	return s.k("lock", "compactacion", topic)
+}
+
+// OffsetAplicado es el último offset de la partición ya aplicado a los
+// agregados; se escribe en la misma transacción que los agregados.
+func (s Schema) OffsetAplicado(topic string, partition int) string {
+	return s.k("offset", topic, strconv.Itoa(partition))
+}
+
+// LockLider es el lock con lease de la réplica que recalcula los KPIs derivados.
+func (s Schema) LockLider() string {
+	return s.k("lock", "lider")
+}
+
+// ===== Alertas de anomalías =====
+
+// Alertas es la LIST con las últimas alertas (JSON, la más nueva primero).
+func (s Schema) Alertas() string {
+	return s.k("alertas")
+}
+
+// AlertasPorRegla es un HASH regla -> cantidad de alertas.
+func (s Schema) AlertasPorRegla() string {
+	return s.k("alertas", "por_regla")
+}
+
+// EstadoMaterializador es un HASH con la última corrida del materializador
+// (actualizado, duracion_ms, intervalo_ms, replica) para medir staleness.
+func (s Schema) EstadoMaterializador() string {
+	return s.stats("materializador")
+}
+
+// ===== Inventario =====
+
+// Las keys de stock las escribe el gRPC server al reservar cada venta; no
+// están bajo stats:, así que el rebuild no las toca.
+
+func (s Schema) Stock() string        { return s.k("stock") }            // HASH: productoId -> unidades disponibles
+func (s Schema) StockInicial() string { return s.k("stock", "inicial") } // HASH: productoId -> unidades cargadas
+func (s Schema) StockAgotado() string { return s.k("stock", "agotado") } // HASH: productoId -> unix ms en que llegó a 0
+
+// Publicados por el materializador a partir de las anteriores.
+
+func (s Schema) StockRestante() string { return s.stats("stock_restante") } // HASH: productoId -> unidades disponibles
+func (s Schema) StockAgotadoEn() string {
+	return s.stats("stock_agotado_en") // HASH: productoId -> hora RFC3339 en que se agotó
+}
+func (s Schema) StockAgotamientoEstimado() string {
+	return s.stats("stock_agotamiento_estimado") // HASH: productoId -> hora RFC3339 estimada según el ritmo de venta
+}
+
+// ===== Catálogo =====
+
+// El catálogo lo administra el gRPC server (CatalogoService); no está bajo
+// stats:, así que el rebuild no lo toca.
+
+func (s Schema) Catalogo() string        { return s.k("catalogo") }            // HASH: productoId -> JSON del producto
+func (s Schema) CatalogoNombres() string { return s.k("catalogo", "nombres") } // HASH: productoId -> nombre
+
+// Profundidad de descuento (precio de venta contra precio de lista) de las
+// ventas de productos del catálogo.
+
+func (s Schema) DescuentoSuma() string   { return s.stats("descuento_suma") }   // HASH: categoria -> suma(descuento %)
+func (s Schema) DescuentoConteo() string { return s.stats("descuento_conteo") } // HASH: categoria -> ventas con precio de lista
+func (s Schema) DescuentoPromedio() string {
+	return s.stats("descuento_promedio") // HASH: categoria -> avg(descuento %)
+}
+
+// ===== Agregados por categoría =====
+
+func (s Schema) SumPrecio() string            { return s.stats("sumPrecio") }              // HASH: categoria -> suma(precio)
+func (s Schema) Conteo() string               { return s.stats("count") }                  // HASH: categoria -> conteo
+func (s Schema) PromedioPorCategoria() string { return s.stats("categorias") }             // HASH: categoria -> avg(precio)
+func (s Schema) ReportesPorCategoria() string { return s.stats("reportes_por_categoria") } // HASH: categoria -> total reportes
+func (s Schema) PromedioGlobal() string       { return s.stats("precio_promedio_global") } // STRING: avg(precio) global
+
+// ===== Por tenant (tienda autenticada en el gateway) =====
+
+func (s Schema) ReportesPorTenant() string  { return s.stats("tenant", "reportes") }  // HASH: tenant -> total reportes
+func (s Schema) UnidadesPorTenant() string  { return s.stats("tenant", "unidades") }  // HASH: tenant -> unidades vendidas
+func (s Schema) SumPrecioPorTenant() string { return s.stats("tenant", "sumPrecio") } // HASH: tenant -> suma(precio)
+func (s Schema) PromedioPorTenant() string  { return s.stats("tenant", "promedio") }  // HASH: tenant -> avg(precio)
+
+// ReportesTenantPorCategoria es el HASH categoria -> reportes de un tenant.
+func (s Schema) ReportesTenantPorCategoria(tenant string) string {
+	return s.stats("tenant", tenant, "reportes_por_categoria")
+}
+
+// ===== Precio máximo / mínimo =====
+
+func (s Schema) PrecioMax() string             { return s.stats("precio_max") }               // STRING
+func (s Schema) PrecioMin() string             { return s.stats("precio_min") }               // STRING
+func (s Schema) PrecioMaxPorCategoria() string { return s.stats("precio_max_por_categoria") } // HASH: categoria -> max(precio)
+func (s Schema) PrecioMinPorCategoria() string { return s.stats("precio_min_por_categoria") } // HASH: categoria -> min(precio)
+
+// ===== Productos =====
+
+func (s Schema) ProductosVendidos() string    { return s.stats("productos_vendidos") }     // ZSET: score=cantidad, member=productoId
+func (s Schema) ProductoMasVendido() string   { return s.stats("producto_mas_vendido") }   // STRING: "ID (score)"
+func (s Schema) ProductoMenosVendido() string { return s.stats("producto_menos_vendido") } // STRING: "ID (score)"
+
+func (s Schema) BestProductoPorCategoria() string { return s.stats("best_producto_por_categoria") } // HASH: categoria -> productoId
+func (s Schema) BestAvgPrecioPorCategoria() string {
+	return s.stats("best_producto_avgprecio_por_categoria") // HASH: categoria -> avg(precio del best)
+}
+func (s Schema) BestQtyPorCategoria() string {
+	return s.stats("best_producto_qty_por_categoria") // HASH: categoria -> qty(best)
+}
+
+// ProductosPorCategoria es el ZSET score=cantidad, member=productoId de una categoría.
+func (s Schema) ProductosPorCategoria(categoria string) string {
+	return s.stats("productos_por_categoria", categoria)
+}
+
+// SumPrecioPorProducto es el HASH productoId -> suma(precio) de una categoría.
+func (s Schema) SumPrecioPorProducto(categoria string) string {
+	return s.stats("sumPrecio_por_producto", categoria)
+}
+
+// CountPorProducto es el HASH productoId -> conteo de una categoría.
+func (s Schema) CountPorProducto(categoria string) string {
+	return s.stats("count_por_producto", categoria)
+}
+
+// ===== Series de precio por producto =====
+
+// SeriePrecio es el ZSET score=unix, member=precio.
+func (s Schema) SeriePrecio(categoria, productoID string) string {
+	return s.k("ts", "precio", categoria, productoID)
+}
+
+// SeriePrecioHash es el HASH unix -> precio.
+func (s Schema) SeriePrecioHash(categoria, productoID string) string {
+	return s.k("ts2", "precio", categoria, productoID)
+}
+
+// ===== Top-K aproximado (TOPK_MODE=sketch) =====
+
+func (s Schema) TopKProductos() string { return s.stats("topk_productos") } // ZSET: top-K global
+
+// TopKPorCategoria es el ZSET con el top-K de una categoría.
+func (s Schema) TopKPorCategoria(categoria string) string {
+	return s.stats("topk_por_categoria", categoria)
+}
+
+// TopKAvgPrecio es el HASH productoId -> avg(precio) de los miembros del
+// top-K de una categoría.
+func (s Schema) TopKAvgPrecio(categoria string) string {
+	return s.stats("topk_avgprecio", categoria)
+}
+
+func (s Schema) ProductosDistintos() string      { return s.stats("productos_distintos") }       // HLL global
+func (s Schema) ProductosDistintosTotal() string { return s.stats("productos_distintos_total") } // STRING: PFCOUNT global
+func (s Schema) ProductosDistintosPorCategoria() string {
+	return s.stats("productos_distintos_por_categoria") // HASH: categoria -> PFCOUNT
+}
+
+// ProductosDistintosCategoria es el HLL de productos distintos de una categoría.
+func (s Schema) ProductosDistintosCategoria(categoria string) string {
+	return s.stats("productos_distintos", categoria)
+}
+
+// ===== Percentiles de precio =====
+
+// SketchPrecio es el HASH con los buckets del DDSketch de una categoría.
+func (s Schema) SketchPrecio(categoria string) string {
+	return s.stats("sketch", "precio", categoria)
+}
+
+// SketchPrecioGlobal es el HASH con los buckets del DDSketch de todas las
+// ventas; va aparte para no chocar con una categoría.
+func (s Schema) SketchPrecioGlobal() string { return s.stats("sketch", "precio_global") }
+
+func (s Schema) PrecioP50() string       { return s.stats("precio_p50") }        // HASH: categoria -> p50
+func (s Schema) PrecioP90() string       { return s.stats("precio_p90") }        // HASH: categoria -> p90
+func (s Schema) PrecioP99() string       { return s.stats("precio_p99") }        // HASH: categoria -> p99
+func (s Schema) PrecioP50Global() string { return s.stats("precio_p50_global") } // STRING: p50 global
+func (s Schema) PrecioP90Global() string { return s.stats("precio_p90_global") } // STRING: p90 global
+func (s Schema) PrecioP99Global() string { return s.stats("precio_p99_global") } // STRING: p99 global
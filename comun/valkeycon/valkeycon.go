@@ -0,0 +1,258 @@
+// Package valkeycon arma la conexión a Valkey desde variables de entorno,
+// con las mismas reglas en el consumer (k8s_kafka) y en el gRPC server.
+//
+// Tres modos (VALKEY_MODE):
+//
+//	standalone  un solo nodo en VALKEY_ADDR (comportamiento original)
+//	sentinel    VALKEY_MASTER_NAME + sentinels en VALKEY_ADDRS; ante un
+//	            failover el cliente pregunta a los sentinels por el nuevo
+//	            primario y reconecta solo
+//	cluster     nodos semilla en VALKEY_ADDRS; los MOVED/ASK tras un
+//	            failover o resharding los resuelve el cliente
+//
+// En modo cluster se fuerza el hash tag del esquema de keys
+// (VALKEY_HASH_TAG) para que todas las operaciones multi-key (pipelines
+// transaccionales, scripts Lua) caigan en un mismo slot.
+package valkeycon
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Modos de VALKEY_MODE.
+var Modos = []string{"standalone", "sentinel", "cluster"}
+
+// Config es la conexión tal como viene del entorno.
+type Config struct {
+	Modo       string
+	Addrs      []string // vacío: sin Valkey configurado
+	MasterName string
+
+	Usuario          string
+	Password         string
+	SentinelUsuario  string
+	SentinelPassword string
+	DB               int
+
+	TLS           bool
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+	TLSInseguro   bool
+
+	DialTimeout time.Duration
+	Timeout     time.Duration
+
+	// Esquema de keys: keys.New(Prefijo, HashTag)
+	Prefijo string
+	HashTag bool
+}
+
+// DesdeEnv lee VALKEY_*. VALKEY_ADDRS reemplaza a VALKEY_ADDR; los valores
+// sin definir quedan con los defectos del cliente (timeouts 5s y 3s,
+// prefijo "venta"). Un valor que no se puede interpretar (VALKEY_TLS=yes,
+// VALKEY_DB=uno) es un error: no se cae en silencio al defecto.
+func DesdeEnv() (Config, error) {
+	addrs := os.Getenv("VALKEY_ADDRS")
+	if addrs == "" {
+		addrs = os.Getenv("VALKEY_ADDR")
+	}
+	c := Config{
+		Modo:       os.Getenv("VALKEY_MODE"),
+		MasterName: os.Getenv("VALKEY_MASTER_NAME"),
+
+		Usuario:          os.Getenv("VALKEY_USERNAME"),
+		Password:         os.Getenv("VALKEY_PASSWORD"),
+		SentinelUsuario:  os.Getenv("VALKEY_SENTINEL_USERNAME"),
+		SentinelPassword: os.Getenv("VALKEY_SENTINEL_PASSWORD"),
+
+		TLSCA:         os.Getenv("VALKEY_TLS_CA"),
+		TLSCert:       os.Getenv("VALKEY_TLS_CERT"),
+		TLSKey:        os.Getenv("VALKEY_TLS_KEY"),
+		TLSServerName: os.Getenv("VALKEY_TLS_SERVER_NAME"),
+
+		DialTimeout: 5 * time.Second,
+		Timeout:     3 * time.Second,
+
+		Prefijo: os.Getenv("VALKEY_KEY_PREFIX"),
+	}
+	if c.Modo == "" {
+		c.Modo = "standalone"
+	}
+	for _, a := range strings.Split(addrs, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			c.Addrs = append(c.Addrs, a)
+		}
+	}
+
+	var errs []error
+	leer := func(clave string, parsear func(string) error) {
+		if v := os.Getenv(clave); v != "" {
+			if err := parsear(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s=%q: %w", clave, v, err))
+			}
+		}
+	}
+	booleano := func(dst *bool) func(string) error {
+		return func(v string) (err error) {
+			*dst, err = strconv.ParseBool(v)
+			if err != nil {
+				return errors.New("se espera true o false")
+			}
+			return nil
+		}
+	}
+	duracion := func(dst *time.Duration) func(string) error {
+		return func(v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return errors.New("se espera una duración > 0")
+			}
+			*dst = d
+			return nil
+		}
+	}
+	leer("VALKEY_DB", func(v string) (err error) {
+		c.DB, err = strconv.Atoi(v)
+		if err != nil || c.DB < 0 {
+			return errors.New("se espera un entero >= 0")
+		}
+		return nil
+	})
+	leer("VALKEY_TLS", booleano(&c.TLS))
+	leer("VALKEY_TLS_INSECURE", booleano(&c.TLSInseguro))
+	leer("VALKEY_HASH_TAG", booleano(&c.HashTag))
+	leer("VALKEY_DIAL_TIMEOUT", duracion(&c.DialTimeout))
+	leer("VALKEY_TIMEOUT", duracion(&c.Timeout))
+	c.HashTag = c.HashTag || c.Cluster()
+	return c, errors.Join(errs...)
+}
+
+// Cluster indica VALKEY_MODE=cluster.
+func (c Config) Cluster() bool { return c.Modo == "cluster" }
+
+// String describe la conexión para el log de arranque, sin secretos.
+func (c Config) String() string {
+	s := fmt.Sprintf("modo=%s addrs=%s", c.Modo, strings.Join(c.Addrs, ","))
+	if c.MasterName != "" {
+		s += " master=" + c.MasterName
+	}
+	if c.TLS {
+		s += " tls=on"
+	}
+	return s
+}
+
+// Nuevo valida la configuración y crea el cliente; no se conecta hasta el
+// primer comando.
+func Nuevo(c Config) (redis.UniversalClient, error) {
+	if len(c.Addrs) == 0 {
+		return nil, errors.New("VALKEY_ADDR/VALKEY_ADDRS vacío")
+	}
+	opts := &redis.UniversalOptions{
+		Addrs:            c.Addrs,
+		Username:         c.Usuario,
+		Password:         c.Password,
+		SentinelUsername: c.SentinelUsuario,
+		SentinelPassword: c.SentinelPassword,
+		MaxRetries:       5,
+		MinRetryBackoff:  50 * time.Millisecond,
+		MaxRetryBackoff:  2 * time.Second,
+		DialTimeout:      c.DialTimeout,
+		ReadTimeout:      c.Timeout,
+		WriteTimeout:     c.Timeout,
+	}
+
+	switch c.Modo {
+	case "standalone":
+		opts.Addrs = c.Addrs[:1]
+		opts.DB = c.DB
+	case "sentinel":
+		if c.MasterName == "" {
+			return nil, errors.New("VALKEY_MODE=sentinel requiere VALKEY_MASTER_NAME")
+		}
+		opts.MasterName = c.MasterName
+		opts.DB = c.DB
+	case "cluster":
+		if c.DB != 0 {
+			return nil, errors.New("VALKEY_DB no se soporta en modo cluster")
+		}
+		opts.IsClusterMode = true
+		opts.MaxRedirects = 8
+	default:
+		return nil, fmt.Errorf("VALKEY_MODE inválido: %q (%s)", c.Modo, strings.Join(Modos, "|"))
+	}
+
+	if c.TLS || c.TLSCA != "" || c.TLSCert != "" {
+		tc, err := c.configTLS()
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tc
+	}
+
+	return redis.NewUniversalClient(opts), nil
+}
+
+func (c Config) configTLS() (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInseguro,
+	}
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("leyendo VALKEY_TLS_CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("VALKEY_TLS_CA sin certificados válidos")
+		}
+		tc.RootCAs = pool
+	}
+	if c.TLSCert != "" || c.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("cargando VALKEY_TLS_CERT/KEY: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+// Ping hace un PING acotado a timeout.
+func Ping(ctx context.Context, rdb redis.UniversalClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return rdb.Ping(ctx).Err()
+}
+
+// Esperar reintenta PING hasta que Valkey responda o se cancele ctx (el
+// primario puede estar en medio de un failover cuando arranca el pod).
+func Esperar(ctx context.Context, rdb redis.UniversalClient, timeout time.Duration) error {
+	for intento := 1; ; intento++ {
+		err := Ping(ctx, rdb, timeout)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("Valkey no disponible", "intento", intento, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(min(intento, 10)) * 500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package valkeycon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDesdeEnv(t *testing.T) {
+	t.Setenv("VALKEY_ADDRS", "a:6379, b:6379,")
+	t.Setenv("VALKEY_MODE", "cluster")
+	t.Setenv("VALKEY_DB", "2")
+	t.Setenv("VALKEY_TLS", "true")
+	t.Setenv("VALKEY_TIMEOUT", "250ms")
+	c, err := DesdeEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Addrs) != 2 || c.Addrs[1] != "b:6379" {
+		t.Errorf("Addrs = %q", c.Addrs)
+	}
+	if c.DB != 2 || !c.TLS || c.Timeout != 250*time.Millisecond || c.DialTimeout != 5*time.Second {
+		t.Errorf("DB=%d TLS=%v Timeout=%v DialTimeout=%v", c.DB, c.TLS, c.Timeout, c.DialTimeout)
+	}
+	if !c.HashTag {
+		t.Error("cluster sin hash tag")
+	}
+}
+
+func TestDesdeEnvInvalido(t *testing.T) {
+	casos := []struct{ clave, valor string }{
+		{"VALKEY_DB", "uno"},
+		{"VALKEY_DB", "-1"},
+		{"VALKEY_TLS", "yes"},
+		{"VALKEY_TLS_INSECURE", "si"},
+		{"VALKEY_HASH_TAG", "2"},
+		{"VALKEY_DIAL_TIMEOUT", "5"},
+		{"VALKEY_TIMEOUT", "0s"},
+		{"VALKEY_TIMEOUT", "-1s"},
+	}
+	for _, c := range casos {
+		t.Run(c.clave+"="+c.valor, func(t *testing.T) {
+			t.Setenv("VALKEY_ADDR", "valkey:6379")
+			t.Setenv(c.clave, c.valor)
+			_, err := DesdeEnv()
+			if err == nil || !strings.Contains(err.Error(), c.clave) {
+				t.Fatalf("err = %v, esperaba un error de %s", err, c.clave)
+			}
+		})
+	}
+}
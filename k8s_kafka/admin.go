@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+)
+
+// Modo admin: revisa los topics del pipeline contra la configuración
+// deseada y crea los que faltan.
+//
+//	k8s_kafka admin [-solo-revisar] [-estricto]
+//
+// Topics: KAFKA_TOPIC (ventas), ALERTAS_TOPIC (anomalías) y KAFKA_DLQ_TOPIC
+// (mensajes que el consumer no puede parsear). Para cada uno compara
+// particiones, factor de replicación y retention.ms.
+//
+// Un topic existente con otra configuración solo se avisa, no se corrige:
+// agregar particiones cambia la partición de cada key (se pierde el orden
+// por producto mientras conviven mensajes viejos y nuevos) y el factor de
+// replicación se cambia con una reasignación. Con -estricto cualquier
+// diferencia o topic faltante termina con error (para un initContainer o
+// un Job que deba frenar el despliegue).
+func admin(ctx context.Context, brokers []string, args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	soloRevisar := fs.Bool("solo-revisar", false, "no crear los topics que faltan")
+	estricto := fs.Bool("estricto", false, "terminar con error si falta un topic o difiere su configuración")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	deseados := topicsDeseados()
+	cli := &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: timeoutKafka(), Transport: segKafka.Transport(timeoutKafka())}
+
+	nombres := make([]string, len(deseados))
+	for i, d := range deseados {
+		nombres[i] = d.nombre
+	}
+	meta, err := cli.Metadata(ctx, &kafka.MetadataRequest{Topics: nombres})
+	if err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+	actuales := make(map[string]kafka.Topic, len(meta.Topics))
+	for _, t := range meta.Topics {
+		switch {
+		case t.Error == nil:
+			actuales[t.Name] = t
+		case !errors.Is(t.Error, kafka.UnknownTopicOrPartition):
+			return fmt.Errorf("metadata de %s: %w", t.Name, t.Error)
+		}
+	}
+	retenciones, err := retencionesActuales(ctx, cli, actuales)
+	if err != nil {
+		return err
+	}
+
+	crear, difs := compararTopics(deseados, actuales, retenciones)
+	var problemas []string
+	conDiferencias := make(map[string]bool)
+	for _, dif := range difs {
+		slog.Warn("admin: topic distinto al deseado", "topic", dif.topic, "diferencia", dif.detalle)
+		problemas = append(problemas, dif.topic+": "+dif.detalle)
+		conDiferencias[dif.topic] = true
+	}
+	for _, d := range deseados {
+		if _, ok := actuales[d.nombre]; ok && !conDiferencias[d.nombre] {
+			slog.Info("admin: topic ok", "topic", d.nombre, "particiones", d.particiones, "replicas", d.replicas, "retencion", d.retencion.String())
+		}
+	}
+
+	if len(crear) > 0 && *soloRevisar {
+		for _, d := range crear {
+			slog.Warn("admin: topic inexistente", "topic", d.nombre)
+			problemas = append(problemas, d.nombre+": no existe")
+		}
+	} else if len(crear) > 0 {
+		if err := crearTopics(ctx, cli, crear); err != nil {
+			return err
+		}
+	}
+
+	if *estricto && len(problemas) > 0 {
+		return fmt.Errorf("%d diferencias con la configuración deseada:\n  %s", len(problemas), strings.Join(problemas, "\n  "))
+	}
+	return nil
+}
+
+// topicDeseado es la configuración esperada de un topic.
+type topicDeseado struct {
+	nombre      string
+	particiones int
+	replicas    int
+	retencion   time.Duration // 0: la del broker, no se controla
+}
+
+// topicsDeseados arma la lista desde el entorno. Ventas y alertas comparten
+// particiones, réplicas y retención (TOPIC_*); la DLQ tiene las suyas.
+func topicsDeseados() []topicDeseado {
+	base := topicDeseado{
+		particiones: config.LeerEntero("TOPIC_PARTICIONES", 3),
+		replicas:    config.LeerEntero("TOPIC_REPLICAS", 1),
+		retencion:   config.LeerDuracion("TOPIC_RETENCION", 7*24*time.Hour),
+	}
+	ventas, alertas := base, base
+	ventas.nombre = getenv("KAFKA_TOPIC", "ventas")
+	alertas.nombre = getenv("ALERTAS_TOPIC", "ventas.alertas")
+	dlq := topicDeseado{
+		nombre:      getenv("KAFKA_DLQ_TOPIC", "ventas.dlq"),
+		particiones: config.LeerEntero("DLQ_PARTICIONES", 1),
+		replicas:    base.replicas,
+		retencion:   config.LeerDuracion("DLQ_RETENCION", 14*24*time.Hour),
+	}
+	return []topicDeseado{ventas, alertas, dlq}
+}
+
+func (d topicDeseado) retencionMs() string {
+	return strconv.FormatInt(d.retencion.Milliseconds(), 10)
+}
+
+// diferenciaTopic es una diferencia de un topic existente con lo deseado.
+type diferenciaTopic struct {
+	topic   string
+	detalle string
+}
+
+// compararTopics separa los topics deseados que no existen de las
+// diferencias de los que sí. actuales y retenciones vienen de la metadata y
+// del DescribeConfigs del broker.
+func compararTopics(deseados []topicDeseado, actuales map[string]kafka.Topic, retenciones map[string]string) (faltan []topicDeseado, difs []diferenciaTopic) {
+	for _, d := range deseados {
+		t, ok := actuales[d.nombre]
+		if !ok {
+			faltan = append(faltan, d)
+			continue
+		}
+		for _, dif := range d.diferencias(t, retenciones[d.nombre]) {
+			difs = append(difs, diferenciaTopic{d.nombre, dif})
+		}
+	}
+	return faltan, difs
+}
+
+// diferencias compara el topic con lo deseado; retencionMs es el
+// retention.ms efectivo del topic.
+func (d topicDeseado) diferencias(t kafka.Topic, retencionMs string) []string {
+	var difs []string
+	if n := len(t.Partitions); n != d.particiones {
+		difs = append(difs, fmt.Sprintf("particiones %d, deseadas %d", n, d.particiones))
+	}
+	// el factor de replicación es el de la partición con menos réplicas
+	replicas := -1
+	for _, p := range t.Partitions {
+		if replicas < 0 || len(p.Replicas) < replicas {
+			replicas = len(p.Replicas)
+		}
+	}
+	if replicas >= 0 && replicas != d.replicas {
+		difs = append(difs, fmt.Sprintf("réplicas %d, deseadas %d", replicas, d.replicas))
+	}
+	if d.retencion > 0 && retencionMs != d.retencionMs() {
+		difs = append(difs, fmt.Sprintf("retention.ms %s, deseado %s (%s)", retencionMs, d.retencionMs(), d.retencion))
+	}
+	return difs
+}
+
+// retencionesActuales devuelve el retention.ms efectivo de cada topic.
+func retencionesActuales(ctx context.Context, cli *kafka.Client, topics map[string]kafka.Topic) (map[string]string, error) {
+	out := make(map[string]string, len(topics))
+	if len(topics) == 0 {
+		return out, nil
+	}
+	req := &kafka.DescribeConfigsRequest{}
+	for nombre := range topics {
+		req.Resources = append(req.Resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: nombre,
+			ConfigNames:  []string{"retention.ms"},
+		})
+	}
+	resp, err := cli.DescribeConfigs(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("describe configs: %w", err)
+	}
+	for _, r := range resp.Resources {
+		if r.Error != nil {
+			return nil, fmt.Errorf("config de %s: %w", r.ResourceName, r.Error)
+		}
+		for _, e := range r.ConfigEntries {
+			if e.ConfigName == "retention.ms" {
+				out[r.ResourceName] = e.ConfigValue
+			}
+		}
+	}
+	return out, nil
+}
+
+// crearTopics crea los topics; si otra réplica lo creó en el medio
+// (TopicAlreadyExists) no es error.
+func crearTopics(ctx context.Context, cli *kafka.Client, ds []topicDeseado) error {
+	req := &kafka.CreateTopicsRequest{}
+	for _, d := range ds {
+		tc := kafka.TopicConfig{
+			Topic:             d.nombre,
+			NumPartitions:     d.particiones,
+			ReplicationFactor: d.replicas,
+		}
+		if d.retencion > 0 {
+			tc.ConfigEntries = []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: d.retencionMs()}}
+		}
+		req.Topics = append(req.Topics, tc)
+	}
+	resp, err := cli.CreateTopics(ctx, req)
+	if err != nil {
+		return fmt.Errorf("create topics: %w", err)
+	}
+	var errs []error
+	for _, d := range ds {
+		err := resp.Errors[d.nombre]
+		switch {
+		case err == nil:
+			slog.Info("admin: topic creado", "topic", d.nombre, "particiones", d.particiones, "replicas", d.replicas, "retencion", d.retencion.String())
+		case errors.Is(err, kafka.TopicAlreadyExists):
+			slog.Info("admin: topic ya creado por otro proceso", "topic", d.nombre)
+		default:
+			errs = append(errs, fmt.Errorf("creando %s: %w", d.nombre, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// topicPrueba arma la metadata de un topic con una partición por elemento
+// de replicas, cada una con esa cantidad de réplicas.
+func topicPrueba(nombre string, replicas ...int) kafka.Topic {
+	t := kafka.Topic{Name: nombre}
+	for i, n := range replicas {
+		p := kafka.Partition{Topic: nombre, ID: i}
+		for b := range n {
+			p.Replicas = append(p.Replicas, kafka.Broker{ID: b})
+		}
+		t.Partitions = append(t.Partitions, p)
+	}
+	return t
+}
+
+func TestCompararTopics(t *testing.T) {
+	semana := 7 * 24 * time.Hour
+	deseados := []topicDeseado{
+		{nombre: "ventas", particiones: 3, replicas: 3, retencion: semana},
+		{nombre: "ventas.dlq", particiones: 1, replicas: 3, retencion: 0},
+	}
+	semanaMs := "604800000"
+
+	casos := []struct {
+		nombre      string
+		actuales    []kafka.Topic
+		retenciones map[string]string
+		faltan      []string
+		difs        []diferenciaTopic
+	}{
+		{
+			nombre:      "iguales",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3), topicPrueba("ventas.dlq", 3)},
+			retenciones: map[string]string{"ventas": semanaMs, "ventas.dlq": "1"},
+		},
+		{
+			nombre: "no existe ninguno",
+			faltan: []string{"ventas", "ventas.dlq"},
+		},
+		{
+			nombre:      "falta uno",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3)},
+			retenciones: map[string]string{"ventas": semanaMs},
+			faltan:      []string{"ventas.dlq"},
+		},
+		{
+			nombre:      "más particiones",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3, 3), topicPrueba("ventas.dlq", 3)},
+			retenciones: map[string]string{"ventas": semanaMs},
+			difs:        []diferenciaTopic{{"ventas", "particiones 4, deseadas 3"}},
+		},
+		{
+			nombre:      "una partición con menos réplicas",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 2, 3), topicPrueba("ventas.dlq", 3)},
+			retenciones: map[string]string{"ventas": semanaMs},
+			difs:        []diferenciaTopic{{"ventas", "réplicas 2, deseadas 3"}},
+		},
+		{
+			nombre:      "retención distinta",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3), topicPrueba("ventas.dlq", 3)},
+			retenciones: map[string]string{"ventas": "86400000"},
+			difs:        []diferenciaTopic{{"ventas", "retention.ms 86400000, deseado 604800000 (168h0m0s)"}},
+		},
+		{
+			nombre:      "sin retention.ms informado",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3), topicPrueba("ventas.dlq", 3)},
+			retenciones: map[string]string{},
+			difs:        []diferenciaTopic{{"ventas", "retention.ms , deseado 604800000 (168h0m0s)"}},
+		},
+		{
+			nombre:      "sin particiones no compara réplicas",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 3, 3, 3), topicPrueba("ventas.dlq")},
+			retenciones: map[string]string{"ventas": semanaMs},
+			difs:        []diferenciaTopic{{"ventas.dlq", "particiones 0, deseadas 1"}},
+		},
+		{
+			nombre:      "varias diferencias en orden",
+			actuales:    []kafka.Topic{topicPrueba("ventas", 1, 1), topicPrueba("ventas.dlq", 1)},
+			retenciones: map[string]string{"ventas": "1000"},
+			difs: []diferenciaTopic{
+				{"ventas", "particiones 2, deseadas 3"},
+				{"ventas", "réplicas 1, deseadas 3"},
+				{"ventas", "retention.ms 1000, deseado 604800000 (168h0m0s)"},
+				{"ventas.dlq", "réplicas 1, deseadas 3"},
+			},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			actuales := make(map[string]kafka.Topic)
+			for _, tp := range c.actuales {
+				actuales[tp.Name] = tp
+			}
+			faltan, difs := compararTopics(deseados, actuales, c.retenciones)
+			var nombres []string
+			for _, d := range faltan {
+				nombres = append(nombres, d.nombre)
+			}
+			if !slices.Equal(nombres, c.faltan) {
+				t.Errorf("faltan %v, quiero %v", nombres, c.faltan)
+			}
+			if !slices.Equal(difs, c.difs) {
+				t.Errorf("diferencias %v, quiero %v", difs, c.difs)
+			}
+		})
+	}
+}
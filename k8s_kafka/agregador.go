@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// agregador aplica los mensajes de Kafka a los agregados en Valkey. Es el
+// Sink "valkey" (ver sink.go) y también lo usa el modo rebuild (con otro
+// esquema de keys).
+//
+// Para poder correr N réplicas en el mismo consumer group, cada réplica
+// acumula en memoria los mensajes de sus particiones y cada FLUSH_INTERVAL
+// (o al juntar FLUSH_MAX_MENSAJES) los aplica en una sola transacción MULTI
+// por partición, solo con operaciones conmutativas (HINCRBY, HINCRBYFLOAT,
+// ZINCRBY, PFADD y un script de max/min), junto con el último offset
+// aplicado de la partición. La transacción va bajo WATCH de ese offset: si en
+// un rebalance otra réplica ya aplicó esos mensajes, se descartan en vez de
+// contarse dos veces. Recién después se confirma el offset en Kafka.
+//
+// Los KPIs derivados (más/menos vendido, mejor producto por categoría,
+// promedios, percentiles) no son conmutativos: los recalcula solo el líder
+// (ver lider.go y materializador.go).
+type agregador struct {
+	rdb       redis.UniversalClient
+	ks        keys.Schema
+	topkK     int // 0 en TOPK_MODE=exact
+	epsilon   float64
+	delta     float64
+	pct       *percentilesPrecio
+	retencion *retencionEventos
+
+	mu    sync.Mutex
+	aprox map[int]*productosAproximados // por partición; vacío en TOPK_MODE=exact
+}
+
+func nuevoAgregador(rdb redis.UniversalClient, ks keys.Schema) (*agregador, error) {
+	a := &agregador{
+		rdb:   rdb,
+		ks:    ks,
+		aprox: make(map[int]*productosAproximados),
+	}
+
+	// TOPK_MODE=exact usa ZSETs exactos (crecen con cada productoId);
+	// TOPK_MODE=sketch usa Count-Min Sketch + heap con memoria acotada y no
+	// guarda la serie de precio por producto.
+	switch topkMode := getenv("TOPK_MODE", "exact"); topkMode {
+	case "exact":
+	case "sketch":
+		a.topkK = config.LeerEntero("TOPK_K", 10)
+		a.epsilon = config.LeerDecimal("TOPK_EPSILON", 0.0005)
+		a.delta = config.LeerDecimal("TOPK_DELTA", 0.001)
+		slog.Info("top-K aproximado", "k", a.topkK, "eps", a.epsilon, "delta", a.delta)
+	default:
+		return nil, fmt.Errorf("TOPK_MODE inválido: %q (exact|sketch)", topkMode)
+	}
+
+	ret, err := retencionDesdeEnv(ks)
+	if err != nil {
+		return nil, err
+	}
+	a.retencion = ret
+	slog.Info("eventos crudos", "retencion", ret.String())
+
+	a.pct, err = nuevoPercentilesPrecio(ks, config.LeerDecimal("PERCENTILES_ALPHA", 0.01))
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *agregador) nombre() string { return "valkey" }
+
+func (a *agregador) cerrar() error { return nil }
+
+// aproxParticion devuelve el top-K local de la partición (nil en exact).
+// Cada partición la escribe un solo worker, el lock es solo para el map.
+func (a *agregador) aproxParticion(id int) *productosAproximados {
+	if a.topkK == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.aprox[id]
+	if !ok {
+		p = nuevoProductosAproximados(a.ks, a.topkK, a.epsilon, a.delta, -1)
+		a.aprox[id] = p
+	}
+	return p
+}
+
+// reiniciarAprox descarta el top-K local de la partición y arma uno vacío
+// que se sembrará desde Valkey (ver productosAproximados).
+func (a *agregador) reiniciarAprox(id int, guardado int64) *productosAproximados {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := nuevoProductosAproximados(a.ks, a.topkK, a.epsilon, a.delta, guardado)
+	a.aprox[id] = p
+	return p
+}
+
+const reintentosFlush = 5
+
+// escribir aplica el lote de la partición en una transacción junto con su
+// offset (ver Sink).
+func (a *agregador) escribir(ctx context.Context, l lote) error {
+	ultimo := l.ultimo()
+	offKey := a.ks.OffsetAplicado(l.topic, l.particion)
+	aprox := a.aproxParticion(l.particion)
+
+	aplicar := func(tx *redis.Tx) error {
+		guardado := int64(-1)
+		s, err := tx.Get(ctx, offKey).Result()
+		switch {
+		case err == nil:
+			if guardado, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return fmt.Errorf("offset aplicado inválido en %s: %w", offKey, err)
+			}
+		case err != redis.Nil:
+			return err
+		}
+
+		var nuevos []ventaPendiente
+		for _, vp := range l.mensajes {
+			if vp.m.Offset > guardado {
+				nuevos = append(nuevos, vp)
+			}
+		}
+		if len(nuevos) == 0 {
+			return nil
+		}
+		if descartados := len(l.mensajes) - len(nuevos); descartados > 0 {
+			slog.Info("mensajes ya aplicados por otra réplica", "part", l.particion, "descartados", descartados, "offset_hasta", guardado)
+		}
+		if aprox != nil {
+			if !aprox.alDia(guardado) {
+				slog.Info("top-K aproximado desactualizado, se siembra desde Valkey", "part", l.particion, "offset_sketch", aprox.hasta, "offset_guardado", guardado)
+				aprox = a.reiniciarAprox(l.particion, guardado)
+			}
+			var ventas []Venta
+			for _, vp := range nuevos {
+				if vp.ok {
+					ventas = append(ventas, vp.v)
+				}
+			}
+			if err := aprox.sembrar(ctx, tx, ventas); err != nil {
+				return err
+			}
+			for _, vp := range nuevos {
+				if vp.ok {
+					aprox.observar(vp.m.Offset, vp.v)
+				}
+			}
+			aprox.avanzar(ultimo.Offset)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			a.encolar(ctx, pipe, aprox, nuevos)
+			pipe.Set(ctx, offKey, ultimo.Offset, 0)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for i := 0; i < reintentosFlush; i++ {
+		err = a.rdb.Watch(ctx, aplicar, offKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("flush partición %d: %w", l.particion, err)
+	}
+	if aprox != nil {
+		aprox.confirmar()
+	}
+	return nil
+}
+
+type claveProducto struct{ categoria, productoID string }
+
+// totalesTenant acumula lo de un tenant dentro de un lote: reportes,
+// unidades, suma de precios y unidades por categoría.
+type totalesTenant struct {
+	reportes, unidades int64
+	sumPrecio          float64
+	porCategoria       map[string]int64
+}
+
+// encolar encola en la transacción todos los incrementos del lote.
+func (a *agregador) encolar(ctx context.Context, pipe redis.Pipeliner, aprox *productosAproximados, lote []ventaPendiente) {
+	ks := a.ks
+
+	reportes := make(map[string]int64)
+	sumPrecio := make(map[string]float64)
+	maxPrecio := make(map[string]float64)
+	minPrecio := make(map[string]float64)
+	prodQty := make(map[string]float64)
+	prodCatQty := make(map[claveProducto]float64)
+	sumProd := make(map[claveProducto]float64)
+	cntProd := make(map[claveProducto]int64)
+	sumDescuento := make(map[string]float64)
+	cntDescuento := make(map[string]int64)
+	porTenant := make(map[string]*totalesTenant)
+	var ventas []Venta
+
+	for _, vp := range lote {
+		// 1) Guardar evento crudo (según RAW_EVENTS_MODE)
+		if err := a.retencion.guardar(ctx, pipe, vp.m); err != nil {
+			slog.Error("Valkey evento crudo", "err", err)
+		}
+		if !vp.ok {
+			continue
+		}
+		v := vp.v
+		ventas = append(ventas, v)
+
+		reportes[v.Categoria]++
+		sumPrecio[v.Categoria] += v.Precio
+		for _, c := range []string{v.Categoria, categoriaGlobal} {
+			if cur, ok := maxPrecio[c]; !ok || v.Precio > cur {
+				maxPrecio[c] = v.Precio
+			}
+			if cur, ok := minPrecio[c]; !ok || v.Precio < cur {
+				minPrecio[c] = v.Precio
+			}
+		}
+
+		cp := claveProducto{v.Categoria, v.ProductoID}
+		if v.CantidadVendida > 0 {
+			prodQty[v.ProductoID] += float64(v.CantidadVendida)
+			prodCatQty[cp] += float64(v.CantidadVendida)
+		}
+		sumProd[cp] += v.Precio
+		cntProd[cp]++
+		if v.PrecioLista > 0 {
+			sumDescuento[v.Categoria] += v.DescuentoPct
+			cntDescuento[v.Categoria]++
+		}
+		if v.Tenant != "" {
+			t := porTenant[v.Tenant]
+			if t == nil {
+				t = &totalesTenant{porCategoria: make(map[string]int64)}
+				porTenant[v.Tenant] = t
+			}
+			t.reportes++
+			t.unidades += int64(v.CantidadVendida)
+			t.sumPrecio += v.Precio
+			t.porCategoria[v.Categoria]++
+		}
+
+		// Serie de precio por producto, con la hora del mensaje en Kafka
+		// para que un rebuild reproduzca la serie. Son dos keys por
+		// producto: en TOPK_MODE=sketch no se escriben, para que la memoria
+		// no crezca con la cantidad de productos distintos.
+		if aprox == nil {
+			cuando := vp.m.Time
+			if cuando.IsZero() {
+				cuando = time.Now()
+			}
+			tsZKey := ks.SeriePrecio(v.Categoria, v.ProductoID)
+			pipe.ZAdd(ctx, tsZKey, redis.Z{Score: float64(cuando.Unix()), Member: fmt.Sprintf("%.2f", v.Precio)})
+			pipe.HSet(ctx, ks.SeriePrecioHash(v.Categoria, v.ProductoID), strconv.FormatInt(cuando.Unix(), 10), fmt.Sprintf("%.2f", v.Precio))
+			pipe.ZRemRangeByRank(ctx, tsZKey, 0, -1001)
+		}
+	}
+
+	// 2) Total de reportes y suma/conteo de precio y de descuento por categoría
+	for c, n := range reportes {
+		pipe.HIncrBy(ctx, ks.ReportesPorCategoria(), c, n)
+		pipe.HIncrBy(ctx, ks.Conteo(), c, n)
+		pipe.HIncrByFloat(ctx, ks.SumPrecio(), c, sumPrecio[c])
+	}
+	for c, n := range cntDescuento {
+		pipe.HIncrBy(ctx, ks.DescuentoConteo(), c, n)
+		pipe.HIncrByFloat(ctx, ks.DescuentoSuma(), c, sumDescuento[c])
+	}
+	for tenant, t := range porTenant {
+		pipe.HIncrBy(ctx, ks.ReportesPorTenant(), tenant, t.reportes)
+		pipe.HIncrBy(ctx, ks.UnidadesPorTenant(), tenant, t.unidades)
+		pipe.HIncrByFloat(ctx, ks.SumPrecioPorTenant(), tenant, t.sumPrecio)
+		for c, n := range t.porCategoria {
+			pipe.HIncrBy(ctx, ks.ReportesTenantPorCategoria(tenant), c, n)
+		}
+	}
+
+	// 3) Precio máximo y mínimo global y por categoría (KPIs)
+	for c := range maxPrecio {
+		if c == categoriaGlobal {
+			actualizarMaxMin(ctx, pipe, ks.PrecioMax(), ks.PrecioMin(), "", maxPrecio[c], minPrecio[c])
+		} else {
+			actualizarMaxMin(ctx, pipe, ks.PrecioMaxPorCategoria(), ks.PrecioMinPorCategoria(), c, maxPrecio[c], minPrecio[c])
+		}
+	}
+
+	// 4) Percentiles (buckets del DDSketch)
+	a.pct.escribirDeltas(ctx, pipe, ventas)
+
+	// 5) Cantidad vendida por producto
+	if aprox != nil {
+		aprox.escribir(ctx, pipe, ventas)
+		return
+	}
+	for id, q := range prodQty {
+		pipe.ZIncrBy(ctx, ks.ProductosVendidos(), q, id)
+	}
+	for cp, q := range prodCatQty {
+		pipe.ZIncrBy(ctx, ks.ProductosPorCategoria(cp.categoria), q, cp.productoID)
+	}
+	for cp, s := range sumProd {
+		pipe.HIncrByFloat(ctx, ks.SumPrecioPorProducto(cp.categoria), cp.productoID, s)
+		pipe.HIncrBy(ctx, ks.CountPorProducto(cp.categoria), cp.productoID, cntProd[cp])
+	}
+}
+
+// scriptMaxMin actualiza max/min solo si el candidato lo mejora. Es
+// conmutativo: el resultado no depende del orden de las réplicas.
+// ARGV[1] vacío = keys STRING; si no, campo de HASH.
+var scriptMaxMin = redis.NewScript(`
+local function leer(k, f)
+  if f == '' then return redis.call('GET', k) end
+  return redis.call('HGET', k, f)
+end
+local function escribir(k, f, v)
+  if f == '' then return redis.call('SET', k, v) end
+  return redis.call('HSET', k, f, v)
+end
+local cur = tonumber(leer(KEYS[1], ARGV[1]))
+if not cur or tonumber(ARGV[2]) > cur then escribir(KEYS[1], ARGV[1], ARGV[2]) end
+cur = tonumber(leer(KEYS[2], ARGV[1]))
+if not cur or tonumber(ARGV[3]) < cur then escribir(KEYS[2], ARGV[1], ARGV[3]) end
+return 1
+`)
+
+func actualizarMaxMin(ctx context.Context, pipe redis.Pipeliner, maxKey, minKey, campo string, max, min float64) {
+	// EVAL (no EVALSHA): dentro de MULTI un NOSCRIPT no aborta el resto
+	scriptMaxMin.Eval(ctx, pipe, []string{maxKey, minKey}, campo, fmt.Sprintf("%.2f", max), fmt.Sprintf("%.2f", min))
+}
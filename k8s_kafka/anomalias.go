@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// Modo anomalias: consumer aparte (grupo ANOMALIAS_GROUP, por defecto
+// <KAFKA_GROUP>-anomalias) que evalúa reglas sobre cada venta y publica las
+// sospechosas en el topic ALERTAS_TOPIC (por defecto ventas.alertas) y en la
+// LIST keys.Alertas de Valkey (últimas ALERTAS_MAX) para Grafana.
+//
+//	k8s_kafka anomalias
+//
+// Reglas (ANOMALIAS_REGLAS, archivo JSON; sin archivo valen los defaults):
+//
+//	precio     precio fuera de media ± k·desvío de la categoría, con media y
+//	           varianza móviles (EWMA con factor alpha)
+//	cantidad   cantidad mayor que max, o fuera de media ± k·desvío
+//	velocidad  más de max_ventas de un mismo producto dentro de ventana
+//
+// Las estadísticas se aprenden en memoria, por réplica, solo con ventas no
+// marcadas, y recién se evalúan después de min_muestras ventas de la
+// categoría. Las alertas son at-least-once: si falla Valkey después de
+// escribir en el topic, el reintento las publica de nuevo.
+func anomalias(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema, brokers []string, topic, group string) error {
+	reglas, err := cargarReglas(getenv("ANOMALIAS_REGLAS", ""))
+	if err != nil {
+		return err
+	}
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        getenv("ALERTAS_TOPIC", "ventas.alertas"),
+		Transport:    segKafka.Transport(timeoutKafka()),
+		Balancer:     kafka.Murmur2Balancer{}, // key = productoId, como las ventas y el DefaultPartitioner de Java
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    config.LeerEntero("KAFKA_BATCH_SIZE", 100),
+		BatchTimeout: config.LeerDuracion("KAFKA_BATCH_TIMEOUT", time.Second),
+	}
+	defer w.Close()
+
+	det := &detectorAnomalias{
+		reglas:     reglas,
+		rdb:        rdb,
+		ks:         ks,
+		w:          w,
+		max:        int64(config.LeerEntero("ALERTAS_MAX", 1000)),
+		categorias: make(map[string]*estadisticaCategoria),
+		productos:  make(map[string][]time.Time),
+		evaluado:   make(map[int]int64),
+		pendientes: make(map[int][]alerta),
+	}
+
+	grupo := getenv("ANOMALIAS_GROUP", group+"-anomalias")
+	reader := kafka.NewReader(configLector(brokers, topic, grupo))
+	defer reader.Close()
+	slog.Info("anomalías", "grupo", grupo, "alertas", w.Topic, "reglas", reglas)
+
+	go func() {
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ahora := <-t.C:
+				det.limpiar(ahora)
+			}
+		}
+	}()
+
+	pool := nuevoPoolParticiones(ctx, det, func(ctx context.Context, m kafka.Message) error {
+		return reader.CommitMessages(ctx, m)
+	})
+	pool.logOK = false
+	return consumir(ctx, reader, pool)
+}
+
+// ===== Reglas =====
+
+type reglaDesvio struct {
+	K           float64 `json:"k"`            // 0 desactiva
+	Alpha       float64 `json:"alpha"`        // peso de cada venta nueva en la media móvil
+	MinMuestras int     `json:"min_muestras"` // ventas antes de evaluar
+}
+
+type reglasAnomalias struct {
+	Precio   reglaDesvio `json:"precio"`
+	Cantidad struct {
+		reglaDesvio
+		Max int `json:"max"` // 0 desactiva
+	} `json:"cantidad"`
+	Velocidad struct {
+		MaxVentas int      `json:"max_ventas"` // 0 desactiva
+		Ventana   duracion `json:"ventana"`
+	} `json:"velocidad"`
+}
+
+// duracion acepta "10s", "1m", etc. en el JSON.
+type duracion time.Duration
+
+func (d *duracion) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duracion(v)
+	return nil
+}
+
+func reglasPorDefecto() reglasAnomalias {
+	var r reglasAnomalias
+	r.Precio = reglaDesvio{K: 4, Alpha: 0.01, MinMuestras: 100}
+	r.Cantidad.reglaDesvio = reglaDesvio{K: 6, Alpha: 0.01, MinMuestras: 100}
+	r.Cantidad.Max = 1000
+	r.Velocidad.MaxVentas = 50
+	r.Velocidad.Ventana = duracion(10 * time.Second)
+	return r
+}
+
+// cargarReglas lee el archivo sobre los defaults: lo que no esté en el JSON
+// queda como está.
+func cargarReglas(path string) (reglasAnomalias, error) {
+	r := reglasPorDefecto()
+	if path == "" {
+		return r, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return r, fmt.Errorf("ANOMALIAS_REGLAS: %w", err)
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("ANOMALIAS_REGLAS %s: %w", path, err)
+	}
+	for _, d := range []reglaDesvio{r.Precio, r.Cantidad.reglaDesvio} {
+		if d.K > 0 && (d.Alpha <= 0 || d.Alpha >= 1) {
+			return r, fmt.Errorf("ANOMALIAS_REGLAS %s: alpha debe estar entre 0 y 1", path)
+		}
+	}
+	if r.Velocidad.MaxVentas > 0 && r.Velocidad.Ventana <= 0 {
+		return r, fmt.Errorf("ANOMALIAS_REGLAS %s: velocidad.ventana debe ser > 0", path)
+	}
+	return r, nil
+}
+
+func (r reglasAnomalias) String() string {
+	return fmt.Sprintf("precio(k=%g) cantidad(max=%d k=%g) velocidad(%d/%s)",
+		r.Precio.K, r.Cantidad.Max, r.Cantidad.K, r.Velocidad.MaxVentas, time.Duration(r.Velocidad.Ventana))
+}
+
+// ===== Estadísticas móviles =====
+
+// ewma lleva media y varianza exponencialmente ponderadas.
+type ewma struct {
+	n               int
+	media, varianza float64
+}
+
+func (e *ewma) agregar(x, alpha float64) {
+	e.n++
+	if e.n == 1 {
+		e.media = x
+		return
+	}
+	d := x - e.media
+	e.media += alpha * d
+	e.varianza = (1 - alpha) * (e.varianza + alpha*d*d)
+}
+
+// fuera indica si x está fuera de media ± k·desvío.
+func (e *ewma) fuera(x float64, r reglaDesvio) bool {
+	if r.K <= 0 || e.n < r.MinMuestras {
+		return false
+	}
+	return math.Abs(x-e.media) > r.K*math.Sqrt(e.varianza)
+}
+
+type estadisticaCategoria struct {
+	precio, cantidad ewma
+}
+
+// ===== Detector =====
+
+type alerta struct {
+	Regla     string `json:"regla"`
+	Detalle   string `json:"detalle"`
+	Venta     Venta  `json:"venta"`
+	Particion int    `json:"particion"`
+	Offset    int64  `json:"offset"`
+	Timestamp int64  `json:"timestampUnixMs"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// detectorAnomalias es un Sink: el pool de workers le pasa los lotes por
+// partición y confirma el offset recién cuando las alertas del lote se
+// publicaron.
+type detectorAnomalias struct {
+	reglas reglasAnomalias
+	rdb    redis.UniversalClient
+	ks     keys.Schema
+	w      *kafka.Writer
+	max    int64
+
+	mu         sync.Mutex
+	categorias map[string]*estadisticaCategoria
+	productos  map[string][]time.Time // ventas recientes por producto
+	evaluado   map[int]int64          // partición -> último offset evaluado
+	pendientes map[int][]alerta       // partición -> alertas sin publicar
+}
+
+func (d *detectorAnomalias) nombre() string { return "anomalias" }
+
+func (d *detectorAnomalias) cerrar() error { return nil }
+
+func (d *detectorAnomalias) escribir(ctx context.Context, l lote) error {
+	d.mu.Lock()
+	ultimo, ok := d.evaluado[l.particion]
+	if !ok {
+		ultimo = -1
+	}
+	// en un reintento los mensajes ya evaluados no vuelven a sumar a las
+	// estadísticas; sus alertas siguen en pendientes
+	for _, vp := range l.desde(ultimo).mensajes {
+		if vp.ok {
+			d.pendientes[l.particion] = append(d.pendientes[l.particion], d.evaluar(vp, l.particion)...)
+		}
+	}
+	d.evaluado[l.particion] = l.ultimo().Offset
+	alertas := d.pendientes[l.particion]
+	d.mu.Unlock()
+
+	if len(alertas) == 0 {
+		return nil
+	}
+	if err := d.publicar(ctx, alertas); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.pendientes[l.particion] = nil
+	d.mu.Unlock()
+	return nil
+}
+
+// evaluar aplica las reglas a una venta (con d.mu tomado).
+func (d *detectorAnomalias) evaluar(vp ventaPendiente, particion int) []alerta {
+	v := vp.v
+	cuando := horaVenta(vp)
+	est, ok := d.categorias[v.Categoria]
+	if !ok {
+		est = &estadisticaCategoria{}
+		d.categorias[v.Categoria] = est
+	}
+
+	var out []alerta
+	marcar := func(regla, detalle string) {
+		out = append(out, alerta{
+			Regla:     regla,
+			Detalle:   detalle,
+			Venta:     v,
+			Particion: particion,
+			Offset:    vp.m.Offset,
+			Timestamp: cuando.UnixMilli(),
+			RequestID: requestID(vp.m),
+		})
+	}
+
+	r := d.reglas
+	if est.precio.fuera(v.Precio, r.Precio) {
+		marcar("precio", fmt.Sprintf("precio %.2f fuera de %.2f ± %g·%.2f en %s",
+			v.Precio, est.precio.media, r.Precio.K, math.Sqrt(est.precio.varianza), v.Categoria))
+	}
+	cant := float64(v.CantidadVendida)
+	switch {
+	case r.Cantidad.Max > 0 && v.CantidadVendida > r.Cantidad.Max:
+		marcar("cantidad", fmt.Sprintf("cantidad %d mayor que %d", v.CantidadVendida, r.Cantidad.Max))
+	case est.cantidad.fuera(cant, r.Cantidad.reglaDesvio):
+		marcar("cantidad", fmt.Sprintf("cantidad %d fuera de %.1f ± %g·%.1f en %s",
+			v.CantidadVendida, est.cantidad.media, r.Cantidad.K, math.Sqrt(est.cantidad.varianza), v.Categoria))
+	}
+	if r.Velocidad.MaxVentas > 0 {
+		ventana := time.Duration(r.Velocidad.Ventana)
+		recientes := d.productos[v.ProductoID]
+		i := 0
+		for i < len(recientes) && cuando.Sub(recientes[i]) > ventana {
+			i++
+		}
+		recientes = append(recientes[i:], cuando)
+		d.productos[v.ProductoID] = recientes
+		if len(recientes) > r.Velocidad.MaxVentas {
+			marcar("velocidad", fmt.Sprintf("%d ventas de %s en %s", len(recientes), v.ProductoID, ventana))
+			// se descarta la ventana para no alertar en cada venta siguiente
+			d.productos[v.ProductoID] = nil
+		}
+	}
+
+	// solo las ventas normales alimentan las estadísticas
+	if len(out) == 0 {
+		est.precio.agregar(v.Precio, r.Precio.Alpha)
+		est.cantidad.agregar(cant, r.Cantidad.Alpha)
+	}
+	for _, a := range out {
+		slog.Warn("ALERTA", "rid", a.RequestID, "regla", a.Regla, "detalle", a.Detalle, "part", a.Particion, "off", a.Offset)
+	}
+	return out
+}
+
+// publicar manda las alertas al topic y a Valkey.
+func (d *detectorAnomalias) publicar(ctx context.Context, alertas []alerta) error {
+	msgs := make([]kafka.Message, 0, len(alertas))
+	jsons := make([]any, 0, len(alertas))
+	for _, a := range alertas {
+		b, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(a.Venta.ProductoID), Value: b})
+		jsons = append(jsons, string(b))
+	}
+	if err := d.w.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("topic %s: %w", d.w.Topic, err)
+	}
+
+	_, err := d.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, d.ks.Alertas(), jsons...)
+		pipe.LTrim(ctx, d.ks.Alertas(), 0, d.max-1)
+		for _, a := range alertas {
+			pipe.HIncrBy(ctx, d.ks.AlertasPorRegla(), a.Regla, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("valkey alertas: %w", err)
+	}
+	return nil
+}
+
+// limpiar olvida los productos sin ventas dentro de la ventana, para acotar
+// la memoria.
+func (d *detectorAnomalias) limpiar(ahora time.Time) {
+	ventana := time.Duration(d.reglas.Velocidad.Ventana)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, ts := range d.productos {
+		if len(ts) == 0 || ahora.Sub(ts[len(ts)-1]) > ventana {
+			delete(d.productos, id)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestCargarReglas(t *testing.T) {
+	def := reglasPorDefecto()
+	casos := []struct {
+		nombre string
+		json   string // "-" no crea archivo
+		error  string
+		probar func(t *testing.T, r reglasAnomalias)
+	}{
+		{nombre: "sin archivo", json: "-", probar: func(t *testing.T, r reglasAnomalias) {
+			if r != def {
+				t.Errorf("reglas %v, quiero los defaults %v", r, def)
+			}
+		}},
+		{nombre: "parcial pisa solo lo indicado", json: `{"precio": {"k": 3}, "velocidad": {"ventana": "1m"}}`, probar: func(t *testing.T, r reglasAnomalias) {
+			if r.Precio.K != 3 || r.Precio.Alpha != def.Precio.Alpha || r.Precio.MinMuestras != def.Precio.MinMuestras {
+				t.Errorf("precio %+v", r.Precio)
+			}
+			if time.Duration(r.Velocidad.Ventana) != time.Minute || r.Velocidad.MaxVentas != def.Velocidad.MaxVentas {
+				t.Errorf("velocidad %+v", r.Velocidad)
+			}
+			if r.Cantidad != def.Cantidad {
+				t.Errorf("cantidad %+v", r.Cantidad)
+			}
+		}},
+		{nombre: "regla desactivada sin alpha", json: `{"cantidad": {"k": 0, "alpha": 0, "max": 0}}`},
+		{nombre: "velocidad desactivada sin ventana", json: `{"velocidad": {"max_ventas": 0, "ventana": "0s"}}`},
+		{nombre: "JSON inválido", json: `{"precio": `, error: "ANOMALIAS_REGLAS"},
+		{nombre: "ventana sin unidad", json: `{"velocidad": {"ventana": "10"}}`, error: "missing unit"},
+		{nombre: "ventana no string", json: `{"velocidad": {"ventana": 10}}`, error: "ANOMALIAS_REGLAS"},
+		{nombre: "alpha cero", json: `{"precio": {"alpha": 0}}`, error: "alpha"},
+		{nombre: "alpha uno", json: `{"cantidad": {"alpha": 1}}`, error: "alpha"},
+		{nombre: "alpha negativo", json: `{"precio": {"alpha": -0.1}}`, error: "alpha"},
+		{nombre: "ventana cero", json: `{"velocidad": {"ventana": "0s"}}`, error: "velocidad.ventana"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			path := ""
+			if c.json != "-" {
+				path = filepath.Join(t.TempDir(), "reglas.json")
+				if err := os.WriteFile(path, []byte(c.json), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r, err := cargarReglas(path)
+			if c.error != "" {
+				if err == nil || !strings.Contains(err.Error(), c.error) {
+					t.Fatalf("err %v, esperaba %q", err, c.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.probar != nil {
+				c.probar(t, r)
+			}
+		})
+	}
+
+	if _, err := cargarReglas(filepath.Join(t.TempDir(), "no-existe.json")); err == nil {
+		t.Error("archivo inexistente aceptado")
+	}
+}
+
+func TestEwmaFuera(t *testing.T) {
+	// media 100, desvío 5
+	e := ewma{n: 50, media: 100, varianza: 25}
+	regla := reglaDesvio{K: 2, Alpha: 0.1, MinMuestras: 50}
+	casos := []struct {
+		nombre string
+		x      float64
+		regla  reglaDesvio
+		fuera  bool
+	}{
+		{"en la media", 100, regla, false},
+		{"justo en media + k·desvío", 110, regla, false},
+		{"justo en media - k·desvío", 90, regla, false},
+		{"apenas arriba", 110.01, regla, true},
+		{"apenas abajo", 89.99, regla, true},
+		{"muy lejos con k mayor", 115, reglaDesvio{K: 4, MinMuestras: 50}, false},
+		{"regla desactivada", 1000, reglaDesvio{K: 0}, false},
+		{"sin muestras suficientes", 1000, reglaDesvio{K: 2, MinMuestras: 51}, false},
+		{"con las muestras justas", 1000, reglaDesvio{K: 2, MinMuestras: 50}, true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := e.fuera(c.x, c.regla); got != c.fuera {
+				t.Fatalf("fuera(%v) = %v, quiero %v", c.x, got, c.fuera)
+			}
+		})
+	}
+}
+
+func TestEwmaAgregar(t *testing.T) {
+	var e ewma
+	e.agregar(50, 0.5)
+	if e.n != 1 || e.media != 50 || e.varianza != 0 {
+		t.Fatalf("primera muestra: %+v", e)
+	}
+	e.agregar(50, 0.5)
+	if e.media != 50 || e.varianza != 0 {
+		t.Fatalf("serie constante: %+v", e)
+	}
+	// d = 10: media += 0.5·10, varianza = 0.5·(0 + 0.5·100)
+	e.agregar(60, 0.5)
+	if e.media != 55 || e.varianza != 25 {
+		t.Fatalf("después de 60: %+v, quiero media 55 varianza 25", e)
+	}
+}
+
+func nuevoDetectorPrueba(r reglasAnomalias) *detectorAnomalias {
+	return &detectorAnomalias{
+		reglas:     r,
+		categorias: make(map[string]*estadisticaCategoria),
+		productos:  make(map[string][]time.Time),
+		evaluado:   make(map[int]int64),
+		pendientes: make(map[int][]alerta),
+	}
+}
+
+// ventaAnomalia arma una venta de Ropa en t0 + segundos.
+func ventaAnomalia(offset int64, producto string, precio float64, cantidad int, segundos float64) ventaPendiente {
+	t0 := time.Unix(1_700_000_000, 0)
+	ts := t0.Add(time.Duration(segundos * float64(time.Second)))
+	return ventaPendiente{
+		m:  kafka.Message{Offset: offset},
+		v:  Venta{Categoria: "Ropa", ProductoID: producto, Precio: precio, CantidadVendida: cantidad, TimestampUnixMs: ts.UnixMilli()},
+		ok: true,
+	}
+}
+
+func reglas(f func(r *reglasAnomalias)) reglasAnomalias {
+	var r reglasAnomalias // todo desactivado
+	f(&r)
+	return r
+}
+
+func TestEvaluar(t *testing.T) {
+	precio := reglas(func(r *reglasAnomalias) { r.Precio = reglaDesvio{K: 3, Alpha: 0.1, MinMuestras: 10} })
+	cantidad := reglas(func(r *reglasAnomalias) {
+		r.Cantidad.reglaDesvio = reglaDesvio{K: 3, Alpha: 0.1, MinMuestras: 10}
+		r.Cantidad.Max = 20
+	})
+	velocidad := reglas(func(r *reglasAnomalias) {
+		r.Velocidad.MaxVentas = 3
+		r.Velocidad.Ventana = duracion(10 * time.Second)
+	})
+
+	type venta struct {
+		producto string
+		precio   float64
+		cantidad int
+		segundos float64
+	}
+	// normales alterna precios 95/105 y cantidades 1/3 de distintos
+	// productos, espaciados para no tocar la regla de velocidad
+	normales := func(n int) []venta {
+		var out []venta
+		for i := range n {
+			out = append(out, venta{string(rune('a' + i)), float64(95 + 10*(i%2)), 1 + 2*(i%2), float64(i)})
+		}
+		return out
+	}
+
+	casos := []struct {
+		nombre  string
+		reglas  reglasAnomalias
+		previas []venta
+		venta   venta
+		regla   string // vacío: sin alerta
+	}{
+		{"precio normal", precio, normales(10), venta{"x", 104, 1, 100}, ""},
+		{"precio fuera", precio, normales(10), venta{"x", 130, 1, 100}, "precio"},
+		{"precio bajo fuera", precio, normales(10), venta{"x", 70, 1, 100}, "precio"},
+		{"precio fuera durante el calentamiento", precio, normales(9), venta{"x", 1000, 1, 100}, ""},
+		{"cantidad fuera del desvío", cantidad, normales(10), venta{"x", 100, 12, 100}, "cantidad"},
+		{"cantidad sobre el máximo sin muestras", cantidad, nil, venta{"x", 100, 21, 100}, "cantidad"},
+		{"cantidad en el máximo", cantidad, nil, venta{"x", 100, 20, 100}, ""},
+		{"velocidad dentro del límite", velocidad, []venta{{"p", 1, 1, 0}, {"p", 1, 1, 5}}, venta{"p", 1, 1, 9}, ""},
+		{"velocidad excedida", velocidad, []venta{{"p", 1, 1, 0}, {"p", 1, 1, 5}, {"p", 1, 1, 9}}, venta{"p", 1, 1, 10}, "velocidad"},
+		{"velocidad con ventas viejas fuera de la ventana", velocidad, []venta{{"p", 1, 1, 0}, {"p", 1, 1, 5}, {"p", 1, 1, 9}}, venta{"p", 1, 1, 10.5}, ""},
+		{"velocidad de otro producto", velocidad, []venta{{"p", 1, 1, 0}, {"p", 1, 1, 1}, {"p", 1, 1, 2}}, venta{"q", 1, 1, 3}, ""},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			d := nuevoDetectorPrueba(c.reglas)
+			for i, v := range c.previas {
+				if a := d.evaluar(ventaAnomalia(int64(i), v.producto, v.precio, v.cantidad, v.segundos), 0); len(a) > 0 {
+					t.Fatalf("venta previa %d marcada: %+v", i, a)
+				}
+			}
+			v := c.venta
+			alertas := d.evaluar(ventaAnomalia(99, v.producto, v.precio, v.cantidad, v.segundos), 0)
+			switch {
+			case c.regla == "" && len(alertas) > 0:
+				t.Fatalf("alerta inesperada: %+v", alertas)
+			case c.regla != "" && (len(alertas) != 1 || alertas[0].Regla != c.regla || alertas[0].Offset != 99):
+				t.Fatalf("alertas %+v, quiero una de %s", alertas, c.regla)
+			}
+		})
+	}
+}
+
+// Una venta marcada no entra en las estadísticas, así que una ráfaga de
+// precios raros no corre la media hasta volverse normal.
+func TestEvaluarNoAprendeDeLasMarcadas(t *testing.T) {
+	d := nuevoDetectorPrueba(reglas(func(r *reglasAnomalias) { r.Precio = reglaDesvio{K: 3, Alpha: 0.5, MinMuestras: 4} }))
+	for i := range 4 {
+		d.evaluar(ventaAnomalia(int64(i), "a", float64(99+2*(i%2)), 1, float64(i)), 0)
+	}
+	antes := d.categorias["Ropa"].precio
+	for i := range 20 {
+		if a := d.evaluar(ventaAnomalia(int64(10+i), "a", 500, 1, float64(10+i)), 0); len(a) != 1 {
+			t.Fatalf("venta rara %d sin alerta", i)
+		}
+	}
+	if d.categorias["Ropa"].precio != antes {
+		t.Fatalf("las ventas marcadas cambiaron la media: %+v -> %+v", antes, d.categorias["Ropa"].precio)
+	}
+}
+
+// Después de alertar por velocidad la ventana del producto se descarta:
+// no se alerta en cada venta siguiente de la misma ráfaga.
+func TestEvaluarVelocidadReinicia(t *testing.T) {
+	d := nuevoDetectorPrueba(reglas(func(r *reglasAnomalias) {
+		r.Velocidad.MaxVentas = 2
+		r.Velocidad.Ventana = duracion(time.Minute)
+	}))
+	var marcadas []int64
+	for i := range 9 {
+		for _, a := range d.evaluar(ventaAnomalia(int64(i), "p", 1, 1, float64(i)), 0) {
+			marcadas = append(marcadas, a.Offset)
+		}
+	}
+	if want := []int64{2, 5, 8}; len(marcadas) != len(want) || marcadas[0] != 2 || marcadas[1] != 5 || marcadas[2] != 8 {
+		t.Fatalf("alertas en los offsets %v, quiero %v", marcadas, want)
+	}
+
+	// limpiar olvida los productos sin ventas dentro de la ventana
+	d.evaluar(ventaAnomalia(20, "q", 1, 1, 20), 0)
+	d.limpiar(time.Unix(1_700_000_000, 0).Add(75 * time.Second))
+	if _, ok := d.productos["p"]; ok {
+		t.Error("limpiar no olvidó p")
+	}
+	if _, ok := d.productos["q"]; !ok {
+		t.Error("limpiar olvidó q, con ventas dentro de la ventana")
+	}
+}
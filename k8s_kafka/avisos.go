@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// Modo avisos: evalúa reglas de umbral sobre los agregados y métricas
+// internas y avisa por webhooks HTTP, sin depender de que alguien mire
+// Grafana.
+//
+//	k8s_kafka avisos
+//
+// Config en AVISOS_CONFIG (JSON):
+//
+//	{
+//	  "intervalo": "15s",
+//	  "webhooks": {
+//	    "ops": {
+//	      "url": "https://hooks.example.com/abc",
+//	      "headers": {"Authorization": "Bearer ..."},
+//	      "cuerpo": "{\"text\": {{json .Mensaje}}}"
+//	    }
+//	  },
+//	  "reglas": [
+//	    {"nombre": "sin_ventas", "metrica": "tasa_ventas", "ventana": "2m",
+//	     "menor_que": 0.001, "webhooks": ["ops"], "reenviar": "30m"},
+//	    {"nombre": "lag", "metrica": "lag_consumer", "mayor_que": 10000, "webhooks": ["ops"]},
+//	    {"nombre": "errores_kafka", "metrica": "tasa_expvar", "ventana": "1m",
+//	     "url": "http://go-server:9103/debug/vars", "variable": "estados.ERROR_KAFKA",
+//	     "mayor_que": 1, "webhooks": ["ops"]}
+//	  ]
+//	}
+//
+// Métricas:
+//
+//	tasa_ventas   ventas/s según keys.ReportesPorCategoria, sobre "ventana"
+//	lag_consumer  mensajes sin confirmar de KAFKA_GROUP en KAFKA_TOPIC
+//	expvar        valor de "variable" (camino con puntos) en el JSON de "url"
+//	tasa_expvar   lo mismo pero como tasa por segundo sobre "ventana"
+//
+// Se avisa al disparar, cada "reenviar" mientras siga disparada (por
+// defecto nunca) y una vez al resolverse. El cambio de estado recién cuenta
+// cuando algún webhook recibió el aviso: si fallan todos, se reintenta en la
+// próxima evaluación.
+//
+// El cuerpo es un text/template con .Regla .Estado .Valor .Umbral .Mensaje
+// .Timestamp y la función json; sin "cuerpo" se manda el aviso entero como
+// JSON.
+func avisos(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema, brokers []string, topic, group string) error {
+	cfg, err := cargarConfigAvisos(getenv("AVISOS_CONFIG", ""))
+	if err != nil {
+		return err
+	}
+	ev := &evaluadorAvisos{
+		cfg:     cfg,
+		rdb:     rdb,
+		ks:      ks,
+		brokers: brokers,
+		topic:   topic,
+		group:   group,
+		http:    &http.Client{Timeout: config.LeerDuracion("AVISOS_HTTP_TIMEOUT", 10*time.Second)},
+		estados: make(map[string]*estadoRegla),
+	}
+	slog.Info("avisos", "reglas", len(cfg.Reglas), "webhooks", len(cfg.Webhooks), "intervalo", time.Duration(cfg.Intervalo).String())
+
+	t := time.NewTicker(time.Duration(cfg.Intervalo))
+	defer t.Stop()
+	for {
+		ev.evaluar(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// ===== Config =====
+
+type webhookAviso struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Cuerpo  string            `json:"cuerpo"`
+
+	tmpl *template.Template
+}
+
+type reglaAviso struct {
+	Nombre   string   `json:"nombre"`
+	Metrica  string   `json:"metrica"`
+	Ventana  duracion `json:"ventana"`
+	URL      string   `json:"url"`
+	Variable string   `json:"variable"`
+	MayorQue *float64 `json:"mayor_que"`
+	MenorQue *float64 `json:"menor_que"`
+	Webhooks []string `json:"webhooks"`
+	Reenviar duracion `json:"reenviar"`
+}
+
+type configAvisos struct {
+	Intervalo duracion                 `json:"intervalo"`
+	Webhooks  map[string]*webhookAviso `json:"webhooks"`
+	Reglas    []*reglaAviso            `json:"reglas"`
+}
+
+var funcionesAviso = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func cargarConfigAvisos(path string) (*configAvisos, error) {
+	if path == "" {
+		return nil, errors.New("AVISOS_CONFIG requerido")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("AVISOS_CONFIG: %w", err)
+	}
+	cfg := &configAvisos{Intervalo: duracion(15 * time.Second)}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("AVISOS_CONFIG %s: %w", path, err)
+	}
+	if cfg.Intervalo <= 0 {
+		return nil, errors.New("AVISOS_CONFIG: intervalo debe ser > 0")
+	}
+
+	for nombre, w := range cfg.Webhooks {
+		if w.URL == "" {
+			return nil, fmt.Errorf("webhook %s: falta url", nombre)
+		}
+		if w.Cuerpo != "" {
+			if w.tmpl, err = template.New(nombre).Funcs(funcionesAviso).Parse(w.Cuerpo); err != nil {
+				return nil, fmt.Errorf("webhook %s: cuerpo: %w", nombre, err)
+			}
+		}
+	}
+
+	vistos := make(map[string]bool)
+	for _, r := range cfg.Reglas {
+		if r.Nombre == "" || vistos[r.Nombre] {
+			return nil, fmt.Errorf("regla sin nombre o repetida: %q", r.Nombre)
+		}
+		vistos[r.Nombre] = true
+		if (r.MayorQue == nil) == (r.MenorQue == nil) {
+			return nil, fmt.Errorf("regla %s: usar mayor_que o menor_que (uno solo)", r.Nombre)
+		}
+		switch r.Metrica {
+		case "lag_consumer", "expvar":
+		case "tasa_ventas", "tasa_expvar":
+			if r.Ventana <= 0 {
+				r.Ventana = duracion(time.Minute)
+			}
+		default:
+			return nil, fmt.Errorf("regla %s: métrica desconocida %q", r.Nombre, r.Metrica)
+		}
+		if strings.HasSuffix(r.Metrica, "expvar") && (r.URL == "" || r.Variable == "") {
+			return nil, fmt.Errorf("regla %s: %s necesita url y variable", r.Nombre, r.Metrica)
+		}
+		for _, w := range r.Webhooks {
+			if cfg.Webhooks[w] == nil {
+				return nil, fmt.Errorf("regla %s: webhook desconocido %q", r.Nombre, w)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// ===== Evaluación =====
+
+type muestra struct {
+	t     time.Time
+	valor float64
+}
+
+type estadoRegla struct {
+	disparada bool
+	ultimo    time.Time // último envío mientras estuvo disparada
+	muestras  []muestra // para las tasas
+}
+
+type aviso struct {
+	Regla     string  `json:"regla"`
+	Estado    string  `json:"estado"` // disparada | resuelta
+	Valor     float64 `json:"valor"`
+	Umbral    float64 `json:"umbral"`
+	Mensaje   string  `json:"mensaje"`
+	Timestamp int64   `json:"timestampUnixMs"`
+}
+
+type evaluadorAvisos struct {
+	cfg          *configAvisos
+	rdb          redis.UniversalClient
+	ks           keys.Schema
+	brokers      []string
+	topic, group string
+	http         *http.Client
+	estados      map[string]*estadoRegla
+}
+
+func (ev *evaluadorAvisos) evaluar(ctx context.Context) {
+	ahora := time.Now()
+	for _, r := range ev.cfg.Reglas {
+		est, ok := ev.estados[r.Nombre]
+		if !ok {
+			est = &estadoRegla{}
+			ev.estados[r.Nombre] = est
+		}
+		valor, listo, err := ev.medir(ctx, r, est, ahora)
+		if err != nil {
+			slog.Warn("avisos: regla", "regla", r.Nombre, "err", err)
+			continue
+		}
+		if !listo {
+			continue // la tasa necesita al menos una ventana de muestras
+		}
+
+		umbral, dispara, cmp := 0.0, false, ""
+		if r.MayorQue != nil {
+			umbral, dispara, cmp = *r.MayorQue, valor > *r.MayorQue, ">"
+		} else {
+			umbral, dispara, cmp = *r.MenorQue, valor < *r.MenorQue, "<"
+		}
+
+		a := aviso{Regla: r.Nombre, Valor: valor, Umbral: umbral, Timestamp: ahora.UnixMilli()}
+		switch {
+		case dispara && (!est.disparada || (r.Reenviar > 0 && ahora.Sub(est.ultimo) >= time.Duration(r.Reenviar))):
+			a.Estado = "disparada"
+			a.Mensaje = fmt.Sprintf("%s: %s=%.4g %s %.4g", r.Nombre, r.Metrica, valor, cmp, umbral)
+		case !dispara && est.disparada:
+			a.Estado = "resuelta"
+			a.Mensaje = fmt.Sprintf("%s resuelta: %s=%.4g", r.Nombre, r.Metrica, valor)
+		default:
+			continue
+		}
+		slog.Warn("aviso", "mensaje", a.Mensaje)
+		if !ev.avisar(ctx, r, a) {
+			// el estado no cambia: se vuelve a intentar en la próxima evaluación
+			continue
+		}
+		est.disparada = a.Estado == "disparada"
+		if est.disparada {
+			est.ultimo = ahora
+		}
+	}
+}
+
+// avisar manda el aviso a los webhooks de la regla. Devuelve true si al
+// menos uno lo recibió (o la regla no tiene webhooks).
+func (ev *evaluadorAvisos) avisar(ctx context.Context, r *reglaAviso, a aviso) bool {
+	entregado := len(r.Webhooks) == 0
+	for _, w := range r.Webhooks {
+		if err := ev.enviar(ctx, w, a); err != nil {
+			slog.Error("avisos: webhook", "webhook", w, "regla", r.Nombre, "estado", a.Estado, "err", err)
+			continue
+		}
+		entregado = true
+	}
+	return entregado
+}
+
+// medir devuelve el valor actual de la métrica. Para las tasas, listo es
+// false hasta tener muestras que cubran la ventana.
+func (ev *evaluadorAvisos) medir(ctx context.Context, r *reglaAviso, est *estadoRegla, ahora time.Time) (float64, bool, error) {
+	var (
+		v   float64
+		err error
+	)
+	switch r.Metrica {
+	case "lag_consumer":
+		v, err = ev.lagConsumer(ctx)
+		return v, err == nil, err
+	case "expvar":
+		v, err = ev.leerExpvar(ctx, r.URL, r.Variable)
+		return v, err == nil, err
+	case "tasa_ventas":
+		v, err = ev.totalVentas(ctx)
+	case "tasa_expvar":
+		v, err = ev.leerExpvar(ctx, r.URL, r.Variable)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	ventana := time.Duration(r.Ventana)
+	est.muestras = append(est.muestras, muestra{ahora, v})
+	// conservar la muestra más nueva que ya tenga al menos "ventana" de edad
+	for len(est.muestras) > 1 && ahora.Sub(est.muestras[1].t) >= ventana {
+		est.muestras = est.muestras[1:]
+	}
+	primera := est.muestras[0]
+	if ahora.Sub(primera.t) < ventana {
+		return 0, false, nil
+	}
+	delta := v - primera.valor
+	if delta < 0 {
+		// contador reiniciado (rebuild, reinicio del proceso)
+		delta = 0
+	}
+	return delta / ahora.Sub(primera.t).Seconds(), true, nil
+}
+
+func (ev *evaluadorAvisos) totalVentas(ctx context.Context) (float64, error) {
+	vals, err := ev.rdb.HVals(ctx, ev.ks.ReportesPorCategoria()).Result()
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, s := range vals {
+		n, _ := strconv.ParseFloat(s, 64)
+		total += n
+	}
+	return total, nil
+}
+
+// lagConsumer suma, por partición, el último offset menos el confirmado
+// por el grupo.
+func (ev *evaluadorAvisos) lagConsumer(ctx context.Context) (float64, error) {
+	inicio, fin, err := rangoParticiones(ctx, ev.brokers, ev.topic, -1, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	parts := make([]int, 0, len(fin))
+	for p := range fin {
+		parts = append(parts, p)
+	}
+	cli := &kafka.Client{Addr: kafka.TCP(ev.brokers...), Timeout: timeoutKafka(), Transport: segKafka.Transport(timeoutKafka())}
+	resp, err := cli.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: ev.group,
+		Topics:  map[string][]int{ev.topic: parts},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	lag := int64(0)
+	for _, p := range resp.Topics[ev.topic] {
+		if p.Error != nil {
+			return 0, fmt.Errorf("partición %d: %w", p.Partition, p.Error)
+		}
+		confirmado := p.CommittedOffset
+		if confirmado < 0 {
+			// el grupo nunca confirmó: todo lo retenido está pendiente
+			confirmado = inicio[p.Partition]
+		}
+		lag += max(fin[p.Partition]-confirmado, 0)
+	}
+	return float64(lag), nil
+}
+
+// leerExpvar trae el JSON de /debug/vars y sigue el camino con puntos.
+func (ev *evaluadorAvisos) leerExpvar(ctx context.Context, url, variable string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := ev.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	var v any
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return 0, fmt.Errorf("%s: %w", url, err)
+	}
+	for _, parte := range strings.Split(variable, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("%s: %s no es un objeto", url, variable)
+		}
+		if v, ok = m[parte]; !ok {
+			// un contador que todavía no se incrementó no aparece
+			return 0, nil
+		}
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s no es numérica", url, variable)
+	}
+	return f, nil
+}
+
+// ===== Envío =====
+
+const reintentosWebhook = 3
+
+// esperaWebhook es la espera base entre reintentos (1s, 2s, ...).
+var esperaWebhook = time.Second
+
+func (ev *evaluadorAvisos) enviar(ctx context.Context, nombre string, a aviso) error {
+	w := ev.cfg.Webhooks[nombre]
+	var cuerpo bytes.Buffer
+	if w.tmpl != nil {
+		if err := w.tmpl.Execute(&cuerpo, a); err != nil {
+			return fmt.Errorf("cuerpo: %w", err)
+		}
+	} else if err := json.NewEncoder(&cuerpo).Encode(a); err != nil {
+		return err
+	}
+
+	var err error
+	for i := 0; i < reintentosWebhook; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(time.Duration(i) * esperaWebhook):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = ev.post(ctx, w, cuerpo.Bytes()); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (ev *evaluadorAvisos) post(ctx context.Context, w *webhookAviso, cuerpo []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := ev.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ===== Stub =====
+
+// webhookStub es un receptor local para probar los avisos: loguea cada POST
+// y responde 204.
+//
+//	k8s_kafka webhook-stub [addr]   (por defecto :8099)
+func webhookStub(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		slog.Info("webhook stub", "metodo", r.Method, "path", r.URL.Path, "cuerpo", string(bytes.TrimSpace(b)))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := &http.Server{Addr: addr, Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("webhook stub escuchando", "addr", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
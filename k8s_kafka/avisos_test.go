@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookPrueba registra los avisos recibidos y responde con el código que
+// indique codigo.
+type webhookPrueba struct {
+	codigo atomic.Int32
+	mu     sync.Mutex
+	posts  int
+	avisos []aviso
+}
+
+func (w *webhookPrueba) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var a aviso
+	json.NewDecoder(r.Body).Decode(&a)
+	w.mu.Lock()
+	w.posts++
+	if c := w.codigo.Load(); c < 300 {
+		w.avisos = append(w.avisos, a)
+	}
+	w.mu.Unlock()
+	rw.WriteHeader(int(w.codigo.Load()))
+}
+
+func (w *webhookPrueba) recibidos() (int, []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	estados := make([]string, len(w.avisos))
+	for i, a := range w.avisos {
+		estados[i] = a.Estado
+	}
+	return w.posts, estados
+}
+
+func TestAvisosDisparaResuelveYReintenta(t *testing.T) {
+	esperaWebhook = time.Millisecond
+	t.Cleanup(func() { esperaWebhook = time.Second })
+
+	var valor atomic.Int64
+	metricas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"estados": {"ERROR_KAFKA": %d}}`, valor.Load())
+	}))
+	defer metricas.Close()
+
+	wh := &webhookPrueba{}
+	wh.codigo.Store(http.StatusInternalServerError)
+	srv := httptest.NewServer(wh)
+	defer srv.Close()
+
+	umbral := 1.0
+	ev := &evaluadorAvisos{
+		cfg: &configAvisos{
+			Webhooks: map[string]*webhookAviso{"ops": {URL: srv.URL}},
+			Reglas: []*reglaAviso{{
+				Nombre: "errores_kafka", Metrica: "expvar", URL: metricas.URL,
+				Variable: "estados.ERROR_KAFKA", MayorQue: &umbral, Webhooks: []string{"ops"},
+			}},
+		},
+		http:    srv.Client(),
+		estados: make(map[string]*estadoRegla),
+	}
+	ctx := context.Background()
+	comprobar := func(paso string, posts int, estados []string, disparada bool) {
+		t.Helper()
+		gotPosts, gotEstados := wh.recibidos()
+		if gotPosts != posts || fmt.Sprint(gotEstados) != fmt.Sprint(estados) {
+			t.Fatalf("%s: %d posts %v, quiero %d %v", paso, gotPosts, gotEstados, posts, estados)
+		}
+		if d := ev.estados["errores_kafka"].disparada; d != disparada {
+			t.Fatalf("%s: disparada = %v, quiero %v", paso, d, disparada)
+		}
+	}
+
+	// sin superar el umbral no se avisa
+	ev.evaluar(ctx)
+	comprobar("bajo el umbral", 0, nil, false)
+
+	// dispara pero el webhook falla: reintenta y no queda disparada
+	valor.Store(5)
+	ev.evaluar(ctx)
+	comprobar("webhook caído", reintentosWebhook, nil, false)
+
+	// en la próxima evaluación se vuelve a intentar
+	wh.codigo.Store(http.StatusNoContent)
+	ev.evaluar(ctx)
+	comprobar("webhook repuesto", reintentosWebhook+1, []string{"disparada"}, true)
+
+	// sigue disparada sin reenviar: no se repite
+	ev.evaluar(ctx)
+	comprobar("sigue disparada", reintentosWebhook+1, []string{"disparada"}, true)
+
+	// se resuelve con el webhook caído: sigue disparada hasta entregarlo
+	valor.Store(0)
+	wh.codigo.Store(http.StatusBadGateway)
+	ev.evaluar(ctx)
+	comprobar("resolución no entregada", 2*reintentosWebhook+1, []string{"disparada"}, true)
+
+	wh.codigo.Store(http.StatusOK)
+	ev.evaluar(ctx)
+	comprobar("resuelta", 2*reintentosWebhook+2, []string{"disparada", "resuelta"}, false)
+}
+
+func TestAvisosReenviar(t *testing.T) {
+	wh := &webhookPrueba{}
+	wh.codigo.Store(http.StatusNoContent)
+	srv := httptest.NewServer(wh)
+	defer srv.Close()
+	metricas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"lag": 50}`)
+	}))
+	defer metricas.Close()
+
+	umbral := 10.0
+	ev := &evaluadorAvisos{
+		cfg: &configAvisos{
+			Webhooks: map[string]*webhookAviso{"ops": {URL: srv.URL, Cuerpo: "{{json .Mensaje}}"}},
+			Reglas: []*reglaAviso{{
+				Nombre: "lag", Metrica: "expvar", URL: metricas.URL, Variable: "lag",
+				MayorQue: &umbral, Webhooks: []string{"ops"}, Reenviar: duracion(time.Hour),
+			}},
+		},
+		http:    srv.Client(),
+		estados: make(map[string]*estadoRegla),
+	}
+	ctx := context.Background()
+	ev.evaluar(ctx)
+	ev.evaluar(ctx)
+	if posts, _ := wh.recibidos(); posts != 1 {
+		t.Fatalf("%d posts antes de reenviar, quiero 1", posts)
+	}
+	// pasó el intervalo de reenvío
+	ev.estados["lag"].ultimo = time.Now().Add(-2 * time.Hour)
+	ev.evaluar(ctx)
+	if posts, _ := wh.recibidos(); posts != 2 {
+		t.Fatalf("%d posts después de reenviar, quiero 2", posts)
+	}
+}
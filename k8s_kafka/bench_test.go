@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/keys"
+)
+
+// Benchmark del pool de workers sin Kafka ni Valkey:
+//
+//	go test -run '^$' -bench Pool [-productos N] [-valkey host:port]
+//
+// Los mensajes salen de una fuente en memoria que imita a kafka.Reader
+// (particiona por productoId, lleva offsets y registra commits) y los
+// agregados van a un miniredis dentro del mismo proceso, o a un Valkey real
+// con -valkey. SINKS se respeta igual que en el consumer, para medir
+// también los otros sinks. particiones=1 es la línea base secuencial.
+var (
+	productosBench = flag.Int("productos", 500, "productoIds distintos del benchmark")
+	valkeyBench    = flag.String("valkey", "", "Valkey real para el benchmark (por defecto miniredis en memoria)")
+)
+
+func BenchmarkPool(b *testing.B) {
+	for _, particiones := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("particiones=%d", particiones), func(b *testing.B) {
+			benchPool(b, particiones, *productosBench)
+		})
+	}
+}
+
+func benchPool(b *testing.B, particiones, productos int) {
+	addr := *valkeyBench
+	if addr == "" {
+		addr = miniredis.RunT(b).Addr()
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr, PoolSize: 4 * particiones})
+	defer rdb.Close()
+
+	ks := keys.New("bench", false)
+	ctx := context.Background()
+	if *valkeyBench != "" {
+		rdb.Del(ctx, ks.Conteo())
+	}
+	agg, err := nuevoAgregador(rdb, ks)
+	if err != nil {
+		b.Fatal(err)
+	}
+	agg.retencion = &retencionEventos{modo: "off"}
+
+	// SINKS aplica igual que en el consumer (por defecto solo valkey)
+	sink, err := sinksDesdeEnv(agg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sink.cerrar()
+
+	fuente := nuevaFuenteMemoria(particiones, b.N, productos)
+	pool := nuevoPoolParticiones(ctx, sink, func(ctx context.Context, m kafka.Message) error {
+		return fuente.CommitMessages(ctx, m)
+	})
+	pool.logOK = false
+
+	b.ResetTimer()
+	if err := consumir(ctx, fuente, pool); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "msg/s")
+
+	// control: todo lo leído quedó agregado y confirmado
+	if strings.Contains(getenv("SINKS", "valkey"), "valkey") {
+		conteos, err := rdb.HGetAll(ctx, ks.Conteo()).Result()
+		if err != nil {
+			b.Fatal(err)
+		}
+		agregados := 0
+		for _, c := range conteos {
+			n, _ := strconv.Atoi(c)
+			agregados += n
+		}
+		if agregados != b.N {
+			b.Fatalf("agregados %d de %d mensajes", agregados, b.N)
+		}
+	}
+	if p, ok := fuente.pendiente(); !ok {
+		b.Fatalf("partición %d sin confirmar hasta el final", p)
+	}
+}
+
+// fuenteMemoria genera ventas como lo haría el producer con el particionado
+// por defecto (KAFKA_PARTICIONADO=producto): key = productoId y partición
+// por murmur2, con el mismo balancer que el gRPC server.
+type fuenteMemoria struct {
+	total, leidos int
+	productos     int
+	rnd           *rand.Rand
+	offsets       []int64
+	particiones   []int
+
+	mu          sync.Mutex
+	confirmados []int64
+}
+
+var categoriasBench = []string{"Electronica", "Ropa", "Hogar", "Belleza"}
+
+func nuevaFuenteMemoria(particiones, total, productos int) *fuenteMemoria {
+	f := &fuenteMemoria{
+		total:       total,
+		productos:   productos,
+		rnd:         rand.New(rand.NewSource(1)),
+		offsets:     make([]int64, particiones),
+		particiones: make([]int, particiones),
+		confirmados: make([]int64, particiones),
+	}
+	for i := range f.confirmados {
+		f.particiones[i] = i
+		f.confirmados[i] = -1
+	}
+	return f
+}
+
+func (f *fuenteMemoria) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if f.leidos == f.total {
+		return kafka.Message{}, io.EOF
+	}
+	f.leidos++
+
+	prod := f.rnd.Intn(f.productos)
+	v := Venta{
+		Categoria:       categoriasBench[prod%len(categoriasBench)],
+		Precio:          float64(1+f.rnd.Intn(100000)) / 100,
+		CantidadVendida: 1 + f.rnd.Intn(5),
+		ProductoID:      "P" + strconv.Itoa(prod),
+	}
+	val, err := json.Marshal(v)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	m := kafka.Message{
+		Topic: "bench",
+		Key:   []byte(v.ProductoID),
+		Value: val,
+		Time:  time.Now(),
+	}
+	m.Partition = kafka.Murmur2Balancer{}.Balance(m, f.particiones...)
+	m.Offset = f.offsets[m.Partition]
+	f.offsets[m.Partition]++
+	return m, nil
+}
+
+func (f *fuenteMemoria) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range msgs {
+		if m.Offset > f.confirmados[m.Partition] {
+			f.confirmados[m.Partition] = m.Offset
+		}
+	}
+	return nil
+}
+
+// pendiente devuelve la primera partición cuyo último offset leído no se
+// confirmó.
+func (f *fuenteMemoria) pendiente() (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for p, o := range f.offsets {
+		if o > 0 && f.confirmados[p] != o-1 {
+			return p, false
+		}
+	}
+	return 0, true
+}
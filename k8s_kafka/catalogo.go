@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// catalogoProductos es una copia en memoria del catálogo (keys.Catalogo, lo
+// administra el gRPC server) que se refresca cada CATALOGO_REFRESH. Con ella
+// los workers enriquecen cada venta con nombre, precio de lista y
+// profundidad de descuento sin ir a Valkey por mensaje. Un producto creado
+// después del último refresco sale sin enriquecer hasta el siguiente.
+type catalogoProductos struct {
+	rdb      redis.UniversalClient
+	key      string
+	refresco time.Duration
+
+	mu        sync.RWMutex
+	productos map[string]productoCatalogo
+}
+
+// productoCatalogo es el JSON que guarda el gRPC server.
+type productoCatalogo struct {
+	Nombre       string  `json:"nombre"`
+	Categoria    string  `json:"categoria"`
+	PrecioLista  float64 `json:"precioLista"`
+	DescuentoPct float64 `json:"descuentoPct"`
+}
+
+func nuevoCatalogo(rdb redis.UniversalClient, ks keys.Schema) *catalogoProductos {
+	return &catalogoProductos{
+		rdb:      rdb,
+		key:      ks.Catalogo(),
+		refresco: config.LeerDuracion("CATALOGO_REFRESH", 30*time.Second),
+	}
+}
+
+// cargar reemplaza la copia en memoria por el catálogo actual.
+func (c *catalogoProductos) cargar(ctx context.Context) error {
+	todos, err := c.rdb.HGetAll(ctx, c.key).Result()
+	if err != nil {
+		return err
+	}
+	productos := make(map[string]productoCatalogo, len(todos))
+	for id, v := range todos {
+		var p productoCatalogo
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			slog.Warn("catálogo: producto ilegible", "producto_id", id, "err", err)
+			continue
+		}
+		productos[id] = p
+	}
+	c.mu.Lock()
+	c.productos = productos
+	c.mu.Unlock()
+	return nil
+}
+
+// correr refresca el catálogo hasta que se cancele ctx.
+func (c *catalogoProductos) correr(ctx context.Context) {
+	t := time.NewTicker(c.refresco)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := c.cargar(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("catálogo: refresco fallido", "err", err)
+			}
+		}
+	}
+}
+
+// enriquecer completa nombre, precio de lista y descuento real de la venta
+// si el producto está en el catálogo.
+func (c *catalogoProductos) enriquecer(v *Venta) {
+	c.mu.RLock()
+	p, ok := c.productos[v.ProductoID]
+	c.mu.RUnlock()
+	if !ok {
+		return
+	}
+	v.Nombre = p.Nombre
+	v.PrecioLista = p.PrecioLista
+	if p.PrecioLista > 0 {
+		v.DescuentoPct = 100 * (1 - v.Precio/p.PrecioLista)
+	}
+}
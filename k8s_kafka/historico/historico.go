@@ -0,0 +1,241 @@
+// Package historico guarda las ventas en una base relacional para análisis
+// ad hoc ("ingresos por categoría por hora el último Black Friday").
+//
+// Cada venta es una fila de la tabla ventas, identificada por el mensaje de
+// Kafka que la trajo (topic, particion, kafka_offset): reintentos, rebalances
+// o un reinicio que vuelve a leer mensajes no la duplican. En la misma
+// transacción se incrementan los rollups ventas_por_hora y ventas_por_dia,
+// solo con las filas que efectivamente se insertaron.
+//
+// Soporta SQLite (modernc.org/sqlite, sin cgo) para uso local y Postgres
+// (pgx). El esquema se maneja con migraciones embebidas (ver Migrar).
+package historico
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Venta es una fila de la tabla ventas. Refleja los campos de SaleEvent
+// más la posición en Kafka.
+type Venta struct {
+	Topic      string
+	Particion  int
+	Offset     int64
+	Timestamp  time.Time
+	Categoria  string
+	ProductoID string
+	Precio     float64
+	Cantidad   int
+
+	Tenant string // vacío = sin autenticación (NULL)
+
+	// Del catálogo; Nombre vacío = producto fuera del catálogo (NULL).
+	Nombre       string
+	PrecioLista  float64
+	DescuentoPct float64
+}
+
+// Store es la conexión a la base histórica.
+type Store struct {
+	db   *sql.DB
+	pg   bool
+	lote int // filas por INSERT
+}
+
+// columnasVenta es el orden de columnas del INSERT de ventas.
+const columnasVenta = 11
+
+// Abrir conecta con driver "sqlite" (dsn = ruta del archivo) o "postgres"
+// (dsn = URL). filasPorInsert acota cuántas filas van en cada INSERT
+// multi-fila.
+func Abrir(driver, dsn string, filasPorInsert int) (*Store, error) {
+	s := &Store{lote: filasPorInsert}
+	nombre := "sqlite"
+	switch driver {
+	case "sqlite":
+		// espera en vez de fallar si otro proceso tiene el lock de escritura
+		if !strings.Contains(dsn, "_pragma=busy_timeout") {
+			sep := "?"
+			if strings.Contains(dsn, "?") {
+				sep = "&"
+			}
+			dsn += sep + "_pragma=busy_timeout(5000)"
+		}
+	case "postgres":
+		s.pg = true
+		nombre = "pgx"
+	default:
+		return nil, fmt.Errorf("driver inválido: %q (sqlite|postgres)", driver)
+	}
+	if s.lote <= 0 {
+		s.lote = 500
+	}
+	// límite de parámetros por sentencia: 32766 en SQLite, 65535 en Postgres
+	s.lote = min(s.lote, 32766/columnasVenta)
+
+	db, err := sql.Open(nombre, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if !s.pg {
+		// SQLite admite un solo escritor
+		db.SetMaxOpenConns(1)
+	}
+	s.db = db
+	return s, nil
+}
+
+// Close cierra la conexión.
+func (s *Store) Close() error { return s.db.Close() }
+
+// arg devuelve el placeholder n-ésimo (1..) del driver.
+func (s *Store) arg(n int) string {
+	if s.pg {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+type claveRollup struct {
+	inicio    time.Time
+	categoria string
+}
+
+type rollup struct {
+	ventas, unidades int64
+	ingresos         float64
+}
+
+// Guardar inserta las ventas en una transacción, ignorando las que ya
+// estaban, y actualiza los rollups con las nuevas. Devuelve cuántas eran
+// nuevas.
+func (s *Store) Guardar(ctx context.Context, ventas []Venta) (int, error) {
+	if len(ventas) == 0 {
+		return 0, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	porHora := make(map[claveRollup]*rollup)
+	porDia := make(map[claveRollup]*rollup)
+	nuevas := 0
+	for i := 0; i < len(ventas); i += s.lote {
+		tanda := ventas[i:min(i+s.lote, len(ventas))]
+		insertadas, err := s.insertar(ctx, tx, tanda)
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range tanda {
+			if !insertadas[clave(v)] {
+				continue
+			}
+			nuevas++
+			ts := v.Timestamp.UTC()
+			sumar(porHora, claveRollup{ts.Truncate(time.Hour), v.Categoria}, v)
+			sumar(porDia, claveRollup{time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC), v.Categoria}, v)
+		}
+	}
+
+	if err := s.incrementar(ctx, tx, "ventas_por_hora", "hora", porHora); err != nil {
+		return 0, err
+	}
+	if err := s.incrementar(ctx, tx, "ventas_por_dia", "dia", porDia); err != nil {
+		return 0, err
+	}
+	return nuevas, tx.Commit()
+}
+
+type claveMensaje struct {
+	topic     string
+	particion int
+	offset    int64
+}
+
+func clave(v Venta) claveMensaje { return claveMensaje{v.Topic, v.Particion, v.Offset} }
+
+// insertar hace un INSERT multi-fila y devuelve qué mensajes se insertaron
+// (los repetidos los descarta ON CONFLICT DO NOTHING).
+func (s *Store) insertar(ctx context.Context, tx *sql.Tx, ventas []Venta) (map[claveMensaje]bool, error) {
+	var q strings.Builder
+	q.WriteString(`INSERT INTO ventas (topic, particion, kafka_offset, ts, categoria, producto_id, precio, cantidad, nombre, descuento_pct, tenant) VALUES `)
+	args := make([]any, 0, len(ventas)*columnasVenta)
+	for i, v := range ventas {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteByte('(')
+		for c := 1; c <= columnasVenta; c++ {
+			if c > 1 {
+				q.WriteString(", ")
+			}
+			q.WriteString(s.arg(i*columnasVenta + c))
+		}
+		q.WriteByte(')')
+		var nombre, descuento, tenant any
+		if v.Nombre != "" {
+			nombre = v.Nombre
+		}
+		if v.PrecioLista > 0 {
+			descuento = v.DescuentoPct
+		}
+		if v.Tenant != "" {
+			tenant = v.Tenant
+		}
+		args = append(args, v.Topic, v.Particion, v.Offset, v.Timestamp.UTC(), v.Categoria, v.ProductoID, v.Precio, v.Cantidad, nombre, descuento, tenant)
+	}
+	q.WriteString(` ON CONFLICT (topic, particion, kafka_offset) DO NOTHING RETURNING topic, particion, kafka_offset`)
+
+	rows, err := tx.QueryContext(ctx, q.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	insertadas := make(map[claveMensaje]bool, len(ventas))
+	for rows.Next() {
+		var k claveMensaje
+		if err := rows.Scan(&k.topic, &k.particion, &k.offset); err != nil {
+			return nil, err
+		}
+		insertadas[k] = true
+	}
+	return insertadas, rows.Err()
+}
+
+func sumar(m map[claveRollup]*rollup, k claveRollup, v Venta) {
+	r, ok := m[k]
+	if !ok {
+		r = &rollup{}
+		m[k] = r
+	}
+	r.ventas++
+	r.unidades += int64(v.Cantidad)
+	r.ingresos += v.Precio * float64(v.Cantidad)
+}
+
+func (s *Store) incrementar(ctx context.Context, tx *sql.Tx, tabla, columna string, rollups map[claveRollup]*rollup) error {
+	q := fmt.Sprintf(
+		`INSERT INTO %[1]s (%[2]s, categoria, ventas, unidades, ingresos)
+		 VALUES (%[3]s, %[4]s, %[5]s, %[6]s, %[7]s)
+		 ON CONFLICT (%[2]s, categoria) DO UPDATE SET
+		   ventas   = %[1]s.ventas + excluded.ventas,
+		   unidades = %[1]s.unidades + excluded.unidades,
+		   ingresos = %[1]s.ingresos + excluded.ingresos`,
+		tabla, columna, s.arg(1), s.arg(2), s.arg(3), s.arg(4), s.arg(5))
+	for k, r := range rollups {
+		if _, err := tx.ExecContext(ctx, q, k.inicio, k.categoria, r.ventas, r.unidades, r.ingresos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package historico
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func abrirPrueba(t *testing.T, path string, filasPorInsert int) *Store {
+	t.Helper()
+	s, err := Abrir("sqlite", path, filasPorInsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestMigrarIdempotente(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ventas.db")
+	s := abrirPrueba(t, path, 0)
+
+	primera, err := s.Migrar(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migs, err := cargarMigraciones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(primera) != len(migs) || primera[0] != "0001_ventas" {
+		t.Fatalf("primera corrida aplicó %q", primera)
+	}
+	if segunda, err := s.Migrar(ctx); err != nil || len(segunda) != 0 {
+		t.Fatalf("segunda corrida: %q, %v", segunda, err)
+	}
+
+	// otro proceso sobre la misma base tampoco reaplica nada
+	otro := abrirPrueba(t, path, 0)
+	if tercera, err := otro.Migrar(ctx); err != nil || len(tercera) != 0 {
+		t.Fatalf("otra conexión: %q, %v", tercera, err)
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migraciones`).Scan(&n); err != nil || n != len(migs) {
+		t.Fatalf("schema_migraciones con %d filas, %v; esperaba %d", n, err, len(migs))
+	}
+}
+
+func TestSentencias(t *testing.T) {
+	casos := []struct {
+		nombre string
+		sql    string
+		quiero []string
+	}{
+		{"una", "CREATE TABLE a (x INT);\n", []string{"CREATE TABLE a (x INT)"}},
+		{"comentarios y líneas vacías", "-- comentario\n\nCREATE TABLE a (x INT);\n  -- otro\nCREATE INDEX i ON a (x);", []string{"CREATE TABLE a (x INT)", "CREATE INDEX i ON a (x)"}},
+		{"varias líneas", "CREATE TABLE a (\n    x INT\n);", []string{"CREATE TABLE a (\n    x INT\n)"}},
+		{"sin ; final", "ALTER TABLE a ADD COLUMN y TEXT", []string{"ALTER TABLE a ADD COLUMN y TEXT"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if got := sentencias(c.sql); !slices.Equal(got, c.quiero) {
+				t.Fatalf("sentencias = %q, quiero %q", got, c.quiero)
+			}
+		})
+	}
+}
+
+type filaRollup struct {
+	categoria        string
+	ventas, unidades int64
+	ingresos         float64
+}
+
+func rollups(t *testing.T, s *Store, tabla string) []filaRollup {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT categoria, ventas, unidades, ingresos FROM ` + tabla + ` ORDER BY 1, 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []filaRollup
+	for rows.Next() {
+		var f filaRollup
+		if err := rows.Scan(&f.categoria, &f.ventas, &f.unidades, &f.ingresos); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// Reentregar mensajes ya guardados (reintento, rebalance, reinicio) no
+// duplica filas ni infla los rollups: solo cuentan las filas que el INSERT
+// devolvió con RETURNING.
+func TestGuardarReentrega(t *testing.T) {
+	ctx := context.Background()
+	// 2 filas por INSERT para que un lote pase por varias tandas
+	s := abrirPrueba(t, filepath.Join(t.TempDir(), "ventas.db"), 2)
+	if _, err := s.Migrar(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Date(2025, 11, 28, 10, 15, 0, 0, time.UTC)
+	venta := func(offset int64, categoria string, precio float64, cantidad int, ts time.Time) Venta {
+		return Venta{Topic: "ventas", Particion: 0, Offset: offset, Timestamp: ts, Categoria: categoria, ProductoID: "p", Precio: precio, Cantidad: cantidad}
+	}
+	primera := []Venta{
+		venta(0, "Ropa", 10, 1, t0),
+		venta(1, "Ropa", 20, 2, t0.Add(10*time.Minute)),
+		venta(2, "Ropa", 5, 1, t0.Add(time.Hour)), // otra hora, mismo día
+	}
+	if n, err := s.Guardar(ctx, primera); err != nil || n != 3 {
+		t.Fatalf("primer lote: %d nuevas, %v", n, err)
+	}
+
+	// reentrega de los tres más dos nuevos, uno en otra partición con el mismo offset
+	reentrega := append(slices.Clone(primera),
+		venta(3, "Hogar", 100, 1, t0),
+		Venta{Topic: "ventas", Particion: 1, Offset: 0, Timestamp: t0, Categoria: "Ropa", ProductoID: "p", Precio: 1, Cantidad: 1, Tenant: "t1", Nombre: "Remera", PrecioLista: 2, DescuentoPct: 50},
+	)
+	for i := range 2 {
+		n, err := s.Guardar(ctx, reentrega)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quiero := []int{2, 0}[i]; n != quiero {
+			t.Fatalf("reentrega %d: %d nuevas, esperaba %d", i+1, n, quiero)
+		}
+	}
+
+	var filas int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM ventas`).Scan(&filas); err != nil || filas != 5 {
+		t.Fatalf("%d filas en ventas, %v; esperaba 5", filas, err)
+	}
+	porHora := rollups(t, s, "ventas_por_hora")
+	quieroHora := []filaRollup{
+		{"Hogar", 1, 1, 100},
+		{"Ropa", 1, 1, 5},  // 11:00
+		{"Ropa", 3, 4, 51}, // 10:00: 10 + 40 + 1
+	}
+	if !slices.Equal(porHora, quieroHora) {
+		t.Fatalf("ventas_por_hora = %v, quiero %v", porHora, quieroHora)
+	}
+	porDia := rollups(t, s, "ventas_por_dia")
+	quieroDia := []filaRollup{{"Hogar", 1, 1, 100}, {"Ropa", 4, 5, 56}}
+	if !slices.Equal(porDia, quieroDia) {
+		t.Fatalf("ventas_por_dia = %v, quiero %v", porDia, quieroDia)
+	}
+
+	// los opcionales vacíos quedan NULL
+	var nulos int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM ventas WHERE tenant IS NULL AND nombre IS NULL AND descuento_pct IS NULL`).Scan(&nulos); err != nil || nulos != 4 {
+		t.Fatalf("%d filas con tenant, nombre y descuento NULL, %v; esperaba 4", nulos, err)
+	}
+}
@@ -0,0 +1,160 @@
+package historico
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Las migraciones son archivos NNNN_nombre.sql en migraciones/, embebidos en
+// el binario. Se aplican en orden, cada una en su propia transacción, y se
+// registran en schema_migraciones. Una migración aplicada no se edita: los
+// cambios van en un archivo nuevo. El SQL tiene que valer tanto para SQLite
+// como para Postgres.
+//
+//go:embed migraciones/*.sql
+var archivosMigraciones embed.FS
+
+type migracion struct {
+	version int
+	nombre  string
+	sql     string
+}
+
+func cargarMigraciones() ([]migracion, error) {
+	nombres, err := fs.Glob(archivosMigraciones, "migraciones/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var out []migracion
+	for _, n := range nombres {
+		base := strings.TrimSuffix(strings.TrimPrefix(n, "migraciones/"), ".sql")
+		numero, _, ok := strings.Cut(base, "_")
+		v, err := strconv.Atoi(numero)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migración con nombre inválido: %s", n)
+		}
+		b, err := archivosMigraciones.ReadFile(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, migracion{version: v, nombre: base, sql: string(b)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	for i := 1; i < len(out); i++ {
+		if out[i].version == out[i-1].version {
+			return nil, fmt.Errorf("migraciones con la misma versión: %s y %s", out[i-1].nombre, out[i].nombre)
+		}
+	}
+	return out, nil
+}
+
+// sentencias separa el archivo en sentencias por ";" al final de línea y
+// descarta comentarios de línea completa.
+func sentencias(sql string) []string {
+	var (
+		out []string
+		cur strings.Builder
+	)
+	for _, linea := range strings.Split(sql, "\n") {
+		t := strings.TrimSpace(linea)
+		if t == "" || strings.HasPrefix(t, "--") {
+			continue
+		}
+		cur.WriteString(linea)
+		cur.WriteByte('\n')
+		if strings.HasSuffix(t, ";") {
+			out = append(out, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if s := strings.TrimSpace(cur.String()); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// lockMigraciones es la clave del advisory lock de Postgres que serializa
+// las migraciones entre réplicas que arrancan a la vez.
+const lockMigraciones = 0x76656e7461
+
+// Migrar aplica las migraciones pendientes y devuelve sus nombres.
+func (s *Store) Migrar(ctx context.Context) ([]string, error) {
+	if s.pg {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockMigraciones); err != nil {
+			return nil, err
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockMigraciones)
+	}
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migraciones (
+		version  INTEGER   PRIMARY KEY,
+		nombre   TEXT      NOT NULL,
+		aplicada TIMESTAMP NOT NULL
+	)`); err != nil {
+		return nil, err
+	}
+	migs, err := cargarMigraciones()
+	if err != nil {
+		return nil, err
+	}
+
+	aplicadas := make(map[int]bool)
+	rows, err := s.db.QueryContext(ctx, `SELECT version FROM schema_migraciones`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		aplicadas[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var nuevas []string
+	for _, m := range migs {
+		if aplicadas[m.version] {
+			continue
+		}
+		if err := s.aplicar(ctx, m); err != nil {
+			return nuevas, fmt.Errorf("migración %s: %w", m.nombre, err)
+		}
+		nuevas = append(nuevas, m.nombre)
+	}
+	return nuevas, nil
+}
+
+func (s *Store) aplicar(ctx context.Context, m migracion) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, q := range sentencias(m.sql) {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		fmt.Sprintf(`INSERT INTO schema_migraciones (version, nombre, aplicada) VALUES (%s, %s, %s)`,
+			s.arg(1), s.arg(2), s.arg(3)),
+		m.version, m.nombre, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
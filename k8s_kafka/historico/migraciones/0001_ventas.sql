@@ -0,0 +1,18 @@
+-- Una fila por venta; (topic, particion, kafka_offset) identifica el
+-- mensaje de Kafka y evita duplicados en reintentos y rebalances.
+CREATE TABLE IF NOT EXISTS ventas (
+    topic        TEXT             NOT NULL,
+    particion    INTEGER          NOT NULL,
+    kafka_offset BIGINT           NOT NULL,
+    ts           TIMESTAMP        NOT NULL,
+    categoria    TEXT             NOT NULL,
+    producto_id  TEXT             NOT NULL,
+    precio       DOUBLE PRECISION NOT NULL,
+    cantidad     INTEGER          NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ventas_mensaje ON ventas (topic, particion, kafka_offset);
+
+CREATE INDEX IF NOT EXISTS ventas_ts ON ventas (ts);
+
+CREATE INDEX IF NOT EXISTS ventas_categoria_ts ON ventas (categoria, ts);
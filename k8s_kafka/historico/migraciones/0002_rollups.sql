@@ -0,0 +1,19 @@
+-- Rollups por hora y por día (UTC) y categoría, mantenidos con incrementos
+-- en la misma transacción que inserta las ventas.
+CREATE TABLE IF NOT EXISTS ventas_por_hora (
+    hora      TIMESTAMP        NOT NULL,
+    categoria TEXT             NOT NULL,
+    ventas    BIGINT           NOT NULL,
+    unidades  BIGINT           NOT NULL,
+    ingresos  DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (hora, categoria)
+);
+
+CREATE TABLE IF NOT EXISTS ventas_por_dia (
+    dia       TIMESTAMP        NOT NULL,
+    categoria TEXT             NOT NULL,
+    ventas    BIGINT           NOT NULL,
+    unidades  BIGINT           NOT NULL,
+    ingresos  DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (dia, categoria)
+);
@@ -0,0 +1,5 @@
+-- Datos del catálogo con que el consumer enriquece cada venta. NULL si el
+-- producto no estaba en el catálogo al momento de procesarla.
+ALTER TABLE ventas ADD COLUMN nombre TEXT;
+
+ALTER TABLE ventas ADD COLUMN descuento_pct DOUBLE PRECISION;
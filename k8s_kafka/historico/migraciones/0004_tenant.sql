@@ -0,0 +1,5 @@
+-- Tenant (tienda) autenticado en el gateway. NULL si la venta entró sin
+-- autenticación.
+ALTER TABLE ventas ADD COLUMN tenant TEXT;
+
+CREATE INDEX IF NOT EXISTS ventas_tenant_ts ON ventas (tenant, ts);
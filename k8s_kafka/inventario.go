@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/config"
+)
+
+// ritmoInventario estima a qué hora se agota cada producto según cuánto baja
+// su stock entre corridas del materializador (EWMA de unidades por segundo,
+// con ventana STOCK_RITMO_VENTANA). Vive en memoria del líder: después de un
+// cambio de líder la primera corrida todavía no tiene estimación.
+type ritmoInventario struct {
+	ventana time.Duration
+	previo  map[string]muestraStock
+}
+
+type muestraStock struct {
+	stock int64
+	t     time.Time
+	ritmo ewma // unidades por segundo
+}
+
+func nuevoRitmoInventario() *ritmoInventario {
+	return &ritmoInventario{
+		ventana: config.LeerDuracion("STOCK_RITMO_VENTANA", time.Minute),
+		previo:  make(map[string]muestraStock),
+	}
+}
+
+// observar registra el stock actual y devuelve la hora estimada de
+// agotamiento, si hay ritmo de venta para estimarla.
+func (r *ritmoInventario) observar(id string, stock int64, ahora time.Time) (time.Time, bool) {
+	p, ok := r.previo[id]
+	if !ok {
+		r.previo[id] = muestraStock{stock: stock, t: ahora}
+		return time.Time{}, false
+	}
+	if dt := ahora.Sub(p.t); dt > 0 {
+		// una reposición sube el stock: no cuenta como venta negativa
+		vendidas := math.Max(float64(p.stock-stock), 0)
+		alpha := 1 - math.Exp(-float64(dt)/float64(r.ventana))
+		p.ritmo.agregar(vendidas/dt.Seconds(), alpha)
+	}
+	p.stock, p.t = stock, ahora
+	r.previo[id] = p
+
+	if stock <= 0 || p.ritmo.media <= 0 {
+		return time.Time{}, false
+	}
+	return ahora.Add(time.Duration(float64(stock) / p.ritmo.media * float64(time.Second))), true
+}
+
+// publicarInventario copia el stock restante a stats y publica la hora de
+// agotamiento: la real (StockAgotado, la marca el gRPC server) o la estimada.
+// Sin inventario cargado no hace nada.
+func (m *materializador) publicarInventario(ctx context.Context) error {
+	rdb, ks := m.rdb, m.ks
+
+	stock, err := rdb.HGetAll(ctx, ks.Stock()).Result()
+	if err != nil || len(stock) == 0 {
+		return err
+	}
+	agotado, err := rdb.HGetAll(ctx, ks.StockAgotado()).Result()
+	if err != nil {
+		return err
+	}
+
+	ahora := time.Now()
+	restante := make(map[string]any, len(stock))
+	agotadoEn := make(map[string]any)
+	estimado := make(map[string]any)
+	for id, v := range stock {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		restante[id] = n
+		if ms, err := strconv.ParseInt(agotado[id], 10, 64); err == nil && n <= 0 {
+			agotadoEn[id] = time.UnixMilli(ms).UTC().Format(time.RFC3339)
+		}
+		if eta, ok := m.ritmo.observar(id, n, ahora); ok {
+			estimado[id] = eta.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if len(restante) == 0 {
+		return nil
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, ks.StockRestante(), ks.StockAgotadoEn(), ks.StockAgotamientoEstimado())
+		pipe.HSet(ctx, ks.StockRestante(), restante)
+		if len(agotadoEn) > 0 {
+			pipe.HSet(ctx, ks.StockAgotadoEn(), agotadoEn)
+		}
+		if len(estimado) > 0 {
+			pipe.HSet(ctx, ks.StockAgotamientoEstimado(), estimado)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// lider implementa una elección simple sobre Valkey: la réplica que logra
+// SET NX PX sobre LockLider es líder mientras renueve el lease. Si el pod
+// muere, el lock expira solo y otra réplica toma el rol en a lo sumo un lease.
+type lider struct {
+	rdb   redis.UniversalClient
+	key   string
+	id    string
+	lease time.Duration
+	es    bool
+}
+
+var scriptRenovarLider = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// scriptSoltarLider borra el lock solo si todavía tiene el token de quien lo
+// tomó. También lo usa el lock de compactación (ver tokenLock).
+var scriptSoltarLider = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func nuevoLider(rdb redis.UniversalClient, key string, lease time.Duration) *lider {
+	host, _ := os.Hostname()
+	return &lider{
+		rdb:   rdb,
+		key:   key,
+		id:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+		lease: lease,
+	}
+}
+
+// tokenLock es el valor de un lock que se toma por corrida: distinto en cada
+// llamada, aun dentro del mismo proceso.
+func tokenLock() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// intentar renueva el lease si ya es líder o intenta tomarlo si no.
+func (l *lider) intentar(ctx context.Context) bool {
+	var err error
+	antes := l.es
+	if l.es {
+		var n int64
+		n, err = scriptRenovarLider.Run(ctx, l.rdb, []string{l.key}, l.id, l.lease.Milliseconds()).Int64()
+		l.es = err == nil && n == 1
+	} else {
+		l.es, err = l.rdb.SetNX(ctx, l.key, l.id, l.lease).Result()
+	}
+	if err != nil {
+		slog.Error("líder: error en lock", "key", l.key, "err", err)
+		l.es = false
+	}
+	if l.es != antes {
+		slog.Info("líder", "id", l.id, "es_lider", l.es)
+	}
+	return l.es
+}
+
+func (l *lider) soltar(ctx context.Context) {
+	if !l.es {
+		return
+	}
+	if err := scriptSoltarLider.Run(ctx, l.rdb, []string{l.key}, l.id).Err(); err != nil {
+		slog.Error("líder: error soltando lock", "err", err)
+	}
+	l.es = false
+}
+
+// correr llama fn cada intervalo mientras esta réplica sea líder. El lease
+// se renueva aparte, cada lease/3, también mientras fn corre: una corrida
+// más larga que el lease no lo deja expirar, y el intervalo no depende del
+// lease. Si se pierde el lease, se cancela el ctx de la corrida en curso.
+func (l *lider) correr(ctx context.Context, intervalo time.Duration, fn func(context.Context) error) {
+	renovar := time.NewTicker(l.lease / 3)
+	defer renovar.Stop()
+	t := time.NewTicker(intervalo)
+	defer t.Stop()
+
+	var (
+		fin    chan struct{} // no nil mientras fn corre
+		cancel = func() {}
+	)
+	l.intentar(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			cancel()
+			if fin != nil {
+				<-fin
+			}
+			l.soltar(context.Background())
+			return
+		case <-renovar.C:
+			if !l.intentar(ctx) {
+				cancel()
+			}
+		case <-fin:
+			cancel()
+			fin = nil
+		case <-t.C:
+			if !l.es || fin != nil {
+				continue // sin lease, o la corrida anterior todavía no terminó
+			}
+			var cctx context.Context
+			cctx, cancel = context.WithCancel(ctx)
+			fin = make(chan struct{})
+			go func(fin chan struct{}) {
+				defer close(fin)
+				if err := fn(cctx); err != nil {
+					slog.Error("líder: error en la corrida", "err", err)
+				}
+			}(fin)
+		}
+	}
+}
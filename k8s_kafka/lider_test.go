@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Una corrida más larga que el lease no lo deja expirar: se renueva mientras
+// fn corre. miniredis no vence keys con el reloj real, así que la corrida
+// adelanta el reloj de Valkey medio lease por cada tercio de lease real; sin
+// renovaciones el lock vencería en la segunda vuelta.
+func TestLiderRenuevaDuranteLaCorrida(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	const lease = 300 * time.Millisecond
+	l := nuevoLider(rdb, "lider", lease)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hecho := make(chan error, 1)
+	go l.correr(ctx, 10*time.Millisecond, func(ctx context.Context) error {
+		defer cancel()
+		for i := 0; i < 8; i++ {
+			time.Sleep(lease / 2)
+			mr.FastForward(lease / 2)
+			if v, _ := mr.Get("lider"); v != l.id {
+				hecho <- fmt.Errorf("vuelta %d: lock = %q", i, v)
+				return nil
+			}
+		}
+		hecho <- nil
+		return nil
+	})
+	select {
+	case err := <-hecho:
+		if err != nil {
+			t.Fatalf("el lease venció durante la corrida: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("la corrida no terminó")
+	}
+}
+
+// Si otra réplica se queda con el lock, la corrida en curso se cancela.
+func TestLiderCancelaAlPerderElLease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	l := nuevoLider(rdb, "lider", 150*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	empezo, cancelada := make(chan struct{}), make(chan struct{})
+	terminado := make(chan struct{})
+	go func() {
+		defer close(terminado)
+		l.correr(ctx, 10*time.Millisecond, func(cctx context.Context) error {
+			select {
+			case <-empezo:
+				return nil
+			default:
+				close(empezo)
+			}
+			<-cctx.Done()
+			close(cancelada)
+			return cctx.Err()
+		})
+	}()
+
+	select {
+	case <-empezo:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no tomó el liderazgo")
+	}
+	mr.Set("lider", "otra-replica")
+	select {
+	case <-cancelada:
+	case <-time.After(5 * time.Second):
+		t.Fatal("la corrida siguió después de perder el lease")
+	}
+
+	cancel()
+	<-terminado
+	if v, _ := mr.Get("lider"); v != "otra-replica" {
+		t.Errorf("al salir soltó un lock ajeno: %q", v)
+	}
+}
@@ -2,17 +2,27 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+	"comun/kafkaseg"
+	"comun/keys"
+	"comun/valkeycon"
 )
 
 type Venta struct {
@@ -20,219 +30,251 @@ type Venta struct {
 	Precio          float64 `json:"precio"`
 	CantidadVendida int     `json:"cantidadVendida"`
 	ProductoID      string  `json:"productoId"`
+	TimestampUnixMs int64   `json:"timestampUnixMs"`
+	Tenant          string  `json:"tenant,omitempty"` // vacío sin auth en el gateway
+
+	// Del catálogo (ver catalogoProductos.enriquecer); vacíos si el producto
+	// no está en el catálogo.
+	Nombre       string  `json:"nombre,omitempty"`
+	PrecioLista  float64 `json:"precioLista,omitempty"`
+	DescuentoPct float64 `json:"descuentoPct,omitempty"` // 100·(1 - precio/precioLista)
 }
 
-func main() {
-	ctx := context.Background()
-
-	brokers := getenv("KAFKA_BROKERS", "kafka:9092")
-	topic := getenv("KAFKA_TOPIC", "ventas")
-	group := getenv("KAFKA_GROUP", "ventas-consumer")
-	valkeyAddr := getenv("VALKEY_ADDR", "valkey-primary:6379")
-
-	rdb := redis.NewClient(&redis.Options{Addr: valkeyAddr})
-	defer rdb.Close()
-
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: strings.Split(brokers, ","),
-		Topic:   topic,
-		GroupID: group,
-	})
-	defer reader.Close()
-
-	log.Printf("Consumer listo | brokers=%s | topic=%s group=%s | valkey=%s", brokers, topic, group, valkeyAddr)
+// modos son los subcomandos; sin subcomando corre el consumer.
+var modos = []string{"consumer", "webhook-stub", "rebuild", "anomalias", "avisos", "materializar", "admin"}
 
-	// === Keys requeridas para el dashboard ===
-	sumKey := "venta:stats:sumPrecio"              // HASH: categoria -> suma(precio)
-	cntKey := "venta:stats:count"                  // HASH: categoria -> conteo
-	avgKey := "venta:stats:categorias"             // HASH: categoria -> avg(precio)
-	repKey := "venta:stats:reportes_por_categoria" // HASH: categoria -> total reportes
-
-	maxKey := "venta:stats:precio_max" // STRING
-	minKey := "venta:stats:precio_min" // STRING
-
-	prodZKey := "venta:stats:productos_vendidos"      // ZSET: score=cantidad, member=productoId
-	bestProdKey := "venta:stats:producto_mas_vendido" // STRING: "ID (score)"
-	worstProdKey := "venta:stats:producto_menos_vendido"
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	bestProdCatKey := "venta:stats:best_producto_por_categoria"          // HASH: categoria -> productoId
-	bestAvgCatKey := "venta:stats:best_producto_avgprecio_por_categoria" // HASH: categoria -> avg(precio del best)
-	bestQtyCatKey := "venta:stats:best_producto_qty_por_categoria"       // HASH: categoria -> qty(best) (opcional)
+	cfg, args, err := config.Cargar("k8s_kafka", opciones, os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Config inválida:\n%v", err)
+	}
+	modo := "consumer"
+	if len(args) > 0 {
+		modo = args[0]
+	}
+	if !slices.Contains(modos, modo) {
+		log.Fatalf("Modo desconocido %q (%s)", modo, strings.Join(modos, ", "))
+	}
+	if cfg.Imprimir {
+		cfg.Escribir(os.Stdout)
+		return
+	}
+	if err := configurarLogs(modo); err != nil {
+		log.Fatalf("Logs: %v", err)
+	}
+	if segKafka, err = kafkaseg.Nueva(kafkaseg.DesdeEnv()); err != nil {
+		log.Fatalf("Config Kafka inválida:\n%v", err)
+	}
+	if segKafka.PlanoConPassword() {
+		slog.Warn("KAFKA_SASL_MECHANISM=PLAIN sin TLS: la contraseña viaja sin cifrar")
+	}
 
-	for {
-		m, err := reader.ReadMessage(ctx)
-		if err != nil {
-			log.Printf("Kafka read error: %v", err)
-			time.Sleep(500 * time.Millisecond)
-			continue
+	// Subcomando: k8s_kafka webhook-stub [addr] (receptor para probar avisos)
+	if modo == "webhook-stub" {
+		addr := ":8099"
+		if len(args) > 1 {
+			addr = args[1]
 		}
-
-		keyEvento := "venta:" + topic + ":" + itoa(m.Partition) + ":" + itoa64(m.Offset)
-		val := string(m.Value)
-
-		// 1) Guardar evento crudo
-		if err := rdb.Set(ctx, keyEvento, val, 0).Err(); err != nil {
-			log.Printf("Valkey set error: %v", err)
-			continue
+		if err := webhookStub(ctx, addr); err != nil {
+			log.Fatalf("Webhook stub error: %v", err)
 		}
+		return
+	}
 
-		// 2) Parsear JSON
-		var v Venta
-		if err := json.Unmarshal(m.Value, &v); err != nil {
-			log.Printf("JSON inválido, no agrego stats. offset=%d err=%v value=%s", m.Offset, err, val)
-			continue
-		}
-		if v.Categoria == "" {
-			v.Categoria = "Desconocida"
-		}
-		if v.ProductoID == "" {
-			v.ProductoID = "UNKNOWN"
-		}
+	brokers := getenv("KAFKA_BROKERS", "kafka:9092")
+	topic := getenv("KAFKA_TOPIC", "ventas")
+	group := getenv("KAFKA_GROUP", "ventas-consumer")
 
-		prodCatZKey := "venta:stats:productos_por_categoria:" + v.Categoria
-		sumProdKey := "venta:stats:sumPrecio_por_producto:" + v.Categoria // HASH: productoId -> suma(precio)
-		cntProdKey := "venta:stats:count_por_producto:" + v.Categoria     // HASH: productoId -> conteo
-
-		if v.CantidadVendida > 0 {
-			rdb.ZIncrBy(
-				ctx,
-				prodCatZKey,
-				float64(v.CantidadVendida),
-				v.ProductoID,
-			)
+	// Subcomando: k8s_kafka admin [flags] (topics; no necesita Valkey)
+	if modo == "admin" {
+		if err := admin(ctx, strings.Split(brokers, ","), args[1:]); err != nil {
+			log.Fatalf("Admin error: %v", err)
 		}
+		return
+	}
 
-		if err := updateBestAvgPriceByCategory(
-			ctx, rdb,
-			v.Categoria,
-			prodCatZKey,
-			sumProdKey,
-			cntProdKey,
-			bestProdCatKey,
-			bestAvgCatKey,
-			bestQtyCatKey,
-		); err != nil {
-			log.Printf("update best avg cat error: %v", err)
-		}
+	// Conexión y esquema de keys compartidos con el gRPC server (ver
+	// paquetes valkeycon y keys). En cluster el hash tag es obligatorio para
+	// que las operaciones multi-key no crucen slots.
+	valkeyCfg, err := valkeycon.DesdeEnv()
+	if err != nil {
+		log.Fatalf("Config Valkey inválida:\n%v", err)
+	}
+	rdb, err := valkeycon.Nuevo(valkeyCfg)
+	if err != nil {
+		log.Fatalf("Config Valkey inválida: %v", err)
+	}
+	defer rdb.Close()
+	if err := valkeycon.Esperar(ctx, rdb, valkeyCfg.DialTimeout); err != nil {
+		log.Fatalf("Valkey no disponible: %v", err)
+	}
 
-		// 3) Total de reportes por categoría (para gráfica "Total de Reportes por Categoría")
-		if err := rdb.HIncrBy(ctx, repKey, v.Categoria, 1).Err(); err != nil {
-			log.Printf("Valkey HINCRBY reportes error: %v", err)
-			continue
-		}
+	ks := keys.New(valkeyCfg.Prefijo, valkeyCfg.HashTag)
+	slog.Info("esquema de keys", "prefijo", ks.Prefix())
 
-		// 4) Promedio de precio por categoría (para gráfica "Precio/Producto Promedio por Categoría")
-		newSum, err := rdb.HIncrByFloat(ctx, sumKey, v.Categoria, v.Precio).Result()
-		if err != nil {
-			log.Printf("Valkey HINCRBYFLOAT sum error: %v", err)
-			continue
-		}
-		newCnt, err := rdb.HIncrBy(ctx, cntKey, v.Categoria, 1).Result()
-		if err != nil {
-			log.Printf("Valkey HINCRBY cnt error: %v", err)
-			continue
-		}
-		avg := newSum / float64(newCnt)
-		if err := rdb.HSet(ctx, avgKey, v.Categoria, avg).Err(); err != nil {
-			log.Printf("Valkey HSET avg error: %v", err)
-			continue
-		}
-
-		// 5) Precio máximo y mínimo global (KPIs)
-		if err := updateMaxMin(ctx, rdb, maxKey, minKey, v.Precio); err != nil {
-			log.Printf("Valkey update max/min error: %v", err)
-			continue
+	// Subcomando: k8s_kafka rebuild [flags]
+	if modo == "rebuild" {
+		if err := rebuild(ctx, rdb, ks, strings.Split(brokers, ","), topic, args[1:]); err != nil {
+			log.Fatalf("Rebuild error: %v", err)
 		}
+		return
+	}
 
-		// 6) Producto más/menos vendido global (KPIs)
-		if v.CantidadVendida > 0 {
-			if _, err := rdb.ZIncrBy(ctx, prodZKey, float64(v.CantidadVendida), v.ProductoID).Result(); err != nil {
-				log.Printf("Valkey ZINCRBY error: %v", err)
-				continue
-			}
-			if err := updateBestWorstProduct(ctx, rdb, prodZKey, bestProdKey, worstProdKey); err != nil {
-				log.Printf("Valkey update best/worst error: %v", err)
-				continue
-			}
+	// Subcomando: k8s_kafka anomalias (consumer de detección de anomalías)
+	if modo == "anomalias" {
+		if err := anomalias(ctx, rdb, ks, strings.Split(brokers, ","), topic, group); err != nil {
+			log.Fatalf("Anomalías error: %v", err)
 		}
+		return
+	}
 
-		tsKey := fmt.Sprintf(
-			"venta:ts:precio:%s:%s",
-			v.Categoria,
-			v.ProductoID,
-		)
-
-		newSumProd, err := rdb.HIncrByFloat(ctx, sumProdKey, v.ProductoID, v.Precio).Result()
-		if err != nil {
-			log.Printf("sumProd error: %v", err)
-			continue
+	// Subcomando: k8s_kafka avisos (umbrales -> webhooks)
+	if modo == "avisos" {
+		if err := avisos(ctx, rdb, ks, strings.Split(brokers, ","), topic, group); err != nil {
+			log.Fatalf("Avisos error: %v", err)
 		}
+		return
+	}
 
-		newCntProd, err := rdb.HIncrBy(ctx, cntProdKey, v.ProductoID, 1).Result()
+	// Subcomando: k8s_kafka materializar (solo KPIs derivados, sin consumir)
+	if modo == "materializar" {
+		agg, err := nuevoAgregador(rdb, ks)
 		if err != nil {
-			log.Printf("cntProd error: %v", err)
-			continue
+			log.Fatalf("Config inválida: %v", err)
 		}
+		correrMaterializador(ctx, rdb, ks, agg)
+		return
+	}
 
-		// avg del producto actual (no necesariamente el best)
-		_ = newSumProd / float64(newCntProd)
-
-		tsZKey := fmt.Sprintf("venta:ts:precio:%s:%s", v.Categoria, v.ProductoID)
-		tsHKey := fmt.Sprintf("venta:ts2:precio:%s:%s", v.Categoria, v.ProductoID)
-
-		ts := strconv.FormatInt(time.Now().Unix(), 10)
+	reader := kafka.NewReader(configLector(strings.Split(brokers, ","), topic, group))
+	defer reader.Close()
 
-		_ = rdb.ZAdd(ctx, tsZKey, redis.Z{Score: float64(time.Now().Unix()), Member: fmt.Sprintf("%.2f", v.Precio)}).Err()
+	slog.Info("consumer listo", "brokers", brokers, "topic", topic, "group", group, "valkey", valkeyCfg.String(), "kafka", segKafka.String())
 
-		if err := rdb.HSet(ctx, tsHKey, ts, fmt.Sprintf("%.2f", v.Precio)).Err(); err != nil {
-			log.Printf("Valkey HSET ts2 error: %v", err)
-		}
+	agg, err := nuevoAgregador(rdb, ks)
+	if err != nil {
+		log.Fatalf("Config inválida: %v", err)
+	}
+	go agg.retencion.compactar(ctx, rdb, topic)
+
+	// Los KPIs derivados los recalcula el materializador de una sola réplica
+	// (la líder). Con MATERIALIZER_ENABLED=false se deja a un proceso
+	// aparte (k8s_kafka materializar).
+	liderListo := make(chan struct{})
+	if getenvBool("MATERIALIZER_ENABLED", true) {
+		go func() {
+			correrMaterializador(ctx, rdb, ks, agg)
+			close(liderListo)
+		}()
+	} else {
+		close(liderListo)
+	}
 
-		_ = rdb.ZRemRangeByRank(ctx, tsKey, 0, -1001).Err()
+	sink, err := sinksDesdeEnv(agg)
+	if err != nil {
+		log.Fatalf("Config inválida: %v", err)
+	}
+	defer sink.cerrar()
 
-		log.Printf("OK | cat=%s precio=%.2f cnt=%d avg=%.2f prod=%s cant=%d",
-			v.Categoria, v.Precio, newCnt, avg, v.ProductoID, v.CantidadVendida)
+	// Métricas de los sinks (expvar) en /debug/vars
+	if addr := getenv("METRICS_ADDR", ":9102"); addr != "" {
+		go func() {
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				slog.Error("servidor de métricas", "err", err)
+			}
+		}()
 	}
-}
 
-func updateMaxMin(ctx context.Context, rdb *redis.Client, maxKey, minKey string, precio float64) error {
-	// MAX
-	curMaxStr, err := rdb.Get(ctx, maxKey).Result()
-	if err != nil && err != redis.Nil {
-		return err
+	// Un worker por partición asignada; el offset de cada partición se
+	// confirma en Kafka recién después de escribirlo en los sinks.
+	pool := nuevoPoolParticiones(ctx, sink, func(ctx context.Context, m kafka.Message) error {
+		return reader.CommitMessages(ctx, m)
+	})
+	pool.enriquecer = catalogoDesdeValkey(ctx, rdb, ks).enriquecer
+	dlq := escritorDLQ(strings.Split(brokers, ","))
+	defer dlq.Close()
+	pool.dlq = func(ctx context.Context, m kafka.Message, causa error) error {
+		return dlq.WriteMessages(ctx, mensajeDLQ(m, causa))
 	}
-	curMax := -math.MaxFloat64
-	if err != redis.Nil {
-		if f, e := strconv.ParseFloat(curMaxStr, 64); e == nil {
-			curMax = f
-		}
-	}
-	if precio > curMax {
-		if err := rdb.Set(ctx, maxKey, fmt.Sprintf("%.2f", precio), 0).Err(); err != nil {
-			return err
-		}
+	if err := consumir(ctx, reader, pool); err != nil {
+		slog.Error("consumer", "err", err)
 	}
+	slog.Info("apagando: pendientes aplicados")
+	<-liderListo
+}
 
-	// MIN
-	curMinStr, err := rdb.Get(ctx, minKey).Result()
-	if err != nil && err != redis.Nil {
-		return err
+// segKafka es la seguridad (SASL y TLS) de todas las conexiones a Kafka;
+// se arma en main con la configuración ya validada.
+var segKafka = &kafkaseg.Seguridad{}
+
+func timeoutKafka() time.Duration { return config.LeerDuracion("KAFKA_TIMEOUT", 10*time.Second) }
+
+// configLector es la configuración común de los readers de Kafka
+// (consumer, anomalias y rebuild).
+func configLector(brokers []string, topic, group string) kafka.ReaderConfig {
+	return kafka.ReaderConfig{
+		Brokers:  brokers,
+		Topic:    topic,
+		GroupID:  group,
+		Dialer:   segKafka.Dialer(timeoutKafka()),
+		MinBytes: config.LeerEntero("KAFKA_MIN_BYTES", 1),
+		MaxBytes: config.LeerEntero("KAFKA_MAX_BYTES", 1e6),
+		MaxWait:  config.LeerDuracion("KAFKA_MAX_WAIT", 10*time.Second),
 	}
-	curMin := math.MaxFloat64
-	if err != redis.Nil {
-		if f, e := strconv.ParseFloat(curMinStr, 64); e == nil {
-			curMin = f
-		}
+}
+
+// escritorDLQ publica en KAFKA_DLQ_TOPIC los mensajes que no se pueden
+// parsear. Son raros: se escriben de a uno sin esperar a juntar un lote.
+func escritorDLQ(brokers []string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        getenv("KAFKA_DLQ_TOPIC", "ventas.dlq"),
+		Transport:    segKafka.Transport(timeoutKafka()),
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
 	}
-	if precio < curMin {
-		if err := rdb.Set(ctx, minKey, fmt.Sprintf("%.2f", precio), 0).Err(); err != nil {
-			return err
-		}
+}
+
+// mensajeDLQ copia key, valor y headers (x-request-id incluido) y agrega de
+// dónde salió el mensaje y por qué se descartó.
+func mensajeDLQ(m kafka.Message, causa error) kafka.Message {
+	headers := append(slices.Clone(m.Headers),
+		kafka.Header{Key: "dlq-topic", Value: []byte(m.Topic)},
+		kafka.Header{Key: "dlq-particion", Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: "dlq-offset", Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: "dlq-error", Value: []byte(causa.Error())},
+	)
+	return kafka.Message{Key: m.Key, Value: m.Value, Headers: headers}
+}
+
+// catalogoDesdeValkey carga el catálogo y lo mantiene actualizado en
+// segundo plano. Si la primera carga falla arranca vacío (ventas sin
+// enriquecer) y se reintenta en el próximo refresco.
+func catalogoDesdeValkey(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema) *catalogoProductos {
+	cat := nuevoCatalogo(rdb, ks)
+	if err := cat.cargar(ctx); err != nil {
+		slog.Warn("catálogo: carga inicial fallida", "err", err)
+	} else {
+		slog.Info("catálogo", "productos", len(cat.productos), "refresco", cat.refresco.String())
 	}
-	return nil
+	go cat.correr(ctx)
+	return cat
+}
+
+func correrMaterializador(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema, agg *agregador) {
+	lid := nuevoLider(rdb, ks.LockLider(), config.LeerDuracion("LEADER_LEASE", 10*time.Second))
+	mat := nuevoMaterializador(rdb, ks, agg, lid.id)
+	slog.Info("materializador", "intervalo", mat.intervalo.String(), "max_staleness", mat.maxStaleness.String(), "lease", lid.lease.String())
+	lid.correr(ctx, mat.intervalo, mat.materializar)
 }
 
-func updateBestWorstProduct(ctx context.Context, rdb *redis.Client, zkey, bestKey, worstKey string) error {
+func updateBestWorstProduct(ctx context.Context, rdb redis.UniversalClient, zkey, bestKey, worstKey string) error {
 	// más vendido
 	top, err := rdb.ZRevRangeWithScores(ctx, zkey, 0, 0).Result()
 	if err != nil {
@@ -267,9 +309,19 @@ func getenv(k, def string) string {
 	return def
 }
 
+func getenvBool(k string, def bool) bool {
+	if v := os.Getenv(k); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+		slog.Warn("variable inválida, uso el valor por defecto", "var", k, "valor", v, "defecto", def)
+	}
+	return def
+}
+
 func updateBestAvgPriceByCategory(
 	ctx context.Context,
-	rdb *redis.Client,
+	rdb redis.UniversalClient,
 	categoria string,
 	zkey string,
 	sumProdKey string,
@@ -336,6 +388,3 @@ func updateBestAvgPriceByCategory(
 	}
 	return nil
 }
-
-func itoa(v int) string     { return strconv.Itoa(v) }
-func itoa64(v int64) string { return strconv.FormatInt(v, 10) }
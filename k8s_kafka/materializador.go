@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// materializador recalcula los KPIs que dependen de leer y comparar (no se
+// pueden expresar como incrementos conmutativos): más/menos vendido, mejor
+// producto por categoría con su precio promedio y cantidad, promedios por
+// categoría, por tenant y global, descuento promedio, percentiles y stock
+// restante. El camino por evento solo incrementa; esto corre cada
+// MATERIALIZER_INTERVAL en la réplica líder.
+//
+// El atraso de los derivados queda acotado por intervalo + duración de la
+// corrida (+ LEADER_LEASE si el líder muere). Cada corrida deja su hora en
+// EstadoMaterializador; si al empezar la anterior tiene más de
+// MATERIALIZER_MAX_STALENESS, se avisa en el log.
+type materializador struct {
+	rdb          redis.UniversalClient
+	ks           keys.Schema
+	topkK        int // 0 en TOPK_MODE=exact
+	pct          *percentilesPrecio
+	intervalo    time.Duration
+	maxStaleness time.Duration
+	replica      string
+	ritmo        *ritmoInventario
+}
+
+func nuevoMaterializador(rdb redis.UniversalClient, ks keys.Schema, agg *agregador, replica string) *materializador {
+	intervalo := config.LeerDuracion("MATERIALIZER_INTERVAL", 2*time.Second)
+	return &materializador{
+		rdb:          rdb,
+		ks:           ks,
+		topkK:        agg.topkK,
+		pct:          agg.pct,
+		intervalo:    intervalo,
+		maxStaleness: config.LeerDuracion("MATERIALIZER_MAX_STALENESS", 5*intervalo),
+		replica:      replica,
+		ritmo:        nuevoRitmoInventario(),
+	}
+}
+
+// materializar hace una corrida completa y registra su estado.
+func (m *materializador) materializar(ctx context.Context) error {
+	inicio := time.Now()
+	estado := m.ks.EstadoMaterializador()
+
+	if prev, err := m.rdb.HGet(ctx, estado, "actualizado").Int64(); err == nil {
+		if edad := inicio.Sub(time.UnixMilli(prev)); edad > m.maxStaleness {
+			slog.Warn("materializador: derivados atrasados", "atraso", edad.Round(time.Millisecond).String(), "max", m.maxStaleness.String())
+		}
+	} else if err != redis.Nil {
+		return err
+	}
+
+	if err := m.recalcular(ctx); err != nil {
+		return err
+	}
+
+	dur := time.Since(inicio)
+	if dur > m.intervalo {
+		slog.Warn("materializador: corrida más larga que el intervalo", "dur", dur.Round(time.Millisecond).String(), "intervalo", m.intervalo.String())
+	}
+	return m.rdb.HSet(ctx, estado,
+		"actualizado", time.Now().UnixMilli(),
+		"duracion_ms", dur.Milliseconds(),
+		"intervalo_ms", m.intervalo.Milliseconds(),
+		"replica", m.replica,
+	).Err()
+}
+
+func (m *materializador) recalcular(ctx context.Context) error {
+	rdb, ks := m.rdb, m.ks
+
+	sumas, err := rdb.HGetAll(ctx, ks.SumPrecio()).Result()
+	if err != nil {
+		return err
+	}
+	conteos, err := rdb.HGetAll(ctx, ks.Conteo()).Result()
+	if err != nil {
+		return err
+	}
+	categorias := make([]string, 0, len(conteos))
+	for c := range conteos {
+		categorias = append(categorias, c)
+	}
+
+	// Promedio de precio por categoría y global
+	var sumTotal, cntTotal float64
+	for c, cntStr := range conteos {
+		cnt, _ := strconv.ParseFloat(cntStr, 64)
+		sum, _ := strconv.ParseFloat(sumas[c], 64)
+		if cnt <= 0 {
+			continue
+		}
+		sumTotal += sum
+		cntTotal += cnt
+		if err := rdb.HSet(ctx, ks.PromedioPorCategoria(), c, sum/cnt).Err(); err != nil {
+			return err
+		}
+	}
+	if cntTotal > 0 {
+		if err := rdb.Set(ctx, ks.PromedioGlobal(), fmt.Sprintf("%.2f", sumTotal/cntTotal), 0).Err(); err != nil {
+			return err
+		}
+	}
+
+	// Descuento promedio por categoría (ventas de productos del catálogo)
+	sumDesc, err := rdb.HGetAll(ctx, ks.DescuentoSuma()).Result()
+	if err != nil {
+		return err
+	}
+	cntDesc, err := rdb.HGetAll(ctx, ks.DescuentoConteo()).Result()
+	if err != nil {
+		return err
+	}
+	for c, cntStr := range cntDesc {
+		cnt, _ := strconv.ParseFloat(cntStr, 64)
+		sum, _ := strconv.ParseFloat(sumDesc[c], 64)
+		if cnt <= 0 {
+			continue
+		}
+		if err := rdb.HSet(ctx, ks.DescuentoPromedio(), c, fmt.Sprintf("%.2f", sum/cnt)).Err(); err != nil {
+			return err
+		}
+	}
+
+	// Promedio de precio por tenant
+	sumTenant, err := rdb.HGetAll(ctx, ks.SumPrecioPorTenant()).Result()
+	if err != nil {
+		return err
+	}
+	cntTenant, err := rdb.HGetAll(ctx, ks.ReportesPorTenant()).Result()
+	if err != nil {
+		return err
+	}
+	for t, cntStr := range cntTenant {
+		cnt, _ := strconv.ParseFloat(cntStr, 64)
+		sum, _ := strconv.ParseFloat(sumTenant[t], 64)
+		if cnt <= 0 {
+			continue
+		}
+		if err := rdb.HSet(ctx, ks.PromedioPorTenant(), t, fmt.Sprintf("%.2f", sum/cnt)).Err(); err != nil {
+			return err
+		}
+	}
+
+	// Producto más/menos vendido global y mejor producto por categoría
+	if m.topkK > 0 {
+		if err := m.recalcularTopK(ctx, categorias); err != nil {
+			return err
+		}
+	} else {
+		if err := updateBestWorstProduct(ctx, rdb, ks.ProductosVendidos(), ks.ProductoMasVendido(), ks.ProductoMenosVendido()); err != nil {
+			return err
+		}
+		for _, c := range categorias {
+			if err := updateBestAvgPriceByCategory(
+				ctx, rdb,
+				c,
+				ks.ProductosPorCategoria(c),
+				ks.SumPrecioPorProducto(c),
+				ks.CountPorProducto(c),
+				ks.BestProductoPorCategoria(),
+				ks.BestAvgPrecioPorCategoria(),
+				ks.BestQtyPorCategoria(),
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.publicarInventario(ctx); err != nil {
+		return err
+	}
+
+	// Percentiles
+	return m.pct.publicar(ctx, rdb, categorias)
+}
+
+// recalcularTopK recorta los ZSET del top-K a K miembros (cada partición
+// aporta los suyos) y publica el mejor producto y los productos distintos.
+func (m *materializador) recalcularTopK(ctx context.Context, categorias []string) error {
+	rdb, ks := m.rdb, m.ks
+	k := int64(m.topkK)
+
+	best, err := recortarTopK(ctx, rdb, ks.TopKProductos(), k)
+	if err != nil {
+		return err
+	}
+	if best != nil {
+		if err := rdb.Set(ctx, ks.ProductoMasVendido(), fmt.Sprintf("%v (%.0f)", best.Member, best.Score), 0).Err(); err != nil {
+			return err
+		}
+	}
+	total, err := rdb.PFCount(ctx, ks.ProductosDistintos()).Result()
+	if err != nil {
+		return err
+	}
+	if err := rdb.Set(ctx, ks.ProductosDistintosTotal(), total, 0).Err(); err != nil {
+		return err
+	}
+
+	for _, c := range categorias {
+		zkey, avgKey := ks.TopKPorCategoria(c), ks.TopKAvgPrecio(c)
+		best, err := recortarTopK(ctx, rdb, zkey, k)
+		if err != nil {
+			return err
+		}
+
+		// sacar del HASH de promedios a los que ya no están en el top-K
+		miembros, err := rdb.HKeys(ctx, avgKey).Result()
+		if err != nil {
+			return err
+		}
+		for _, id := range miembros {
+			if err := rdb.ZScore(ctx, zkey, id).Err(); err == redis.Nil {
+				rdb.HDel(ctx, avgKey, id)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if best != nil {
+			id := fmt.Sprint(best.Member)
+			avg, err := rdb.HGet(ctx, avgKey, id).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if _, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, ks.BestProductoPorCategoria(), c, id)
+				pipe.HSet(ctx, ks.BestQtyPorCategoria(), c, fmt.Sprintf("%.0f", best.Score))
+				if avg != "" {
+					pipe.HSet(ctx, ks.BestAvgPrecioPorCategoria(), c, avg)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		n, err := rdb.PFCount(ctx, ks.ProductosDistintosCategoria(c)).Result()
+		if err != nil {
+			return err
+		}
+		if err := rdb.HSet(ctx, ks.ProductosDistintosPorCategoria(), c, n).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recortarTopK deja solo los K de mayor score y devuelve el primero.
+func recortarTopK(ctx context.Context, rdb redis.UniversalClient, zkey string, k int64) (*redis.Z, error) {
+	if err := rdb.ZRemRangeByRank(ctx, zkey, 0, -(k + 1)).Err(); err != nil {
+		return nil, err
+	}
+	top, err := rdb.ZRevRangeWithScores(ctx, zkey, 0, 0).Result()
+	if err != nil || len(top) == 0 {
+		return nil, err
+	}
+	return &top[0], nil
+}
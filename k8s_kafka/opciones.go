@@ -0,0 +1,112 @@
+package main
+
+import (
+	"comun/config"
+	"comun/keys"
+	"comun/valkeycon"
+)
+
+// opciones es toda la configuración del consumer y sus modos (ver paquete
+// config: archivo, entorno o flags). Los flags van antes del subcomando:
+//
+//	k8s_kafka --config consumer.yaml --flush-max-mensajes 1000
+//	k8s_kafka --print-config avisos
+var opciones = []config.Opcion{
+	{Clave: "METRICS_ADDR", Tipo: config.Direccion, Defecto: ":9102", Desc: "dirección de /debug/vars"},
+
+	// Kafka
+	{Clave: "KAFKA_BROKERS", Tipo: config.Lista, Defecto: "kafka:9092", Desc: "brokers de Kafka"},
+	{Clave: "KAFKA_TOPIC", Defecto: "ventas", Desc: "topic de ventas"},
+	{Clave: "KAFKA_GROUP", Defecto: "ventas-consumer", Desc: "consumer group"},
+	{Clave: "KAFKA_MIN_BYTES", Tipo: config.Entero, Defecto: "1", Positivo: true, Desc: "bytes mínimos por fetch"},
+	{Clave: "KAFKA_MAX_BYTES", Tipo: config.Entero, Defecto: "1000000", Positivo: true, Desc: "bytes máximos por fetch"},
+	{Clave: "KAFKA_MAX_WAIT", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "espera máxima de un fetch hasta juntar KAFKA_MIN_BYTES"},
+	{Clave: "KAFKA_TIMEOUT", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "timeout de conexión y de las consultas de metadata y offsets"},
+	{Clave: "KAFKA_BATCH_SIZE", Tipo: config.Entero, Defecto: "100", Positivo: true, Desc: "mensajes por lote al publicar alertas"},
+	{Clave: "KAFKA_BATCH_TIMEOUT", Tipo: config.Duracion, Defecto: "1s", Positivo: true, Desc: "espera máxima para completar un lote de alertas"},
+	{Clave: "KAFKA_DLQ_TOPIC", Defecto: "ventas.dlq", Desc: "topic de los mensajes que no se pueden parsear"},
+	{Clave: "KAFKA_SASL_MECHANISM", Valores: []string{"PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512"}, Desc: "autenticación SASL (vacío: sin SASL)"},
+	{Clave: "KAFKA_SASL_USERNAME", Desc: "usuario SASL"},
+	{Clave: "KAFKA_SASL_PASSWORD", Secreto: true, Desc: "contraseña SASL"},
+	{Clave: "KAFKA_TLS", Tipo: config.Bool, Defecto: "false", Desc: "TLS hacia los brokers con las CA del sistema"},
+	{Clave: "KAFKA_TLS_CA", Desc: "CA PEM del cluster (activa TLS)"},
+	{Clave: "KAFKA_TLS_CERT", Desc: "certificado de cliente PEM (activa TLS)"},
+	{Clave: "KAFKA_TLS_KEY", Desc: "clave del certificado de cliente"},
+	{Clave: "KAFKA_TLS_SERVER_NAME", Desc: "nombre esperado en el certificado del broker"},
+	{Clave: "KAFKA_TLS_INSECURE", Tipo: config.Bool, Defecto: "false", Desc: "no verificar el certificado (solo pruebas)"},
+
+	// Topics deseados (modo admin)
+	{Clave: "TOPIC_PARTICIONES", Tipo: config.Entero, Defecto: "3", Positivo: true, Desc: "particiones de ventas y alertas"},
+	{Clave: "TOPIC_REPLICAS", Tipo: config.Entero, Defecto: "1", Positivo: true, Desc: "factor de replicación de todos los topics"},
+	{Clave: "TOPIC_RETENCION", Tipo: config.Duracion, Defecto: "168h", Desc: "retention.ms de ventas y alertas (0: la del broker)"},
+	{Clave: "DLQ_PARTICIONES", Tipo: config.Entero, Defecto: "1", Positivo: true, Desc: "particiones de la DLQ"},
+	{Clave: "DLQ_RETENCION", Tipo: config.Duracion, Defecto: "336h", Desc: "retention.ms de la DLQ (0: la del broker)"},
+
+	// Workers y flush
+	{Clave: "FLUSH_INTERVAL", Tipo: config.Duracion, Defecto: "1s", Positivo: true, Desc: "flush por partición cada este intervalo"},
+	{Clave: "FLUSH_MAX_MENSAJES", Tipo: config.Entero, Defecto: "500", Positivo: true, Desc: "flush al juntar estos mensajes"},
+	{Clave: "WORKER_QUEUE", Tipo: config.Entero, Defecto: "1000", Positivo: true, Desc: "cola de mensajes por worker"},
+
+	// Valkey
+	{Clave: "VALKEY_MODE", Defecto: "standalone", Valores: valkeycon.Modos, Desc: "topología de Valkey"},
+	{Clave: "VALKEY_ADDR", Tipo: config.Lista, Defecto: "valkey-primary:6379", Desc: "dirección de Valkey"},
+	{Clave: "VALKEY_ADDRS", Tipo: config.Lista, Desc: "direcciones (sentinels o nodos del cluster); reemplaza a VALKEY_ADDR"},
+	{Clave: "VALKEY_MASTER_NAME", Desc: "nombre del master en sentinel"},
+	{Clave: "VALKEY_DB", Tipo: config.Entero, Defecto: "0", Desc: "base de datos"},
+	{Clave: "VALKEY_USERNAME", Desc: "usuario ACL"},
+	{Clave: "VALKEY_PASSWORD", Secreto: true, Desc: "contraseña"},
+	{Clave: "VALKEY_SENTINEL_USERNAME", Desc: "usuario de los sentinels"},
+	{Clave: "VALKEY_SENTINEL_PASSWORD", Secreto: true, Desc: "contraseña de los sentinels"},
+	{Clave: "VALKEY_TLS", Tipo: config.Bool, Defecto: "false", Desc: "TLS hacia Valkey"},
+	{Clave: "VALKEY_TLS_CA", Desc: "CA PEM (activa TLS)"},
+	{Clave: "VALKEY_TLS_CERT", Desc: "certificado de cliente PEM (activa TLS)"},
+	{Clave: "VALKEY_TLS_KEY", Desc: "clave del certificado de cliente"},
+	{Clave: "VALKEY_TLS_SERVER_NAME", Desc: "nombre esperado en el certificado"},
+	{Clave: "VALKEY_TLS_INSECURE", Tipo: config.Bool, Defecto: "false", Desc: "no verificar el certificado (solo pruebas)"},
+	{Clave: "VALKEY_DIAL_TIMEOUT", Tipo: config.Duracion, Defecto: "5s", Positivo: true, Desc: "timeout de conexión"},
+	{Clave: "VALKEY_TIMEOUT", Tipo: config.Duracion, Defecto: "3s", Positivo: true, Desc: "timeout de lectura y escritura"},
+	{Clave: "VALKEY_KEY_PREFIX", Defecto: keys.DefaultPrefix, Desc: "prefijo de keys"},
+	{Clave: "VALKEY_HASH_TAG", Tipo: config.Bool, Defecto: "false", Desc: "envolver el prefijo en {} (forzado en cluster)"},
+
+	// Agregados y materializador
+	{Clave: "TOPK_MODE", Defecto: "exact", Valores: []string{"exact", "sketch"}, Desc: "ranking de productos exacto o aproximado (sketch: memoria acotada, sin serie de precio por producto)"},
+	{Clave: "TOPK_K", Tipo: config.Entero, Defecto: "10", Positivo: true, Desc: "productos del top-K aproximado"},
+	{Clave: "TOPK_EPSILON", Tipo: config.Decimal, Defecto: "0.0005", Positivo: true, Menor: "1", Desc: "error relativo del sketch"},
+	{Clave: "TOPK_DELTA", Tipo: config.Decimal, Defecto: "0.001", Positivo: true, Menor: "1", Desc: "probabilidad de exceder el error"},
+	{Clave: "PERCENTILES_ALPHA", Tipo: config.Decimal, Defecto: "0.01", Positivo: true, Menor: "1", Desc: "error relativo de los percentiles de precio"},
+	{Clave: "MATERIALIZER_ENABLED", Tipo: config.Bool, Defecto: "true", Desc: "correr el materializador en este proceso"},
+	{Clave: "MATERIALIZER_INTERVAL", Tipo: config.Duracion, Defecto: "2s", Positivo: true, Desc: "cada cuánto se recalculan los derivados"},
+	{Clave: "MATERIALIZER_MAX_STALENESS", Tipo: config.Duracion, Positivo: true, Desc: "atraso tolerado de los derivados (vacío: 5 intervalos)"},
+	{Clave: "LEADER_LEASE", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "duración del lock de líder (se renueva cada LEADER_LEASE/3)"},
+	{Clave: "CATALOGO_REFRESH", Tipo: config.Duracion, Defecto: "30s", Positivo: true, Desc: "cada cuánto se relee el catálogo"},
+	{Clave: "STOCK_RITMO_VENTANA", Tipo: config.Duracion, Defecto: "1m", Positivo: true, Desc: "ventana del ritmo de venta para estimar el agotamiento"},
+
+	// Eventos crudos
+	{Clave: "RAW_EVENTS_MODE", Defecto: "key", Valores: []string{"off", "key", "ttl", "stream", "compact"}, Desc: "retención de eventos crudos"},
+	{Clave: "RAW_EVENTS_TTL", Tipo: config.Duracion, Defecto: "24h", Positivo: true, Desc: "vida de los eventos crudos"},
+	{Clave: "RAW_EVENTS_MAXLEN", Tipo: config.Entero, Defecto: "100000", Positivo: true, Desc: "largo máximo del stream"},
+	{Clave: "RAW_EVENTS_COMPACT_INTERVAL", Tipo: config.Duracion, Defecto: "1m", Positivo: true, Desc: "cada cuánto se compacta"},
+	{Clave: "RAW_EVENTS_ARCHIVE_DIR", Defecto: "/data/eventos", Desc: "directorio del archivo compactado"},
+
+	// Sinks
+	{Clave: "SINKS", Tipo: config.Lista, Defecto: "valkey", Desc: "destinos: valkey, sql, archivo"},
+	{Clave: "SINKS_OPCIONALES", Tipo: config.Lista, Desc: "destinos cuyo fallo no frena el commit"},
+	{Clave: "SINK_ARCHIVO_DIR", Defecto: "/data/ventas", Desc: "directorio del sink archivo"},
+	{Clave: "SINK_SQL_DRIVER", Defecto: "sqlite", Valores: []string{"sqlite", "postgres"}, Desc: "base del sink sql"},
+	{Clave: "SINK_SQL_DSN", Secreto: true, Desc: "archivo SQLite o URL de Postgres"},
+	{Clave: "SINK_SQL_LOTE", Tipo: config.Entero, Defecto: "500", Positivo: true, Desc: "filas por INSERT"},
+
+	// Modos anomalias y avisos
+	{Clave: "ANOMALIAS_REGLAS", Desc: "JSON con las reglas de anomalías"},
+	{Clave: "ANOMALIAS_GROUP", Desc: "consumer group de anomalías (vacío: <KAFKA_GROUP>-anomalias)"},
+	{Clave: "ALERTAS_TOPIC", Defecto: "ventas.alertas", Desc: "topic de alertas"},
+	{Clave: "ALERTAS_MAX", Tipo: config.Entero, Defecto: "1000", Positivo: true, Desc: "alertas recientes guardadas en Valkey"},
+	{Clave: "AVISOS_CONFIG", Desc: "JSON con reglas y webhooks de avisos"},
+	{Clave: "AVISOS_HTTP_TIMEOUT", Tipo: config.Duracion, Defecto: "10s", Positivo: true, Desc: "timeout de cada webhook y consulta HTTP"},
+	{Clave: "REBUILD_IDLE_TIMEOUT", Tipo: config.Duracion, Defecto: "15s", Positivo: true, Desc: "sin mensajes por este tiempo, rebuild revisa si hay un hueco de offsets o falla sin swap"},
+
+	// Logs
+	{Clave: "LOG_LEVEL", Defecto: "info", Valores: []string{"debug", "info", "warn", "error"}, Desc: "nivel de log"},
+	{Clave: "LOG_FORMAT", Defecto: "json", Valores: []string{"json", "texto", "text"}, Desc: "formato de log"},
+	{Clave: "LOG_MUESTREO_OK", Tipo: config.Entero, Defecto: "100", Positivo: true, Desc: "se loguea 1 de cada N ventas OK"},
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/keys"
+)
+
+// Percentiles de precio (p50/p90/p99) global y por categoría.
+//
+// En cada flush de una partición se arma un DDSketch con los precios del
+// lote y se suma con HINCRBY sobre un HASH compartido en Valkey (un campo
+// por bucket), dentro de la misma transacción que el resto de agregados:
+// como los buckets solo se suman, el merge entre réplicas es conmutativo.
+// El líder lee el sketch mergeado y publica los percentiles.
+//
+// Con precisión relativa α, el valor reportado para cualquier cuantil está a
+// lo sumo a α·v del valor real v. Con α=0.01 un rango de precios de 0.01 a
+// 1e6 ocupa ~920 buckets. Todas las réplicas deben usar el mismo
+// PERCENTILES_ALPHA, porque el índice de bucket depende de él.
+
+// categoriaGlobal es la clave de todas las ventas en los mapas del lote. No
+// puede ser una categoría: una venta sin categoría se cuenta como
+// "Desconocida" (ver workers.go). En Valkey lo global va en keys propias
+// (SketchPrecioGlobal, PrecioP50Global, ...), no como un campo más de los
+// HASH por categoría.
+const categoriaGlobal = ""
+
+type ddSketch struct {
+	gamma    float64
+	logGamma float64
+	buckets  map[int]uint64
+	ceros    uint64 // precios <= 0
+	total    uint64
+}
+
+func nuevoDDSketch(alpha float64) *ddSketch {
+	gamma := (1 + alpha) / (1 - alpha)
+	return &ddSketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		buckets:  make(map[int]uint64),
+	}
+}
+
+func (s *ddSketch) indice(x float64) int {
+	return int(math.Ceil(math.Log(x) / s.logGamma))
+}
+
+// valor devuelve el punto medio (en escala relativa) del bucket i.
+func (s *ddSketch) valor(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+func (s *ddSketch) agregar(x float64) {
+	if x <= 0 {
+		s.ceros++
+	} else {
+		s.buckets[s.indice(x)]++
+	}
+	s.total++
+}
+
+// cuantil devuelve el valor aproximado del cuantil q (0..1).
+func (s *ddSketch) cuantil(q float64) float64 {
+	if s.total == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(s.total-1))
+	if rank < s.ceros {
+		return 0
+	}
+	idx := make([]int, 0, len(s.buckets))
+	for i := range s.buckets {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	acc := s.ceros
+	for _, i := range idx {
+		acc += s.buckets[i]
+		if acc > rank {
+			return s.valor(i)
+		}
+	}
+	return s.valor(idx[len(idx)-1])
+}
+
+// Formato en Valkey (HASH): "n" -> total, "z" -> ceros, "b<i>" -> conteo del bucket i.
+
+func (s *ddSketch) flushHIncr(ctx context.Context, pipe redis.Pipeliner, key string) {
+	pipe.HIncrBy(ctx, key, "n", int64(s.total))
+	if s.ceros > 0 {
+		pipe.HIncrBy(ctx, key, "z", int64(s.ceros))
+	}
+	for i, c := range s.buckets {
+		pipe.HIncrBy(ctx, key, "b"+strconv.Itoa(i), int64(c))
+	}
+}
+
+func ddSketchDesdeHash(alpha float64, h map[string]string) (*ddSketch, error) {
+	s := nuevoDDSketch(alpha)
+	for campo, val := range h {
+		n, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sketch campo %s: %w", campo, err)
+		}
+		switch {
+		case campo == "n":
+			s.total = n
+		case campo == "z":
+			s.ceros = n
+		case strings.HasPrefix(campo, "b"):
+			i, err := strconv.Atoi(campo[1:])
+			if err != nil {
+				return nil, fmt.Errorf("sketch campo %s: %w", campo, err)
+			}
+			s.buckets[i] = n
+		}
+	}
+	return s, nil
+}
+
+type percentilesPrecio struct {
+	alpha float64
+	ks    keys.Schema
+}
+
+func nuevoPercentilesPrecio(ks keys.Schema, alpha float64) (*percentilesPrecio, error) {
+	// con alpha fuera de (0, 1) gamma es <= 1 o negativo y los índices de
+	// bucket no tienen sentido
+	if !(alpha > 0 && alpha < 1) {
+		return nil, fmt.Errorf("PERCENTILES_ALPHA debe estar entre 0 y 1 (sin incluirlos): %v", alpha)
+	}
+	return &percentilesPrecio{alpha: alpha, ks: ks}, nil
+}
+
+// sketch es el HASH de buckets de una categoría o el global.
+func (p *percentilesPrecio) sketch(categoria string) string {
+	if categoria == categoriaGlobal {
+		return p.ks.SketchPrecioGlobal()
+	}
+	return p.ks.SketchPrecio(categoria)
+}
+
+// escribirDeltas encola en la transacción del flush los buckets del lote.
+func (p *percentilesPrecio) escribirDeltas(ctx context.Context, pipe redis.Pipeliner, ventas []Venta) {
+	lote := make(map[string]*ddSketch)
+	for _, v := range ventas {
+		for _, c := range []string{v.Categoria, categoriaGlobal} {
+			s, ok := lote[c]
+			if !ok {
+				s = nuevoDDSketch(p.alpha)
+				lote[c] = s
+			}
+			s.agregar(v.Precio)
+		}
+	}
+	for c, s := range lote {
+		s.flushHIncr(ctx, pipe, p.sketch(c))
+	}
+}
+
+// publicar calcula los percentiles desde los sketches mergeados (lo corre
+// el líder).
+func (p *percentilesPrecio) publicar(ctx context.Context, rdb redis.UniversalClient, categorias []string) error {
+	for _, c := range append(categorias, categoriaGlobal) {
+		h, err := rdb.HGetAll(ctx, p.sketch(c)).Result()
+		if err != nil {
+			return err
+		}
+		if len(h) == 0 {
+			continue
+		}
+		s, err := ddSketchDesdeHash(p.alpha, h)
+		if err != nil {
+			return err
+		}
+		p50, p90, p99 := fmt.Sprintf("%.2f", s.cuantil(0.50)), fmt.Sprintf("%.2f", s.cuantil(0.90)), fmt.Sprintf("%.2f", s.cuantil(0.99))
+		if _, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			if c == categoriaGlobal {
+				pipe.Set(ctx, p.ks.PrecioP50Global(), p50, 0)
+				pipe.Set(ctx, p.ks.PrecioP90Global(), p90, 0)
+				pipe.Set(ctx, p.ks.PrecioP99Global(), p99, 0)
+				return nil
+			}
+			pipe.HSet(ctx, p.ks.PrecioP50(), c, p50)
+			pipe.HSet(ctx, p.ks.PrecioP90(), c, p90)
+			pipe.HSet(ctx, p.ks.PrecioP99(), c, p99)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"comun/keys"
+)
+
+func TestPercentilesAlphaInvalido(t *testing.T) {
+	for _, alpha := range []float64{0, 1, -0.01, 1.5, math.NaN(), math.Inf(1)} {
+		if _, err := nuevoPercentilesPrecio(keys.New("venta", false), alpha); err == nil {
+			t.Errorf("alpha %v aceptado", alpha)
+		}
+	}
+}
+
+// Una categoría llamada "Global" no se mezcla con el total.
+func TestPercentilesCategoriaGlobal(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	ks := keys.New("venta", false)
+	p, err := nuevoPercentilesPrecio(ks, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ventas []Venta
+	for i := 0; i < 100; i++ {
+		ventas = append(ventas, Venta{Categoria: "Global", Precio: 10})
+		ventas = append(ventas, Venta{Categoria: "Electro", Precio: 1000})
+	}
+	if _, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		p.escribirDeltas(ctx, pipe, ventas)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.publicar(ctx, rdb, []string{"Global", "Electro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cerca := func(nombre, v string, esperado float64) {
+		t.Helper()
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.Abs(x-esperado) > 0.01*esperado+0.01 {
+			t.Errorf("%s = %q, esperaba %.2f ± 1%%", nombre, v, esperado)
+		}
+	}
+	cerca("p99 de la categoría Global", mr.HGet(ks.PrecioP99(), "Global"), 10)
+	cerca("p50 de Electro", mr.HGet(ks.PrecioP50(), "Electro"), 1000)
+	// el total tiene la mitad de ventas a 10 y la mitad a 1000
+	g, _ := mr.Get(ks.PrecioP99Global())
+	cerca("p99 global", g, 1000)
+	g, _ = mr.Get(ks.PrecioP50Global())
+	cerca("p50 global", g, 10)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// Modo rebuild: recalcula los agregados desde Kafka.
+//
+//	k8s_kafka rebuild [-desde-offset N | -desde-tiempo 2025-11-28T00:00:00Z] [-sin-swap]
+//
+// Lee cada partición desde su offset inicial (-desde-offset, -desde-tiempo o
+// el primero) sin consumer group y agrega en el namespace sombra
+// (<prefix>:rebuild:...). Solo si alcanzó el final de todas las particiones,
+// en una sola transacción MULTI borra los agregados vivos y renombra las keys
+// sombra encima; si una partición deja de avanzar termina con error y los
+// agregados vivos quedan como estaban.
+//
+// El consumer normal puede seguir corriendo mientras tanto, pero lo que
+// agregue entre que el rebuild alcanza el final y el swap se pierde; para un
+// resultado exacto conviene escalarlo a 0 durante el rebuild.
+func rebuild(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema, brokers []string, topic string, args []string) error {
+	fs := flag.NewFlagSet("rebuild", flag.ContinueOnError)
+	desdeOffset := fs.Int64("desde-offset", -1, "offset inicial en cada partición")
+	desdeTiempo := fs.String("desde-tiempo", "", "timestamp inicial RFC3339")
+	sinSwap := fs.Bool("sin-swap", false, "dejar los agregados en el namespace sombra")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *desdeOffset >= 0 && *desdeTiempo != "" {
+		return errors.New("usar -desde-offset o -desde-tiempo, no ambos")
+	}
+	var desde time.Time
+	if *desdeTiempo != "" {
+		t, err := time.Parse(time.RFC3339, *desdeTiempo)
+		if err != nil {
+			return fmt.Errorf("-desde-tiempo: %w", err)
+		}
+		desde = t
+	}
+
+	inicio, fin, err := rangoParticiones(ctx, brokers, topic, *desdeOffset, desde)
+	if err != nil {
+		return err
+	}
+
+	sombra := ks.Sombra()
+	if n, err := borrarPatrones(ctx, rdb, sombra.PatronesAgregados()); err != nil {
+		return err
+	} else if n > 0 {
+		slog.Info("rebuild: keys sombra previas borradas", "keys", n)
+	}
+
+	agg, err := nuevoAgregador(rdb, sombra)
+	if err != nil {
+		return err
+	}
+	agg.retencion = &retencionEventos{modo: "off"}
+
+	slog.Info("rebuild", "inicio", fmt.Sprint(inicio), "fin", fmt.Sprint(fin), "sombra", sombra.Prefix())
+
+	// Sin consumer group ni commit en Kafka: cada partición se lee con su
+	// propio reader desde su offset inicial y el offset aplicado queda en
+	// Valkey. Un grupo temporal no puede arrancar en un offset arbitrario por
+	// partición (solo el primero o el último) y dejaría offsets commiteados
+	// en el broker. Un flush fallido aborta el rebuild.
+	pool := nuevoPoolParticiones(ctx, agg, nil)
+	pool.abortar = true
+	pool.logOK = false
+	pool.enriquecer = catalogoDesdeValkey(ctx, rdb, ks).enriquecer // catálogo vivo, no sombra
+
+	pos, fin, procesados, err := ponerseAlDia(inicio, fin,
+		func(p int, desde, hasta int64) (int, error) {
+			return leerParticion(ctx, pool, brokers, topic, p, desde, hasta)
+		},
+		func() (map[int]int64, error) {
+			_, fin, err := rangoParticiones(ctx, brokers, topic, -1, time.Time{})
+			return fin, err
+		})
+	if err != nil {
+		pool.cerrar()
+		return err
+	}
+
+	if err := pool.cerrar(); err != nil {
+		return err
+	}
+	if err := nuevoMaterializador(rdb, sombra, agg, "rebuild").materializar(ctx); err != nil {
+		return fmt.Errorf("derivados: %w", err)
+	}
+	slog.Info("rebuild: al día", "mensajes", procesados)
+
+	// el swap reemplaza los agregados vivos: solo con todas las particiones
+	// leídas hasta el final
+	if !alDia(pos, fin) {
+		return fmt.Errorf("rebuild incompleto (pos %v, fin %v): sin swap", pos, fin)
+	}
+	if *sinSwap {
+		slog.Info("rebuild: -sin-swap, agregados en la sombra", "prefijo", sombra.Prefix())
+		return nil
+	}
+	n, err := intercambiarSombra(ctx, rdb, ks)
+	if err != nil {
+		return fmt.Errorf("swap: %w", err)
+	}
+	slog.Info("rebuild: swap listo", "keys", n)
+	return nil
+}
+
+// ponerseAlDia lee cada partición desde inicio hasta fin con leer y vuelve a
+// pedir los finales con rango hasta que una vuelta completa no encuentra nada
+// nuevo. Devuelve la posición alcanzada, los finales de la última consulta y
+// cuántos mensajes se agregaron; con error deja de leer.
+func ponerseAlDia(inicio, fin map[int]int64, leer func(p int, desde, hasta int64) (int, error), rango func() (map[int]int64, error)) (map[int]int64, map[int]int64, int, error) {
+	pos := maps.Clone(inicio)
+	procesados := 0
+	for !alDia(pos, fin) {
+		for _, p := range slices.Sorted(maps.Keys(fin)) {
+			if pos[p] >= fin[p] {
+				continue
+			}
+			n, err := leer(p, pos[p], fin[p])
+			procesados += n
+			if err != nil {
+				return pos, fin, procesados, err
+			}
+			pos[p] = fin[p]
+			slog.Info("rebuild: partición leída", "part", p, "mensajes", n, "total", procesados)
+		}
+		// revisar si llegó algo nuevo mientras leíamos
+		finNuevo, err := rango()
+		if err != nil {
+			return pos, fin, procesados, err
+		}
+		fin = finNuevo
+	}
+	return pos, fin, procesados, nil
+}
+
+func alDia(pos, fin map[int]int64) bool {
+	for p, f := range fin {
+		if pos[p] < f {
+			return false
+		}
+	}
+	return true
+}
+
+// leerParticion agrega los mensajes de la partición p en [desde, hasta).
+//
+// Si no llega nada en REBUILD_IDLE_TIMEOUT se mira qué hay en el broker en
+// ese offset (ver saltarHueco): un hueco sin mensajes (marcadores de
+// transacción, registros compactados) se saltea; cualquier otra cosa (broker
+// lento, rebalance del líder) es un error y el rebuild termina sin swap.
+func leerParticion(ctx context.Context, pool *poolParticiones, brokers []string, topic string, p int, desde, hasta int64) (int, error) {
+	rc := configLector(brokers, topic, "")
+	rc.Partition = p
+	reader := kafka.NewReader(rc)
+	defer reader.Close()
+	if err := reader.SetOffset(desde); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for pos := desde; pos < hasta; {
+		if err := pool.err(); err != nil {
+			return n, err
+		}
+		fctx, cancel := context.WithTimeout(ctx, config.LeerDuracion("REBUILD_IDLE_TIMEOUT", 15*time.Second))
+		m, err := reader.FetchMessage(fctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			sig, err := saltarHueco(ctx, brokers, topic, p, pos)
+			if err != nil {
+				return n, fmt.Errorf("partición %d en offset %d: %w", p, pos, err)
+			}
+			if sig <= pos {
+				return n, fmt.Errorf("partición %d sin mensajes nuevos en offset %d de %d", p, pos, hasta)
+			}
+			slog.Info("rebuild: hueco de offsets sin mensajes", "part", p, "desde", pos, "hasta", sig)
+			pos = sig
+			if pos < hasta {
+				if err := reader.SetOffset(pos); err != nil {
+					return n, err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("leyendo Kafka: %w", err)
+		}
+		pos = m.Offset + 1
+		if m.Offset >= hasta {
+			break // llegó después de fijar el final; se lee en la próxima vuelta
+		}
+		if err := pool.despachar(m); err != nil {
+			return n, err
+		}
+		n++
+		if n%10000 == 0 {
+			slog.Info("rebuild: progreso", "part", p, "mensajes", n, "pos", pos, "fin", hasta)
+		}
+	}
+	return n, nil
+}
+
+// saltarHueco lee directo del líder de la partición en el offset pos y
+// devuelve el siguiente offset con datos. Si el fetch trae mensajes, o no
+// trae nada, devuelve pos (no hay hueco que saltar); si solo trae registros
+// que no son mensajes (marcadores de transacción, compactados) el offset del
+// batch avanza igual y se devuelve ese.
+func saltarHueco(ctx context.Context, brokers []string, topic string, p int, pos int64) (int64, error) {
+	conn, err := segKafka.Dialer(timeoutKafka()).DialLeader(ctx, "tcp", brokers[0], topic, p)
+	if err != nil {
+		return pos, err
+	}
+	defer conn.Close()
+	if _, err := conn.Seek(pos, kafka.SeekAbsolute); err != nil {
+		return pos, err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeoutKafka()))
+	return siguienteConDatos(conn.ReadBatch(1, config.LeerEntero("KAFKA_MAX_BYTES", 1e6)), pos)
+}
+
+// batchKafka es la parte de *kafka.Batch que usa siguienteConDatos.
+type batchKafka interface {
+	ReadMessage() (kafka.Message, error)
+	Offset() int64
+	Close() error
+}
+
+// siguienteConDatos consume el batch leído en pos y decide hasta dónde
+// saltar (ver saltarHueco).
+func siguienteConDatos(batch batchKafka, pos int64) (int64, error) {
+	mensajes := 0
+	for {
+		if _, err := batch.ReadMessage(); err != nil {
+			break
+		}
+		mensajes++
+	}
+	sig := batch.Offset()
+	if err := batch.Close(); err != nil && !errors.Is(err, io.EOF) && !isTimeout(err) {
+		return pos, err
+	}
+	if mensajes > 0 {
+		return pos, nil
+	}
+	return max(sig, pos), nil
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// rangoParticiones devuelve, por partición, el offset desde el que se
+// agrega y el offset final actual (siguiente a escribir).
+func rangoParticiones(ctx context.Context, brokers []string, topic string, desdeOffset int64, desde time.Time) (inicio, fin map[int]int64, err error) {
+	d := segKafka.Dialer(timeoutKafka())
+	conn, err := d.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	parts, err := conn.ReadPartitions(topic)
+	conn.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("particiones de %s: %w", topic, err)
+	}
+
+	inicio = make(map[int]int64)
+	fin = make(map[int]int64)
+	for _, p := range parts {
+		lc, err := d.DialLeader(ctx, "tcp", brokers[0], topic, p.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("líder partición %d: %w", p.ID, err)
+		}
+		primero, ultimo, err := lc.ReadOffsets()
+		if err == nil && !desde.IsZero() {
+			var o int64
+			if o, err = lc.ReadOffset(desde); err == nil {
+				// -1: no hay mensajes posteriores a "desde"
+				primero = ultimo
+				if o >= 0 {
+					primero = o
+				}
+			}
+		}
+		lc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("offsets partición %d: %w", p.ID, err)
+		}
+		if desdeOffset > primero {
+			primero = desdeOffset
+		}
+		inicio[p.ID] = min(primero, ultimo)
+		fin[p.ID] = ultimo
+	}
+	return inicio, fin, nil
+}
+
+// intercambiarSombra reemplaza atómicamente los agregados vivos por los del
+// namespace sombra.
+func intercambiarSombra(ctx context.Context, rdb redis.UniversalClient, ks keys.Schema) (int, error) {
+	sombra := ks.Sombra()
+	nuevas, err := escanear(ctx, rdb, sombra.PatronesAgregados())
+	if err != nil {
+		return 0, err
+	}
+	if len(nuevas) == 0 {
+		return 0, errors.New("namespace sombra vacío, no reemplazo nada")
+	}
+	viejas, err := escanear(ctx, rdb, ks.PatronesAgregados())
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(viejas) > 0 {
+			pipe.Del(ctx, viejas...)
+		}
+		for _, k := range nuevas {
+			pipe.Rename(ctx, k, ks.Prefix()+strings.TrimPrefix(k, sombra.Prefix()))
+		}
+		return nil
+	})
+	return len(nuevas), err
+}
+
+func borrarPatrones(ctx context.Context, rdb redis.UniversalClient, patrones []string) (int, error) {
+	ks, err := escanear(ctx, rdb, patrones)
+	if err != nil || len(ks) == 0 {
+		return 0, err
+	}
+	for i := 0; i < len(ks); i += 500 {
+		if err := rdb.Del(ctx, ks[i:min(i+500, len(ks))]...).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return len(ks), nil
+}
+
+// escanear recorre con SCAN todos los primarios (en Cluster cada nodo
+// tiene su propio keyspace).
+func escanear(ctx context.Context, rdb redis.UniversalClient, patrones []string) ([]string, error) {
+	var (
+		mu  sync.Mutex
+		out []string
+	)
+	scan := func(ctx context.Context, c redis.Cmdable) error {
+		for _, pat := range patrones {
+			it := c.Scan(ctx, 0, pat, 1000).Iterator()
+			for it.Next(ctx) {
+				mu.Lock()
+				out = append(out, it.Val())
+				mu.Unlock()
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if cc, ok := rdb.(*redis.ClusterClient); ok {
+		err := cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return scan(ctx, c)
+		})
+		return out, err
+	}
+	return out, scan(ctx, rdb)
+}
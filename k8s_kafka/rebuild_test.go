@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/keys"
+)
+
+func TestIntercambiarSombra(t *testing.T) {
+	for _, hashTag := range []bool{false, true} {
+		ks := keys.New("venta", hashTag)
+		t.Run(ks.Prefix(), func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+			ctx := context.Background()
+			vivo, sombra := ks.Prefix(), ks.Sombra().Prefix()
+
+			mr.Set(vivo+":stats:Ropa", "viejo")
+			mr.Set(vivo+":stats:Hogar", "solo-vivo")
+			mr.Set(ks.OffsetAplicado("ventas", 0), "7")
+			mr.HSet(ks.Catalogo(), "p1", "{}") // no es un agregado
+			mr.Set(sombra+":stats:Ropa", "nuevo")
+			mr.Set(sombra+":ts:Ropa", "serie")
+			mr.Set(ks.Sombra().OffsetAplicado("ventas", 0), "42")
+
+			n, err := intercambiarSombra(ctx, rdb, ks)
+			if err != nil || n != 3 {
+				t.Fatalf("swap: %d keys, %v; esperaba 3", n, err)
+			}
+			quiero := map[string]string{
+				vivo + ":stats:Ropa":                    "nuevo",
+				vivo + ":ts:Ropa":                       "serie",
+				ks.OffsetAplicado("ventas", 0):          "42",
+				vivo + ":stats:Hogar":                   "",
+				sombra + ":stats:Ropa":                  "",
+				ks.Sombra().OffsetAplicado("ventas", 0): "",
+			}
+			for k, v := range quiero {
+				if got, _ := mr.Get(k); got != v {
+					t.Errorf("%s = %q, quiero %q", k, got, v)
+				}
+			}
+			if mr.HGet(ks.Catalogo(), "p1") != "{}" {
+				t.Error("el swap borró el catálogo")
+			}
+
+			// con la sombra ya vacía no se toca lo vivo
+			if _, err := intercambiarSombra(ctx, rdb, ks); err == nil {
+				t.Fatal("swap con la sombra vacía aceptado")
+			}
+			if got, _ := mr.Get(vivo + ":stats:Ropa"); got != "nuevo" {
+				t.Fatalf("un swap fallido cambió los agregados vivos: %q", got)
+			}
+		})
+	}
+}
+
+type lectura struct {
+	p            int
+	desde, hasta int64
+}
+
+func TestPonerseAlDia(t *testing.T) {
+	errKafka := errors.New("broker caído")
+	casos := []struct {
+		nombre     string
+		inicio     map[int]int64
+		fines      []map[int]int64 // fin inicial y lo que devuelve cada consulta
+		fallaEn    int             // partición cuya lectura falla; -1 ninguna
+		lecturas   []lectura
+		pos        map[int]int64
+		procesados int
+		err        error
+	}{
+		{
+			nombre: "ya al día", inicio: map[int]int64{0: 5, 1: 3},
+			fines: []map[int]int64{{0: 5, 1: 3}}, fallaEn: -1,
+			pos: map[int]int64{0: 5, 1: 3},
+		},
+		{
+			nombre: "una vuelta", inicio: map[int]int64{0: 0, 1: 5},
+			fines: []map[int]int64{{0: 10, 1: 5}, {0: 10, 1: 5}}, fallaEn: -1,
+			lecturas: []lectura{{0, 0, 10}},
+			pos:      map[int]int64{0: 10, 1: 5}, procesados: 10,
+		},
+		{
+			nombre: "llegan mensajes mientras lee", inicio: map[int]int64{0: 0, 1: 0},
+			fines:    []map[int]int64{{0: 10, 1: 4}, {0: 12, 1: 4}, {0: 12, 1: 7}, {0: 12, 1: 7}},
+			fallaEn:  -1,
+			lecturas: []lectura{{0, 0, 10}, {1, 0, 4}, {0, 10, 12}, {1, 4, 7}},
+			pos:      map[int]int64{0: 12, 1: 7}, procesados: 19,
+		},
+		{
+			nombre: "falla la lectura", inicio: map[int]int64{0: 0, 1: 0},
+			fines: []map[int]int64{{0: 10, 1: 10}}, fallaEn: 1,
+			lecturas: []lectura{{0, 0, 10}, {1, 0, 10}},
+			pos:      map[int]int64{0: 10, 1: 0}, procesados: 12, err: errKafka,
+		},
+		{
+			nombre: "falla la consulta de finales", inicio: map[int]int64{0: 0},
+			fines: []map[int]int64{{0: 3}}, fallaEn: -1,
+			lecturas: []lectura{{0, 0, 3}},
+			pos:      map[int]int64{0: 3}, procesados: 3, err: errKafka,
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			var lecturas []lectura
+			leer := func(p int, desde, hasta int64) (int, error) {
+				lecturas = append(lecturas, lectura{p, desde, hasta})
+				if p == c.fallaEn {
+					return 2, errKafka // leyó algo antes de fallar
+				}
+				return int(hasta - desde), nil
+			}
+			consultas := 1
+			rango := func() (map[int]int64, error) {
+				if consultas >= len(c.fines) {
+					return nil, errKafka
+				}
+				consultas++
+				return c.fines[consultas-1], nil
+			}
+
+			inicio := maps.Clone(c.inicio)
+			pos, _, procesados, err := ponerseAlDia(c.inicio, c.fines[0], leer, rango)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("err %v, esperaba %v", err, c.err)
+			}
+			if !slices.Equal(lecturas, c.lecturas) {
+				t.Errorf("lecturas %v, quiero %v", lecturas, c.lecturas)
+			}
+			if !maps.Equal(pos, c.pos) || procesados != c.procesados {
+				t.Errorf("pos %v con %d procesados, quiero %v y %d", pos, procesados, c.pos, c.procesados)
+			}
+			if !maps.Equal(c.inicio, inicio) {
+				t.Errorf("ponerseAlDia modificó el inicio: %v", c.inicio)
+			}
+		})
+	}
+}
+
+// batchPrueba devuelve mensajes mensajes y después io.EOF; offset es lo que
+// el broker informa como siguiente offset del batch.
+type batchPrueba struct {
+	mensajes  int
+	offset    int64
+	errCierre error
+}
+
+func (b *batchPrueba) ReadMessage() (kafka.Message, error) {
+	if b.mensajes == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	b.mensajes--
+	return kafka.Message{}, nil
+}
+
+func (b *batchPrueba) Offset() int64 { return b.offset }
+func (b *batchPrueba) Close() error  { return b.errCierre }
+
+func TestSiguienteConDatos(t *testing.T) {
+	casos := []struct {
+		nombre string
+		batch  batchPrueba
+		sig    int64
+		error  bool
+	}{
+		{"con mensajes no hay hueco", batchPrueba{mensajes: 3, offset: 13}, 10, false},
+		{"vacío sin avanzar", batchPrueba{offset: 10}, 10, false},
+		{"solo marcadores de transacción", batchPrueba{offset: 14}, 14, false},
+		{"offset anterior a pos", batchPrueba{offset: 4}, 10, false},
+		{"cierre con EOF", batchPrueba{offset: 12, errCierre: io.EOF}, 12, false},
+		{"cierre por timeout de lectura", batchPrueba{offset: 12, errCierre: os.ErrDeadlineExceeded}, 12, false},
+		{"cierre con error", batchPrueba{offset: 12, errCierre: errors.New("conexión cortada")}, 10, true},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			sig, err := siguienteConDatos(&c.batch, 10)
+			if (err != nil) != c.error || sig != c.sig {
+				t.Fatalf("siguienteConDatos = %d, %v; quiero %d (error %v)", sig, err, c.sig, c.error)
+			}
+		})
+	}
+}
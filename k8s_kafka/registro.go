@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Logs en JSON con log/slog, una línea por evento:
+//
+//	LOG_LEVEL        debug, info (por defecto), warn o error
+//	LOG_FORMAT       json (por defecto) o texto
+//	LOG_MUESTREO_OK  se loguea 1 de cada N ventas OK (por defecto 100; 1 todas)
+//
+// Cada línea lleva el modo ("servicio": consumer, anomalias, avisos,
+// rebuild...) y las líneas de un mensaje llevan su request ID ("rid"), que
+// el gRPC server manda en el header x-request-id del mensaje de Kafka.
+
+// headerRequestID es el header de Kafka con el request ID del gateway.
+const headerRequestID = "x-request-id"
+
+func configurarLogs(servicio string) error {
+	var nivel slog.Level
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := nivel.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("LOG_LEVEL: %q, se espera debug, info, warn o error", v)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: nivel}
+
+	var h slog.Handler
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "", "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	case "texto", "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT: %q, se espera json o texto", os.Getenv("LOG_FORMAT"))
+	}
+	slog.SetDefault(slog.New(h).With("servicio", servicio))
+
+	if v := os.Getenv("LOG_MUESTREO_OK"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("LOG_MUESTREO_OK: %q, se espera un entero >= 1", v)
+		}
+		muestreoOK.Store(uint64(n))
+	}
+	return nil
+}
+
+var muestreoOK atomic.Uint64
+
+func init() { muestreoOK.Store(100) }
+
+// muestreo deja pasar una de cada LOG_MUESTREO_OK llamadas a toca.
+type muestreo struct {
+	n atomic.Uint64
+}
+
+func (m *muestreo) toca() bool {
+	return (m.n.Add(1)-1)%muestreoOK.Load() == 0
+}
+
+// requestID devuelve el request ID del mensaje, vacío si no lo trae
+// (mensajes anteriores o producidos por otra herramienta).
+func requestID(m kafka.Message) string {
+	for _, h := range m.Headers {
+		if h.Key == headerRequestID {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+	"comun/keys"
+)
+
+// Retención de eventos crudos (RAW_EVENTS_MODE):
+//
+//	key      una key por mensaje sin TTL (comportamiento original)
+//	off      no se guarda el evento crudo
+//	ttl      una key por mensaje con RAW_EVENTS_TTL
+//	stream   XADD a un Stream con MAXLEN ~RAW_EVENTS_MAXLEN
+//	compact  XADD al Stream; cada RAW_EVENTS_COMPACT_INTERVAL las entradas
+//	         de horas ya cerradas se pasan a archivos por hora en
+//	         RAW_EVENTS_ARCHIVE_DIR (<topic>-AAAAMMDD-HH.jsonl) y se recortan
+//	         del Stream con XTRIM MINID
+//
+// En modo compact el directorio debe ser un volumen persistente.
+type retencionEventos struct {
+	modo      string
+	ttl       time.Duration
+	maxLen    int64
+	intervalo time.Duration
+	dir       string
+	ks        keys.Schema
+}
+
+func retencionDesdeEnv(ks keys.Schema) (*retencionEventos, error) {
+	r := &retencionEventos{
+		modo:      getenv("RAW_EVENTS_MODE", "key"),
+		ttl:       config.LeerDuracion("RAW_EVENTS_TTL", 24*time.Hour),
+		maxLen:    int64(config.LeerEntero("RAW_EVENTS_MAXLEN", 100000)),
+		intervalo: config.LeerDuracion("RAW_EVENTS_COMPACT_INTERVAL", time.Minute),
+		dir:       getenv("RAW_EVENTS_ARCHIVE_DIR", "/data/eventos"),
+		ks:        ks,
+	}
+	switch r.modo {
+	case "key", "off", "stream":
+	case "ttl":
+		if r.ttl <= 0 {
+			return nil, fmt.Errorf("RAW_EVENTS_TTL debe ser > 0")
+		}
+	case "compact":
+		if err := os.MkdirAll(r.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("RAW_EVENTS_ARCHIVE_DIR: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("RAW_EVENTS_MODE inválido: %q (key|off|ttl|stream|compact)", r.modo)
+	}
+	return r, nil
+}
+
+func (r *retencionEventos) String() string {
+	switch r.modo {
+	case "ttl":
+		return "ttl=" + r.ttl.String()
+	case "stream":
+		return "stream maxlen~" + strconv.FormatInt(r.maxLen, 10)
+	case "compact":
+		return "compact dir=" + r.dir + " cada " + r.intervalo.String()
+	}
+	return r.modo
+}
+
+// guardar persiste el mensaje crudo según el modo.
+func (r *retencionEventos) guardar(ctx context.Context, rdb redis.Cmdable, m kafka.Message) error {
+	switch r.modo {
+	case "off":
+		return nil
+	case "key":
+		return rdb.Set(ctx, r.ks.Evento(m.Topic, m.Partition, m.Offset), string(m.Value), 0).Err()
+	case "ttl":
+		return rdb.Set(ctx, r.ks.Evento(m.Topic, m.Partition, m.Offset), string(m.Value), r.ttl).Err()
+	}
+
+	args := &redis.XAddArgs{
+		Stream: r.ks.EventosStream(m.Topic),
+		Values: map[string]any{
+			"partition": m.Partition,
+			"offset":    m.Offset,
+			"value":     string(m.Value),
+		},
+	}
+	// En compact el Stream lo recorta la compactación, no MAXLEN.
+	if r.modo == "stream" {
+		args.MaxLen = r.maxLen
+		args.Approx = true
+	}
+	return rdb.XAdd(ctx, args).Err()
+}
+
+// compactar corre en background hasta que se cancele ctx.
+func (r *retencionEventos) compactar(ctx context.Context, rdb redis.UniversalClient, topic string) {
+	if r.modo != "compact" {
+		return
+	}
+	t := time.NewTicker(r.intervalo)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := r.compactarUnaVez(ctx, rdb, topic)
+			if err != nil {
+				slog.Error("compactación", "err", err)
+			} else if n > 0 {
+				slog.Info("compactación", "archivados", n, "dir", r.dir)
+			}
+		}
+	}
+}
+
+type eventoArchivado struct {
+	ID        string `json:"id"`
+	Partition string `json:"partition"`
+	Offset    string `json:"offset"`
+	Value     string `json:"value"`
+}
+
+const loteCompactacion = 1000
+
+// compactarUnaVez archiva las entradas anteriores a la hora actual y las
+// recorta del Stream. Si falla a medias se puede repetir: en el peor caso
+// algunas entradas quedan duplicadas en el archivo, nunca perdidas.
+func (r *retencionEventos) compactarUnaVez(ctx context.Context, rdb redis.UniversalClient, topic string) (int, error) {
+	// el lock expira a los r.intervalo: si la corrida tarda más, otra réplica
+	// puede tomarlo, y esta solo debe borrar el lock si sigue siendo suyo
+	lock, token := r.ks.LockCompactacion(topic), tokenLock()
+	ok, err := rdb.SetNX(ctx, lock, token, r.intervalo).Result()
+	if err != nil || !ok {
+		return 0, err
+	}
+	defer func() {
+		if err := scriptSoltarLider.Run(context.WithoutCancel(ctx), rdb, []string{lock}, token).Err(); err != nil {
+			slog.Error("compactación: error soltando lock", "key", lock, "err", err)
+		}
+	}()
+
+	stream := r.ks.EventosStream(topic)
+	corte := time.Now().Truncate(time.Hour).UnixMilli()
+	hasta := "(" + strconv.FormatInt(corte, 10) + "-0"
+
+	total := 0
+	desde := "-"
+	for {
+		entradas, err := rdb.XRangeN(ctx, stream, desde, hasta, loteCompactacion).Result()
+		if err != nil {
+			return total, err
+		}
+		if len(entradas) == 0 {
+			break
+		}
+		if err := r.archivar(topic, entradas); err != nil {
+			return total, err
+		}
+		total += len(entradas)
+		desde = "(" + entradas[len(entradas)-1].ID
+		if len(entradas) < loteCompactacion {
+			break
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return total, rdb.XTrimMinID(ctx, stream, strconv.FormatInt(corte, 10)+"-0").Err()
+}
+
+// archivar agrupa las entradas por hora (según el ms del ID) y las agrega
+// al archivo correspondiente.
+func (r *retencionEventos) archivar(topic string, entradas []redis.XMessage) error {
+	porHora := make(map[string][]eventoArchivado)
+	for _, e := range entradas {
+		msStr, _, _ := strings.Cut(e.ID, "-")
+		ms, err := strconv.ParseInt(msStr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ID de stream inválido %q: %w", e.ID, err)
+		}
+		hora := time.UnixMilli(ms).UTC().Format("20060102-15")
+		porHora[hora] = append(porHora[hora], eventoArchivado{
+			ID:        e.ID,
+			Partition: fmt.Sprint(e.Values["partition"]),
+			Offset:    fmt.Sprint(e.Values["offset"]),
+			Value:     fmt.Sprint(e.Values["value"]),
+		})
+	}
+
+	for hora, evs := range porHora {
+		path := filepath.Join(r.dir, fmt.Sprintf("%s-%s.jsonl", topic, hora))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		for _, ev := range evs {
+			if err := enc.Encode(ev); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"comun/keys"
+)
+
+func TestCompactacionLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	r := &retencionEventos{modo: "compact", intervalo: time.Minute, dir: t.TempDir(), ks: keys.New("test", false)}
+	lock, stream := r.ks.LockCompactacion("ventas"), r.ks.EventosStream("ventas")
+
+	// una entrada de una hora ya cerrada (1970-01-01 00:00:01)
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: stream, ID: "1000-0", Values: map[string]any{"partition": 0, "offset": 7, "value": "{}"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// otra réplica tiene el lock: no se compacta y el lock sigue siendo suyo
+	mr.Set(lock, "otra")
+	if n, err := r.compactarUnaVez(ctx, rdb, "ventas"); n != 0 || err != nil {
+		t.Fatalf("con el lock tomado: n=%d err=%v", n, err)
+	}
+	if v, _ := mr.Get(lock); v != "otra" {
+		t.Fatalf("lock ajeno pisado: %q", v)
+	}
+
+	mr.Del(lock)
+	if n, err := r.compactarUnaVez(ctx, rdb, "ventas"); n != 1 || err != nil {
+		t.Fatalf("compactar: n=%d err=%v", n, err)
+	}
+	if mr.Exists(lock) {
+		t.Error("el lock propio quedó tomado después de compactar")
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, "ventas-19700101-00.jsonl")); err != nil {
+		t.Errorf("archivo de la hora: %v", err)
+	}
+	if n, _ := rdb.XLen(ctx, stream).Result(); n != 0 {
+		t.Errorf("quedaron %d entradas en el Stream", n)
+	}
+}
+
+// Si el lock expiró durante una corrida larga y lo tomó otra réplica, soltar
+// con el token propio no lo borra.
+func TestSoltarLockAjeno(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	propio, ajeno := tokenLock(), tokenLock()
+	if propio == ajeno {
+		t.Fatalf("tokenLock repitió %q", propio)
+	}
+	mr.Set("lock", ajeno)
+	if n, err := scriptSoltarLider.Run(ctx, rdb, []string{"lock"}, propio).Int64(); n != 0 || err != nil {
+		t.Fatalf("soltar lock ajeno: n=%d err=%v", n, err)
+	}
+	if v, _ := mr.Get("lock"); v != ajeno {
+		t.Fatalf("lock ajeno borrado: %q", v)
+	}
+	if n, err := scriptSoltarLider.Run(ctx, rdb, []string{"lock"}, ajeno).Int64(); n != 1 || err != nil {
+		t.Fatalf("soltar lock propio: n=%d err=%v", n, err)
+	}
+}
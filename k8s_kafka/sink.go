@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Sink es un destino de las ventas consumidas. El pool de workers le pasa
+// en cada flush el lote de una partición, en orden, y confirma el offset en
+// Kafka solo si escribir no devuelve error.
+//
+// escribir puede recibir mensajes ya escritos (reintentos, rebalances,
+// reinicios): cada implementación decide cómo deduplicar. El fan-out ya
+// evita reenviar un lote a los sinks que lo escribieron bien.
+type Sink interface {
+	nombre() string
+	escribir(ctx context.Context, l lote) error
+	cerrar() error
+}
+
+type ventaPendiente struct {
+	m  kafka.Message
+	v  Venta
+	ok bool // JSON válido
+}
+
+// lote son los mensajes de una partición desde el último flush.
+type lote struct {
+	topic     string
+	particion int
+	mensajes  []ventaPendiente
+}
+
+func (l lote) ultimo() kafka.Message { return l.mensajes[len(l.mensajes)-1].m }
+
+// desde devuelve el sub-lote con offset > offset.
+func (l lote) desde(offset int64) lote {
+	i := 0
+	for i < len(l.mensajes) && l.mensajes[i].m.Offset <= offset {
+		i++
+	}
+	l.mensajes = l.mensajes[i:]
+	return l
+}
+
+// sinksDesdeEnv arma los sinks de SINKS (lista separada por comas, por
+// defecto "valkey"). Los que estén en SINKS_OPCIONALES no frenan el commit
+// si fallan: el error se loguea y se cuenta, y ese lote se pierde para ese
+// sink. Con más de un sink se escriben en paralelo (fan-out).
+func sinksDesdeEnv(agg *agregador) (Sink, error) {
+	opcionales := make(map[string]bool)
+	for _, n := range strings.Split(getenv("SINKS_OPCIONALES", ""), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			opcionales[n] = true
+		}
+	}
+
+	var sinks []sinkConfigurado
+	for _, n := range strings.Split(getenv("SINKS", "valkey"), ",") {
+		var (
+			s   Sink
+			err error
+		)
+		switch n = strings.TrimSpace(n); n {
+		case "":
+			continue
+		case "valkey":
+			s = agg
+		case "sql":
+			s, err = sinkSQLDesdeEnv()
+		case "archivo":
+			s, err = sinkArchivoDesdeEnv()
+		default:
+			err = fmt.Errorf("SINKS: sink desconocido %q (valkey|sql|archivo)", n)
+		}
+		if err != nil {
+			for _, sc := range sinks {
+				sc.cerrar()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sinkConfigurado{Sink: medir(s), opcional: opcionales[n]})
+		slog.Info("sink", "nombre", n, "opcional", opcionales[n])
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("SINKS vacío")
+	}
+	if len(sinks) == 1 && !sinks[0].opcional {
+		return sinks[0].Sink, nil
+	}
+	return nuevoFanOut(sinks), nil
+}
+
+// ===== Fan-out =====
+
+type sinkConfigurado struct {
+	Sink
+	opcional bool
+}
+
+type sinkFanOut struct {
+	sinks []sinkConfigurado
+
+	mu    sync.Mutex
+	hecho []map[int]int64 // por sink: partición -> último offset escrito
+}
+
+func nuevoFanOut(sinks []sinkConfigurado) *sinkFanOut {
+	f := &sinkFanOut{sinks: sinks, hecho: make([]map[int]int64, len(sinks))}
+	for i := range f.hecho {
+		f.hecho[i] = make(map[int]int64)
+	}
+	return f
+}
+
+func (f *sinkFanOut) nombre() string {
+	nombres := make([]string, len(f.sinks))
+	for i, s := range f.sinks {
+		nombres[i] = s.nombre()
+	}
+	return "fanout(" + strings.Join(nombres, ",") + ")"
+}
+
+// escribir manda el lote a todos los sinks en paralelo. Devuelve error solo
+// si falló alguno obligatorio; en el reintento, los que ya lo escribieron
+// no lo reciben de nuevo.
+func (f *sinkFanOut) escribir(ctx context.Context, l lote) error {
+	errs := make([]error, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		f.mu.Lock()
+		hecho, ok := f.hecho[i][l.particion]
+		f.mu.Unlock()
+		sub := l
+		if ok {
+			sub = l.desde(hecho)
+		}
+		if len(sub.mensajes) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.escribir(ctx, sub)
+			if err != nil && s.opcional {
+				slog.Warn("sink opcional descarta lote", "sink", s.nombre(), "part", l.particion, "mensajes", len(l.mensajes), "err", err)
+				err = nil
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("sink %s: %w", s.nombre(), err)
+				return
+			}
+			f.mu.Lock()
+			f.hecho[i][l.particion] = sub.ultimo().Offset
+			f.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (f *sinkFanOut) cerrar() error {
+	var errs []error
+	for _, s := range f.sinks {
+		errs = append(errs, s.cerrar())
+	}
+	return errors.Join(errs...)
+}
+
+// ===== Métricas =====
+
+// Métricas por sink en expvar ("sinks"), publicadas en /debug/vars si
+// METRICS_ADDR no está vacío:
+//
+//	lotes, mensajes, errores, latencia_ms (acumulada), ultimo_error
+var metricasSinks = expvar.NewMap("sinks")
+
+type sinkMedido struct {
+	Sink
+	m           *expvar.Map
+	ultimoError expvar.String
+}
+
+func medir(s Sink) Sink {
+	sm := &sinkMedido{Sink: s, m: new(expvar.Map).Init()}
+	sm.m.Set("ultimo_error", &sm.ultimoError)
+	metricasSinks.Set(s.nombre(), sm.m)
+	return sm
+}
+
+func (s *sinkMedido) escribir(ctx context.Context, l lote) error {
+	inicio := time.Now()
+	err := s.Sink.escribir(ctx, l)
+	s.m.Add("latencia_ms", time.Since(inicio).Milliseconds())
+	s.m.Add("lotes", 1)
+	if err != nil {
+		s.m.Add("errores", 1)
+		s.ultimoError.Set(err.Error())
+		return err
+	}
+	s.m.Add("mensajes", int64(len(l.mensajes)))
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sinkArchivo escribe cada venta válida como una fila CSV en
+// SINK_ARCHIVO_DIR/<topic>-AAAAMMDD-HH.csv según la hora de la venta. Es
+// at-least-once: después de un reinicio o rebalance un lote ya escrito puede
+// repetirse; las columnas topic/particion/offset permiten deduplicar al leer.
+type sinkArchivo struct {
+	dir string
+	mu  sync.Mutex // varias particiones pueden caer en el mismo archivo
+}
+
+var columnasArchivo = []string{"topic", "particion", "offset", "timestamp", "categoria", "producto_id", "precio", "cantidad"}
+
+func sinkArchivoDesdeEnv() (*sinkArchivo, error) {
+	s := &sinkArchivo{dir: getenv("SINK_ARCHIVO_DIR", "/data/ventas")}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("SINK_ARCHIVO_DIR: %w", err)
+	}
+	return s, nil
+}
+
+func (s *sinkArchivo) nombre() string { return "archivo" }
+
+func (s *sinkArchivo) cerrar() error { return nil }
+
+func (s *sinkArchivo) escribir(ctx context.Context, l lote) error {
+	porHora := make(map[string][][]string)
+	for _, vp := range l.mensajes {
+		if !vp.ok {
+			continue
+		}
+		cuando := horaVenta(vp)
+		hora := cuando.UTC().Format("20060102-15")
+		porHora[hora] = append(porHora[hora], []string{
+			l.topic,
+			strconv.Itoa(l.particion),
+			strconv.FormatInt(vp.m.Offset, 10),
+			cuando.UTC().Format(time.RFC3339Nano),
+			vp.v.Categoria,
+			vp.v.ProductoID,
+			strconv.FormatFloat(vp.v.Precio, 'f', 2, 64),
+			strconv.Itoa(vp.v.CantidadVendida),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hora, filas := range porHora {
+		path := filepath.Join(s.dir, fmt.Sprintf("%s-%s.csv", l.topic, hora))
+		if err := anexarCSV(path, filas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// anexarCSV agrega filas al archivo, con encabezado si es nuevo.
+func anexarCSV(path string, filas [][]string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		w.Write(columnasArchivo)
+	}
+	w.WriteAll(filas)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
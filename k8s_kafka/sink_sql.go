@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"comun/config"
+	"k8s_kafka/historico"
+)
+
+// sinkSQL guarda cada venta en la base histórica (ver paquete historico).
+//
+//	SINK_SQL_DRIVER  sqlite (por defecto) | postgres
+//	SINK_SQL_DSN     archivo SQLite (por defecto /data/ventas.db) o URL de Postgres
+//	SINK_SQL_LOTE    filas por INSERT (por defecto 500)
+//
+// Las migraciones pendientes se aplican al arrancar.
+type sinkSQL struct {
+	st *historico.Store
+}
+
+func sinkSQLDesdeEnv() (*sinkSQL, error) {
+	driver := getenv("SINK_SQL_DRIVER", "sqlite")
+	dsn := getenv("SINK_SQL_DSN", "")
+	if dsn == "" {
+		if driver == "postgres" {
+			return nil, fmt.Errorf("SINK_SQL_DSN requerido con postgres")
+		}
+		dsn = "/data/ventas.db"
+	}
+	st, err := historico.Abrir(driver, dsn, config.LeerEntero("SINK_SQL_LOTE", 500))
+	if err != nil {
+		return nil, fmt.Errorf("SINK_SQL_DRIVER: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	aplicadas, err := st.Migrar(ctx)
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("sink sql: %w", err)
+	}
+	for _, m := range aplicadas {
+		slog.Info("sink sql: migración aplicada", "migracion", m)
+	}
+	return &sinkSQL{st: st}, nil
+}
+
+func (s *sinkSQL) nombre() string { return "sql" }
+
+func (s *sinkSQL) cerrar() error { return s.st.Close() }
+
+func (s *sinkSQL) escribir(ctx context.Context, l lote) error {
+	ventas := make([]historico.Venta, 0, len(l.mensajes))
+	for _, vp := range l.mensajes {
+		if !vp.ok {
+			continue
+		}
+		ventas = append(ventas, historico.Venta{
+			Topic:      l.topic,
+			Particion:  l.particion,
+			Offset:     vp.m.Offset,
+			Timestamp:  horaVenta(vp),
+			Categoria:  vp.v.Categoria,
+			ProductoID: vp.v.ProductoID,
+			Precio:     vp.v.Precio,
+			Cantidad:   vp.v.CantidadVendida,
+			Tenant:     vp.v.Tenant,
+
+			Nombre:       vp.v.Nombre,
+			PrecioLista:  vp.v.PrecioLista,
+			DescuentoPct: vp.v.DescuentoPct,
+		})
+	}
+	nuevas, err := s.st.Guardar(ctx, ventas)
+	if err != nil {
+		return err
+	}
+	if repetidas := len(ventas) - nuevas; repetidas > 0 {
+		slog.Info("sink sql: ventas ya guardadas", "part", l.particion, "repetidas", repetidas)
+	}
+	return nil
+}
+
+// horaVenta es el timestamp del evento (timestampUnixMs del gRPC server) o,
+// si no viene, la hora del mensaje en Kafka.
+func horaVenta(vp ventaPendiente) time.Time {
+	switch {
+	case vp.v.TimestampUnixMs > 0:
+		return time.UnixMilli(vp.v.TimestampUnixMs)
+	case !vp.m.Time.IsZero():
+		return vp.m.Time
+	}
+	return time.Now()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// sinkPrueba anota los offsets que recibe; las primeras "fallas" llamadas
+// devuelven error.
+type sinkPrueba struct {
+	n string
+
+	mu       sync.Mutex
+	fallas   int
+	recibido [][]int64
+}
+
+func (s *sinkPrueba) nombre() string { return s.n }
+func (s *sinkPrueba) cerrar() error  { return nil }
+
+func (s *sinkPrueba) escribir(ctx context.Context, l lote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := make([]int64, len(l.mensajes))
+	for i, vp := range l.mensajes {
+		offsets[i] = vp.m.Offset
+	}
+	s.recibido = append(s.recibido, offsets)
+	if s.fallas > 0 {
+		s.fallas--
+		return errors.New("caído")
+	}
+	return nil
+}
+
+// ultimo devuelve los offsets de la última llamada, o nil si no hubo
+// llamadas desde la anterior consulta.
+func (s *sinkPrueba) ultimo() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recibido) == 0 {
+		return nil
+	}
+	u := s.recibido[len(s.recibido)-1]
+	s.recibido = nil
+	return u
+}
+
+func lotePrueba(particion int, desde, hasta int64) lote {
+	l := lote{topic: "ventas", particion: particion}
+	for o := desde; o <= hasta; o++ {
+		l.mensajes = append(l.mensajes, ventaPendiente{
+			m:  kafka.Message{Topic: "ventas", Partition: particion, Offset: o, Time: time.Unix(1_700_000_000+o, 0)},
+			v:  Venta{Categoria: "Ropa", ProductoID: "p1", Precio: 10, CantidadVendida: 1},
+			ok: true,
+		})
+	}
+	return l
+}
+
+// Cuando un sink obligatorio falla, el pool reintenta el mismo lote: los
+// que ya lo escribieron no lo reciben de nuevo.
+func TestFanOutNoReenviaLoEscrito(t *testing.T) {
+	a, b := &sinkPrueba{n: "a"}, &sinkPrueba{n: "b", fallas: 1}
+	f := nuevoFanOut([]sinkConfigurado{{Sink: a}, {Sink: b}})
+	ctx := context.Background()
+
+	if err := f.escribir(ctx, lotePrueba(0, 0, 4)); err == nil {
+		t.Fatal("el fallo de un sink obligatorio no frenó el commit")
+	}
+	a.ultimo()
+	b.ultimo()
+
+	if err := f.escribir(ctx, lotePrueba(0, 0, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.ultimo(); got != nil {
+		t.Errorf("a recibió de nuevo %v", got)
+	}
+	if got := b.ultimo(); !slices.Equal(got, []int64{0, 1, 2, 3, 4}) {
+		t.Errorf("b recibió %v en el reintento", got)
+	}
+
+	// un lote que se solapa con lo escrito solo manda lo nuevo
+	if err := f.escribir(ctx, lotePrueba(0, 3, 6)); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*sinkPrueba{a, b} {
+		if got := s.ultimo(); !slices.Equal(got, []int64{5, 6}) {
+			t.Errorf("%s recibió %v, quiero [5 6]", s.n, got)
+		}
+	}
+
+	// las particiones se siguen por separado
+	if err := f.escribir(ctx, lotePrueba(1, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.ultimo(); !slices.Equal(got, []int64{0, 1}) {
+		t.Errorf("partición 1: a recibió %v", got)
+	}
+}
+
+// Un sink opcional que falla no frena el commit de los obligatorios.
+func TestFanOutOpcionalNoBloquea(t *testing.T) {
+	obligatorio := &sinkPrueba{n: "valkey"}
+	opcional := &sinkPrueba{n: "archivo", fallas: 10}
+	f := nuevoFanOut([]sinkConfigurado{{Sink: obligatorio}, {Sink: opcional, opcional: true}})
+	ctx := context.Background()
+
+	if err := f.escribir(ctx, lotePrueba(0, 0, 2)); err != nil {
+		t.Fatalf("un sink opcional frenó el commit: %v", err)
+	}
+	if got := obligatorio.ultimo(); !slices.Equal(got, []int64{0, 1, 2}) {
+		t.Errorf("obligatorio recibió %v", got)
+	}
+	// el lote fallido se pierde para el opcional: el siguiente no lo arrastra
+	if err := f.escribir(ctx, lotePrueba(0, 3, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if got := opcional.ultimo(); !slices.Equal(got, []int64{3}) {
+		t.Errorf("opcional recibió %v, quiero [3]", got)
+	}
+
+	// si falla el obligatorio sí hay error, aunque el opcional ande
+	obligatorio.fallas, opcional.fallas = 1, 0
+	if err := f.escribir(ctx, lotePrueba(0, 4, 4)); err == nil {
+		t.Fatal("el fallo del obligatorio no devolvió error")
+	}
+}
+
+// Con el sink SQL detrás del fan-out, una reentrega que el fan-out ya no
+// puede filtrar (reinicio del consumer) tampoco infla los rollups.
+func TestFanOutSQLReentrega(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ventas.db")
+	t.Setenv("SINK_SQL_DRIVER", "sqlite")
+	t.Setenv("SINK_SQL_DSN", path)
+	ctx := context.Background()
+
+	historicoSQL, err := sinkSQLDesdeEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valkey := &sinkPrueba{n: "valkey", fallas: 1}
+	f := nuevoFanOut([]sinkConfigurado{{Sink: historicoSQL}, {Sink: valkey}})
+	if err := f.escribir(ctx, lotePrueba(0, 0, 9)); err == nil {
+		t.Fatal("esperaba el error de valkey")
+	}
+	if err := f.escribir(ctx, lotePrueba(0, 0, 9)); err != nil {
+		t.Fatal(err)
+	}
+	f.cerrar()
+
+	// reinicio: fan-out nuevo, sin memoria de lo escrito
+	historicoSQL, err = sinkSQLDesdeEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f = nuevoFanOut([]sinkConfigurado{{Sink: historicoSQL}, {Sink: &sinkPrueba{n: "valkey"}}})
+	if err := f.escribir(ctx, lotePrueba(0, 5, 14)); err != nil {
+		t.Fatal(err)
+	}
+	f.cerrar()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var filas, ventas, unidades int64
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM ventas), SUM(ventas), SUM(unidades) FROM ventas_por_dia`).Scan(&filas, &ventas, &unidades); err != nil {
+		t.Fatal(err)
+	}
+	if filas != 15 || ventas != 15 || unidades != 15 {
+		t.Fatalf("%d filas, rollup con %d ventas y %d unidades; quiero 15 en todo", filas, ventas, unidades)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"comun/keys"
+)
+
+// Modo aproximado de top-K (TOPK_MODE=sketch).
+//
+// En lugar de ZSETs que crecen con cada productoId nuevo, se usa un
+// Count-Min Sketch de tamaño fijo para estimar la cantidad vendida de cada
+// producto y un min-heap con los K productos más vendidos.
+//
+// Garantías del Count-Min Sketch con ancho w = ceil(e/ε) y profundidad
+// d = ceil(ln(1/δ)), siendo N el total de unidades vistas:
+//   - el estimado nunca es menor que la cantidad real;
+//   - con probabilidad >= 1-δ el estimado excede al real en a lo sumo ε·N.
+//
+// Con los valores por defecto (ε=0.0005, δ=0.001) son 5437 x 7 contadores
+// de 8 bytes (~300 KB) por sketch, sin importar cuántos productos existan.
+// Un producto con cantidad real mayor que ε·N + (cantidad del K-ésimo)
+// siempre queda dentro del heap.
+
+type countMinSketch struct {
+	ancho uint64
+	tabla [][]uint64
+	total uint64
+}
+
+func nuevoCountMinSketch(epsilon, delta float64) *countMinSketch {
+	ancho := uint64(math.Ceil(math.E / epsilon))
+	prof := int(math.Ceil(math.Log(1 / delta)))
+	if prof < 1 {
+		prof = 1
+	}
+	tabla := make([][]uint64, prof)
+	for i := range tabla {
+		tabla[i] = make([]uint64, ancho)
+	}
+	return &countMinSketch{ancho: ancho, tabla: tabla}
+}
+
+// hashes usa doble hashing (h1 + i*h2) sobre FNV-1a de 64 bits.
+func (c *countMinSketch) hashes(item string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	h1 := h.Sum64()
+	h2 := (h1 >> 32) | (h1 << 32) | 1
+	return h1, h2
+}
+
+// agregar suma n al item y devuelve su nuevo estimado.
+func (c *countMinSketch) agregar(item string, n uint64) uint64 {
+	h1, h2 := c.hashes(item)
+	est := uint64(math.MaxUint64)
+	for i, fila := range c.tabla {
+		j := (h1 + uint64(i)*h2) % c.ancho
+		fila[j] += n
+		if fila[j] < est {
+			est = fila[j]
+		}
+	}
+	c.total += n
+	return est
+}
+
+// entradaTopK guarda además la suma de precios vista mientras el producto
+// está en el heap, para poder reportar su precio promedio sin un HASH por
+// producto en Valkey.
+type entradaTopK struct {
+	ID        string
+	Cantidad  uint64
+	SumPrecio float64
+	Ventas    int64
+	pos       int
+}
+
+type heapTopK []*entradaTopK
+
+func (h heapTopK) Len() int           { return len(h) }
+func (h heapTopK) Less(i, j int) bool { return h[i].Cantidad < h[j].Cantidad }
+func (h heapTopK) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+func (h *heapTopK) Push(x any) {
+	e := x.(*entradaTopK)
+	e.pos = len(*h)
+	*h = append(*h, e)
+}
+func (h *heapTopK) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+type topK struct {
+	k   int
+	cms *countMinSketch
+	h   heapTopK
+	idx map[string]*entradaTopK
+}
+
+func nuevoTopK(k int, epsilon, delta float64) *topK {
+	return &topK{
+		k:   k,
+		cms: nuevoCountMinSketch(epsilon, delta),
+		idx: make(map[string]*entradaTopK, k),
+	}
+}
+
+// agregar registra una venta. Devuelve el producto desplazado del top-K
+// (o "" si no hubo) y si el producto quedó dentro del top-K.
+func (t *topK) agregar(id string, cantidad uint64, precio float64) (desplazado string, dentro bool) {
+	est := t.cms.agregar(id, cantidad)
+
+	if e, ok := t.idx[id]; ok {
+		e.Cantidad = est
+		e.SumPrecio += precio
+		e.Ventas++
+		heap.Fix(&t.h, e.pos)
+		return "", true
+	}
+	return t.insertar(&entradaTopK{ID: id, Cantidad: est, SumPrecio: precio, Ventas: 1})
+}
+
+// sembrar carga un conteo ya publicado (ver productosAproximados.sembrar).
+// El promedio de precio publicado pesa como ventas ventas.
+func (t *topK) sembrar(id string, cantidad uint64, avg float64, ventas int64) {
+	est := t.cms.agregar(id, cantidad)
+	if e, ok := t.idx[id]; ok {
+		e.Cantidad = est
+		heap.Fix(&t.h, e.pos)
+		return
+	}
+	t.insertar(&entradaTopK{ID: id, Cantidad: est, SumPrecio: avg * float64(ventas), Ventas: ventas})
+}
+
+// insertar agrega un producto que no está en el heap si entra en el top-K.
+func (t *topK) insertar(e *entradaTopK) (desplazado string, dentro bool) {
+	if len(t.h) < t.k {
+		heap.Push(&t.h, e)
+		t.idx[e.ID] = e
+		return "", true
+	}
+
+	if e.Cantidad <= t.h[0].Cantidad {
+		return "", false
+	}
+
+	min := heap.Pop(&t.h).(*entradaTopK)
+	delete(t.idx, min.ID)
+	heap.Push(&t.h, e)
+	t.idx[e.ID] = e
+	return min.ID, true
+}
+
+// productosAproximados mantiene el top-K global y por categoría de una
+// partición y escribe en Valkey solo estructuras acotadas: ZSETs de K
+// miembros y HyperLogLogs para el conteo de productos distintos (error
+// estándar ~0.81%, 12 KB por key).
+//
+// Con varias particiones cada una aporta su top-K al mismo ZSET con ZADD GT
+// y el líder lo recorta a K. El merge es exacto mientras cada producto caiga
+// siempre en la misma partición (productor con balanceo por key).
+//
+// Los sketches viven en memoria y arrancan vacíos en cada proceso o cuando
+// la partición cambia de réplica. Para que ZADD GT no deje de avanzar hasta
+// que el conteo local supere al publicado, cada top-K se siembra (sembrar)
+// con su ZSET y su HASH de promedios antes de la primera venta: el score de
+// un producto sigue creciendo desde lo ya publicado. Los productos que no
+// están en el ZSET (debajo del K-ésimo) vuelven a contar desde cero; solo
+// afecta a los que después subirían al top-K, con un error a lo sumo igual
+// al score del K-ésimo al momento de reiniciar.
+//
+// Si otra réplica aplicó offsets de la partición desde la última vez (un
+// rebalance de ida y vuelta) o un flush no llegó a aplicarse, el sketch no
+// coincide con Valkey: el agregador lo descarta y arma uno nuevo (ver
+// alDia).
+//
+// El producto menos vendido no tiene equivalente aproximado (no se puede
+// acotar el mínimo de un conjunto sin límite), por lo que en este modo
+// venta:stats:producto_menos_vendido no se actualiza.
+type productosAproximados struct {
+	k              int
+	epsilon, delta float64
+	global         *topK            // nil hasta sembrarlo
+	porCategoria   map[string]*topK // solo las ya sembradas
+	ks             keys.Schema
+
+	hasta     int64                          // último offset observado
+	cambiados map[string]map[string]struct{} // categoria ("" = global) -> productos a escribir
+}
+
+// nuevoProductosAproximados arma los sketches de una partición cuyo último
+// offset aplicado en Valkey es hasta (-1 si ninguno).
+func nuevoProductosAproximados(ks keys.Schema, k int, epsilon, delta float64, hasta int64) *productosAproximados {
+	return &productosAproximados{
+		k:            k,
+		epsilon:      epsilon,
+		delta:        delta,
+		porCategoria: make(map[string]*topK),
+		ks:           ks,
+		hasta:        hasta,
+		cambiados:    make(map[string]map[string]struct{}),
+	}
+}
+
+// alDia indica si los sketches reflejan todo lo aplicado en Valkey hasta el
+// offset guardado de la partición.
+func (p *productosAproximados) alDia(guardado int64) bool {
+	return p.hasta == guardado
+}
+
+// sembrar carga los ZSET (y promedios) publicados del top-K global y de las
+// categorías de ventas que todavía no tienen sketch. Se llama dentro del
+// WATCH del flush, antes de observar.
+func (p *productosAproximados) sembrar(ctx context.Context, rdb redis.Cmdable, ventas []Venta) error {
+	if p.global == nil {
+		tk, err := p.sembrado(ctx, rdb, p.ks.TopKProductos(), "")
+		if err != nil {
+			return err
+		}
+		p.global = tk
+	}
+	for _, v := range ventas {
+		if _, ok := p.porCategoria[v.Categoria]; ok {
+			continue
+		}
+		tk, err := p.sembrado(ctx, rdb, p.ks.TopKPorCategoria(v.Categoria), p.ks.TopKAvgPrecio(v.Categoria))
+		if err != nil {
+			return err
+		}
+		p.porCategoria[v.Categoria] = tk
+	}
+	return nil
+}
+
+// sembrado arma un top-K con los miembros del ZSET zkey. Con avgKey, el
+// promedio publicado de cada producto pesa como tantas ventas como unidades
+// tiene (el conteo real de ventas no se publica).
+func (p *productosAproximados) sembrado(ctx context.Context, rdb redis.Cmdable, zkey, avgKey string) (*topK, error) {
+	tk := nuevoTopK(p.k, p.epsilon, p.delta)
+	zs, err := rdb.ZRangeWithScores(ctx, zkey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("sembrando top-K desde %s: %w", zkey, err)
+	}
+	var avgs map[string]string
+	if avgKey != "" && len(zs) > 0 {
+		if avgs, err = rdb.HGetAll(ctx, avgKey).Result(); err != nil {
+			return nil, fmt.Errorf("sembrando promedios desde %s: %w", avgKey, err)
+		}
+	}
+	for _, z := range zs {
+		id := fmt.Sprint(z.Member)
+		if z.Score < 1 {
+			continue
+		}
+		cant := uint64(z.Score)
+		var avg float64
+		var ventas int64
+		if f, err := strconv.ParseFloat(avgs[id], 64); err == nil {
+			avg, ventas = f, int64(cant)
+		}
+		tk.sembrar(id, cant, avg, ventas)
+	}
+	return tk, nil
+}
+
+// observar actualiza los sketches en memoria. Si un flush se reintenta, los
+// offsets ya observados se ignoran para no contarlos dos veces.
+func (p *productosAproximados) observar(offset int64, v Venta) {
+	if offset <= p.hasta {
+		return
+	}
+	p.hasta = offset
+	if v.CantidadVendida <= 0 {
+		return
+	}
+	cant := uint64(v.CantidadVendida)
+
+	if p.global == nil {
+		p.global = nuevoTopK(p.k, p.epsilon, p.delta)
+	}
+	if _, dentro := p.global.agregar(v.ProductoID, cant, v.Precio); dentro {
+		p.marcar("", v.ProductoID)
+	}
+	tk, ok := p.porCategoria[v.Categoria]
+	if !ok {
+		tk = nuevoTopK(p.k, p.epsilon, p.delta)
+		p.porCategoria[v.Categoria] = tk
+	}
+	if _, dentro := tk.agregar(v.ProductoID, cant, v.Precio); dentro {
+		p.marcar(v.Categoria, v.ProductoID)
+	}
+}
+
+// avanzar registra offsets aplicados que no son ventas (JSON inválido), para
+// que alDia siga coincidiendo con el offset guardado.
+func (p *productosAproximados) avanzar(offset int64) {
+	if offset > p.hasta {
+		p.hasta = offset
+	}
+}
+
+func (p *productosAproximados) marcar(categoria, id string) {
+	m, ok := p.cambiados[categoria]
+	if !ok {
+		m = make(map[string]struct{})
+		p.cambiados[categoria] = m
+	}
+	m[id] = struct{}{}
+}
+
+// escribir encola en la transacción del flush los cambios pendientes. Los
+// productos que ya salieron del heap local no se escriben; el líder recorta
+// los ZSET a K.
+func (p *productosAproximados) escribir(ctx context.Context, pipe redis.Pipeliner, ventas []Venta) {
+	// Productos distintos (HyperLogLog)
+	porCat := make(map[string][]any)
+	var todos []any
+	for _, v := range ventas {
+		porCat[v.Categoria] = append(porCat[v.Categoria], v.ProductoID)
+		todos = append(todos, v.ProductoID)
+	}
+	for c, ids := range porCat {
+		pipe.PFAdd(ctx, p.ks.ProductosDistintosCategoria(c), ids...)
+	}
+	if len(todos) > 0 {
+		pipe.PFAdd(ctx, p.ks.ProductosDistintos(), todos...)
+	}
+
+	for c, ids := range p.cambiados {
+		tk, zkey := p.global, p.ks.TopKProductos()
+		if c != "" {
+			tk, zkey = p.porCategoria[c], p.ks.TopKPorCategoria(c)
+		}
+		for id := range ids {
+			e, ok := tk.idx[id]
+			if !ok {
+				continue
+			}
+			pipe.ZAddGT(ctx, zkey, redis.Z{Score: float64(e.Cantidad), Member: id})
+			if c != "" && e.Ventas > 0 {
+				pipe.HSet(ctx, p.ks.TopKAvgPrecio(c), id, fmt.Sprintf("%.2f", e.SumPrecio/float64(e.Ventas)))
+			}
+		}
+	}
+}
+
+// confirmar se llama cuando la transacción del flush se aplicó.
+func (p *productosAproximados) confirmar() {
+	p.cambiados = make(map[string]map[string]struct{})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+
+	"comun/keys"
+)
+
+// flujoZipf genera n ventas de una unidad con productos de popularidad Zipf
+// (pocos muy vendidos y una cola larga) y devuelve también los conteos
+// reales.
+func flujoZipf(seed int64, n, productos int) ([]string, map[string]uint64) {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.2, 1, uint64(productos-1))
+	ids := make([]string, n)
+	real := make(map[string]uint64)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", z.Uint64())
+		real[ids[i]]++
+	}
+	return ids, real
+}
+
+func TestCountMinSketchError(t *testing.T) {
+	const eps, delta = 0.001, 0.01
+	ids, real := flujoZipf(1, 200_000, 50_000)
+
+	cms := nuevoCountMinSketch(eps, delta)
+	for _, id := range ids {
+		cms.agregar(id, 1)
+	}
+	if cms.total != uint64(len(ids)) {
+		t.Fatalf("total = %d, quiero %d", cms.total, len(ids))
+	}
+
+	limite := eps * float64(cms.total)
+	var excedidos int
+	for id, n := range real {
+		est := cms.agregar(id, 0)
+		if est < n {
+			t.Fatalf("%s: estimado %d menor que el real %d", id, est, n)
+		}
+		if float64(est-n) > limite {
+			excedidos++
+		}
+	}
+	// con probabilidad >= 1-δ por producto el error es <= ε·N; se tolera el
+	// doble de δ para que el test no dependa de la semilla
+	if frac := float64(excedidos) / float64(len(real)); frac > 2*delta {
+		t.Errorf("%.4f de los productos exceden ε·N=%.0f, quiero <= %.4f", frac, limite, 2*delta)
+	}
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	const k, eps, delta = 10, 0.0005, 0.001
+	ids, real := flujoZipf(2, 200_000, 50_000)
+
+	tk := nuevoTopK(k, eps, delta)
+	for _, id := range ids {
+		tk.agregar(id, 1, 10)
+	}
+	if len(tk.h) != k || len(tk.idx) != k {
+		t.Fatalf("heap con %d/%d entradas, quiero %d", len(tk.h), len(tk.idx), k)
+	}
+
+	orden := make([]string, 0, len(real))
+	for id := range real {
+		orden = append(orden, id)
+	}
+	sort.Slice(orden, func(i, j int) bool { return real[orden[i]] > real[orden[j]] })
+
+	// todo producto con más de ε·N + (cantidad del K-ésimo) está en el heap
+	umbral := eps*float64(len(ids)) + float64(real[orden[k-1]])
+	for _, id := range orden {
+		if float64(real[id]) <= umbral {
+			break
+		}
+		if _, ok := tk.idx[id]; !ok {
+			t.Errorf("%s (real %d > %.0f) no está en el top-K", id, real[id], umbral)
+		}
+	}
+	// y en este flujo el heap coincide con el top-K real
+	for _, id := range orden[:k] {
+		e, ok := tk.idx[id]
+		if !ok {
+			t.Errorf("%s (real %d) no está en el top-K", id, real[id])
+			continue
+		}
+		if e.Cantidad < real[id] {
+			t.Errorf("%s: cantidad %d menor que la real %d", id, e.Cantidad, real[id])
+		}
+	}
+}
+
+func TestTopKDesplazado(t *testing.T) {
+	tk := nuevoTopK(2, 0.01, 0.01)
+	tk.agregar("a", 5, 1)
+	tk.agregar("b", 3, 1)
+	if d, dentro := tk.agregar("c", 1, 1); d != "" || dentro {
+		t.Fatalf("c con 1 unidad: desplazado %q dentro %v", d, dentro)
+	}
+	if d, dentro := tk.agregar("c", 5, 1); d != "b" || !dentro {
+		t.Fatalf("c con 6 unidades: desplazado %q dentro %v, quiero b true", d, dentro)
+	}
+}
+
+func TestHyperLogLogProductosDistintos(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	p := nuevoProductosAproximados(keys.New("venta", false), 10, 0.001, 0.01, -1)
+	const distintos = 20_000
+	var ventas []Venta
+	for i := 0; i < 3*distintos; i++ {
+		// cada producto se repite tres veces
+		ventas = append(ventas, Venta{Categoria: "Electro", ProductoID: fmt.Sprintf("p%d", i%distintos), CantidadVendida: 1})
+	}
+	if _, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		p.escribir(ctx, pipe, ventas)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{p.ks.ProductosDistintos(), p.ks.ProductosDistintosCategoria("Electro")} {
+		n, err := rdb.PFCount(ctx, key).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// error estándar 0.81%: se acepta 3 desvíos
+		if e := math.Abs(float64(n)-distintos) / distintos; e > 3*0.0081 {
+			t.Errorf("%s = %d, quiero %d ± 2.43%% (error %.2f%%)", key, n, distintos, 100*e)
+		}
+	}
+}
+
+func TestProductosAproximadosSembrado(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	ks := keys.New("venta", false)
+
+	// lo que publicó el proceso anterior
+	rdb.ZAdd(ctx, ks.TopKProductos(), redis.Z{Score: 100, Member: "a"}, redis.Z{Score: 40, Member: "b"})
+	rdb.ZAdd(ctx, ks.TopKPorCategoria("Electro"), redis.Z{Score: 100, Member: "a"})
+	rdb.HSet(ctx, ks.TopKAvgPrecio("Electro"), "a", "20.00")
+
+	p := nuevoProductosAproximados(ks, 10, 0.001, 0.01, 7)
+	ventas := []Venta{{Categoria: "Electro", ProductoID: "a", CantidadVendida: 2, Precio: 10}}
+	if err := p.sembrar(ctx, rdb, ventas); err != nil {
+		t.Fatal(err)
+	}
+	p.observar(8, ventas[0])
+	if !p.alDia(8) {
+		t.Fatalf("hasta = %d, quiero 8", p.hasta)
+	}
+	if _, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		p.escribir(ctx, pipe, ventas)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// el score sigue desde lo publicado en vez de quedar en 2
+	for _, key := range []string{ks.TopKProductos(), ks.TopKPorCategoria("Electro")} {
+		if s := rdb.ZScore(ctx, key, "a").Val(); s != 102 {
+			t.Errorf("%s a = %v, quiero 102", key, s)
+		}
+	}
+	if s := rdb.ZScore(ctx, ks.TopKProductos(), "b").Val(); s != 40 {
+		t.Errorf("b = %v, quiero 40", s)
+	}
+	// promedio: 100 unidades a 20 y una venta a 10
+	if avg := rdb.HGet(ctx, ks.TopKAvgPrecio("Electro"), "a").Val(); avg != "19.90" {
+		t.Errorf("promedio = %s, quiero 19.90", avg)
+	}
+}
+
+// tamañoValkey cuenta keys y elementos (campos, miembros, entradas) de todas
+// las keys.
+func tamañoValkey(t *testing.T, ctx context.Context, rdb *redis.Client) (keys, elementos int64) {
+	t.Helper()
+	todas, err := rdb.Keys(ctx, "*").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range todas {
+		var n int64
+		switch tipo := rdb.Type(ctx, k).Val(); tipo {
+		case "hash":
+			n = rdb.HLen(ctx, k).Val()
+		case "zset":
+			n = rdb.ZCard(ctx, k).Val()
+		case "list":
+			n = rdb.LLen(ctx, k).Val()
+		case "set":
+			n = rdb.SCard(ctx, k).Val()
+		case "stream":
+			n = rdb.XLen(ctx, k).Val()
+		default:
+			n = 1
+		}
+		elementos += n
+	}
+	return int64(len(todas)), elementos
+}
+
+// En TOPK_MODE=sketch la cantidad de keys y de elementos en Valkey no crece
+// con la cantidad de productos distintos.
+func TestSketchMemoriaAcotada(t *testing.T) {
+	t.Setenv("TOPK_MODE", "sketch")
+	t.Setenv("TOPK_K", "10")
+	t.Setenv("RAW_EVENTS_MODE", "off")
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	a, err := nuevoAgregador(rdb, keys.New("venta", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mat := nuevoMaterializador(rdb, a.ks, a, "prueba")
+	categorias := []string{"Electro", "Hogar", "Moda"}
+	offset := int64(0)
+	escribirProductos := func(desde, hasta int) {
+		t.Helper()
+		l := lote{topic: "ventas", particion: 0}
+		for i := desde; i < hasta; i++ {
+			v := Venta{Categoria: categorias[i%len(categorias)], ProductoID: fmt.Sprintf("p%d", i), CantidadVendida: 1, Precio: float64(10 + i%50)}
+			l.mensajes = append(l.mensajes, ventaPendiente{m: kafka.Message{Topic: "ventas", Offset: offset, Time: time.Unix(1_700_000_000+offset, 0)}, v: v, ok: true})
+			offset++
+		}
+		if err := a.escribir(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		// el líder recorta los top-K que aportan las particiones
+		if err := mat.recalcularTopK(ctx, categorias); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	escribirProductos(0, 500)
+	keys1, elem1 := tamañoValkey(t, ctx, rdb)
+	escribirProductos(500, 5000)
+	keys2, elem2 := tamañoValkey(t, ctx, rdb)
+	if keys2 != keys1 || elem2 != elem1 {
+		t.Errorf("con 500 productos: %d keys, %d elementos; con 5000: %d keys, %d elementos", keys1, elem1, keys2, elem2)
+	}
+}
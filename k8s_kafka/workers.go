@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"comun/config"
+)
+
+// fuenteMensajes es lo que el consumer usa de *kafka.Reader; el benchmark
+// del pool (bench_test.go) la reemplaza por una fuente en memoria.
+type fuenteMensajes interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// poolParticiones reparte los mensajes en un worker (goroutine) por
+// partición asignada. Cada worker agrega, hace flush y confirma solo su
+// partición, así que el orden dentro de la partición se mantiene (por
+// productoId con el particionado por defecto del gRPC server, ver
+// KAFKA_PARTICIONADO) y los commits de una partición no esperan a las demás.
+//
+// Backpressure: cada worker tiene una cola de WORKER_QUEUE mensajes. Si
+// un sink está lento el flush bloquea al worker, su cola se llena y el loop
+// que lee de Kafka queda bloqueado en despachar, sin seguir acumulando en
+// memoria. Un flush fallido se reintenta con backoff sin leer más mensajes.
+type poolParticiones struct {
+	sink       Sink
+	confirmar  func(context.Context, kafka.Message) error // nil: sin commit (rebuild)
+	abortar    bool                                       // un flush fallido corta el pool en vez de reintentar
+	logOK      bool                                       // líneas "OK" por mensaje, muestreadas
+	muestreo   muestreo
+	enriquecer func(*Venta)                                      // nil: sin catálogo
+	dlq        func(context.Context, kafka.Message, error) error // nil: los inválidos solo se loguean
+
+	flushIntervalo time.Duration
+	flushMax       int
+	colaWorker     int
+
+	ctx     context.Context
+	workers map[int]*worker
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	primer error
+}
+
+type worker struct {
+	p    *particion
+	cola chan kafka.Message
+}
+
+// particion es el estado local de un worker: los mensajes leídos desde el
+// último flush.
+type particion struct {
+	id         int
+	topic      string
+	pendientes []ventaPendiente
+	desde      time.Time // llegada del primer pendiente
+}
+
+func nuevoPoolParticiones(ctx context.Context, sink Sink, confirmar func(context.Context, kafka.Message) error) *poolParticiones {
+	return &poolParticiones{
+		sink:           sink,
+		confirmar:      confirmar,
+		logOK:          true,
+		flushIntervalo: config.LeerDuracion("FLUSH_INTERVAL", time.Second),
+		flushMax:       config.LeerEntero("FLUSH_MAX_MENSAJES", 500),
+		colaWorker:     config.LeerEntero("WORKER_QUEUE", 1000),
+		ctx:            ctx,
+		workers:        make(map[int]*worker),
+	}
+}
+
+// despachar encola el mensaje en el worker de su partición. Bloquea si la
+// cola está llena.
+func (pp *poolParticiones) despachar(m kafka.Message) error {
+	w, ok := pp.workers[m.Partition]
+	if !ok {
+		w = &worker{
+			p:    &particion{id: m.Partition, topic: m.Topic},
+			cola: make(chan kafka.Message, pp.colaWorker),
+		}
+		pp.workers[m.Partition] = w
+		pp.wg.Add(1)
+		go pp.correr(w)
+	}
+	select {
+	case w.cola <- m:
+		return nil
+	case <-pp.ctx.Done():
+		return pp.ctx.Err()
+	}
+}
+
+// cerrar deja de aceptar mensajes, espera que cada worker vacíe su cola y
+// haga el último flush, y devuelve el primer error si abortar=true.
+func (pp *poolParticiones) cerrar() error {
+	for _, w := range pp.workers {
+		close(w.cola)
+	}
+	pp.wg.Wait()
+	return pp.err()
+}
+
+func (pp *poolParticiones) err() error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.primer
+}
+
+func (pp *poolParticiones) fallar(err error) {
+	pp.mu.Lock()
+	if pp.primer == nil {
+		pp.primer = err
+	}
+	pp.mu.Unlock()
+}
+
+func (pp *poolParticiones) correr(w *worker) {
+	defer pp.wg.Done()
+	t := time.NewTicker(pp.flushIntervalo)
+	defer t.Stop()
+
+	for {
+		select {
+		case m, ok := <-w.cola:
+			if !ok {
+				// cierre: último flush aunque ctx ya esté cancelado
+				pp.flush(context.Background(), w.p, false)
+				return
+			}
+			pp.agregar(w.p, m)
+			if len(w.p.pendientes) >= pp.flushMax {
+				pp.flush(pp.ctx, w.p, true)
+			}
+		case <-t.C:
+			pp.flush(pp.ctx, w.p, true)
+		}
+	}
+}
+
+// agregar parsea el mensaje y lo deja pendiente; no escribe en los sinks.
+func (pp *poolParticiones) agregar(p *particion, m kafka.Message) {
+	if len(p.pendientes) == 0 {
+		p.desde = time.Now()
+	}
+
+	vp := ventaPendiente{m: m}
+	if err := json.Unmarshal(m.Value, &vp.v); err != nil {
+		slog.Warn("JSON inválido, no agrego stats", "rid", requestID(m), "part", m.Partition, "off", m.Offset, "err", err, "value", string(m.Value))
+		if pp.dlq != nil {
+			if err := pp.dlq(pp.ctx, m, err); err != nil {
+				slog.Error("DLQ", "rid", requestID(m), "part", m.Partition, "off", m.Offset, "err", err)
+			}
+		}
+	} else {
+		vp.ok = true
+		if vp.v.Categoria == "" {
+			vp.v.Categoria = "Desconocida"
+		}
+		if vp.v.ProductoID == "" {
+			vp.v.ProductoID = "UNKNOWN"
+		}
+		if pp.enriquecer != nil {
+			pp.enriquecer(&vp.v)
+		}
+		if pp.logOK && pp.muestreo.toca() {
+			slog.Info("OK", "rid", requestID(m), "cat", vp.v.Categoria, "precio", vp.v.Precio,
+				"prod", vp.v.ProductoID, "cant", vp.v.CantidadVendida, "tenant", vp.v.Tenant,
+				"part", m.Partition, "off", m.Offset)
+		}
+	}
+	p.pendientes = append(p.pendientes, vp)
+}
+
+// flush escribe los pendientes en el sink y confirma el offset en Kafka.
+// Con reintentar bloquea al worker hasta lograrlo (o hasta que se cancele
+// el pool).
+func (pp *poolParticiones) flush(ctx context.Context, p *particion, reintentar bool) {
+	espera := 100 * time.Millisecond
+	for len(p.pendientes) > 0 && pp.err() == nil {
+		l := lote{topic: p.topic, particion: p.id, mensajes: p.pendientes}
+		err := pp.sink.escribir(ctx, l)
+		if err == nil {
+			ultimo := l.ultimo()
+			p.pendientes = nil
+			if pp.confirmar != nil {
+				if err := pp.confirmar(ctx, ultimo); err != nil {
+					slog.Error("Kafka commit", "part", ultimo.Partition, "off", ultimo.Offset, "err", err)
+				}
+			}
+			return
+		}
+		if pp.abortar {
+			pp.fallar(err)
+			return
+		}
+		slog.Warn("flush fallido", "part", p.id, "mensajes", len(p.pendientes), "reintento_en", espera.String(), "err", err)
+		if !reintentar || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-time.After(espera):
+		case <-ctx.Done():
+			return
+		}
+		espera = min(2*espera, 5*time.Second)
+	}
+}
+
+// consumir lee de la fuente y despacha hasta que se cancele ctx o la fuente
+// se agote (io.EOF); después cierra el pool.
+func consumir(ctx context.Context, fuente fuenteMensajes, pp *poolParticiones) error {
+	for ctx.Err() == nil && pp.err() == nil {
+		m, err := fuente.FetchMessage(ctx)
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			slog.Error("Kafka read", "err", err)
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if err := pp.despachar(m); err != nil {
+			break
+		}
+	}
+	return pp.cerrar()
+}